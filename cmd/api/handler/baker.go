@@ -0,0 +1,177 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/frisk038/tezos-delegation-service/domain/entity"
+	"github.com/gin-gonic/gin"
+)
+
+// bakerDelegatorsGetter defines an interface for getting the current delegators of a baker.
+type bakerDelegatorsGetter interface {
+	GetBakerDelegators(ctx context.Context, rq entity.BakerDelegatorsRequest) (entity.BakerDelegators, error)
+}
+
+// bakerSnapshotGetter defines an interface for getting the delegators of a baker as of a past moment.
+type bakerSnapshotGetter interface {
+	GetBakerSnapshot(ctx context.Context, rq entity.BakerSnapshotRequest) (entity.BakerDelegators, error)
+}
+
+// bakerDelegatorsJs represents the JSON response format for the current delegators of a baker.
+type bakerDelegatorsJs struct {
+	Baker      string        `json:"baker"`
+	Count      int64         `json:"count"`
+	Total      int64         `json:"total"`
+	Delegators []delegatorJs `json:"delegators"`
+}
+
+// bakerSnapshotJs represents the JSON response format for the delegators of a baker as of a past timestamp or level.
+type bakerSnapshotJs struct {
+	Baker      string        `json:"baker"`
+	At         *time.Time    `json:"at,omitempty"`
+	Level      int64         `json:"level,omitempty"`
+	Count      int64         `json:"count"`
+	Total      int64         `json:"total"`
+	Delegators []delegatorJs `json:"delegators"`
+}
+
+// GetBakerDelegators is a Gin HTTP handler that retrieves the current delegators of a baker.
+// @Summary Get the delegators of a baker
+// @Description Retrieve the current delegators of a baker with their amount, along with their count and total amount
+// @ID get-baker-delegators
+// @Accept  json
+// @Produce  json
+// @Param address path string true "Address of the baker"
+// @Param limit query int false "Limit the number of results (default is 10)"
+// @Param offset query int false "Offset for pagination"
+// @Param sort query string false "Sort by amount or date, descending (default is amount)" Enums(amount, date)
+// @Success 200 {object} bakerDelegatorsJs
+// @Failure 400 "Invalid address, pagination or sort"
+// @Router /xtz/bakers/{address}/delegators [get]
+//
+//goland:noinspection GoPreferNilSlice
+func GetBakerDelegators(cfg Config, getter bakerDelegatorsGetter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		address := c.Param("address")
+		if !addressFormat.MatchString(address) {
+			_ = c.AbortWithError(http.StatusBadRequest, errors.New("address is not a valid Tezos address"))
+			return
+		}
+
+		limit, offset, err := parsePagination(c, cfg)
+		if err != nil {
+			_ = c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+
+		sort := c.Query("sort")
+		switch sort {
+		case "":
+			sort = entity.SortAmount
+		case entity.SortAmount, entity.SortDate:
+		default:
+			_ = c.AbortWithError(http.StatusBadRequest, errors.New("sort must be amount or date"))
+			return
+		}
+
+		bd, err := getter.GetBakerDelegators(c.Request.Context(), entity.BakerDelegatorsRequest{
+			Baker:  address,
+			Limit:  limit,
+			Offset: offset,
+			Sort:   sort,
+		})
+		if err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		resp := bakerDelegatorsJs{
+			Baker:      address,
+			Count:      bd.Count,
+			Total:      bd.Total,
+			Delegators: []delegatorJs{},
+		}
+		for _, st := range bd.Delegators {
+			resp.Delegators = append(resp.Delegators, toDelegatorJs(st))
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": resp})
+	}
+}
+
+// GetBakerSnapshot is a Gin HTTP handler that retrieves the delegators of a baker as of a past timestamp or level.
+// @Summary Get a snapshot of the delegators of a baker
+// @Description Rebuild from the stored history who was delegating to a baker at a given timestamp or level, with the amount known at that point
+// @ID get-baker-snapshot
+// @Accept  json
+// @Produce  json
+// @Param address path string true "Address of the baker"
+// @Param at query string true "Level, or RFC 3339 timestamp, of the snapshot (included)"
+// @Success 200 {object} bakerSnapshotJs
+// @Failure 400 "Invalid address or snapshot point"
+// @Router /xtz/bakers/{address}/snapshot [get]
+//
+//goland:noinspection GoPreferNilSlice
+func GetBakerSnapshot(getter bakerSnapshotGetter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		address := c.Param("address")
+		if !addressFormat.MatchString(address) {
+			_ = c.AbortWithError(http.StatusBadRequest, errors.New("address is not a valid Tezos address"))
+			return
+		}
+
+		rq, err := parseSnapshotPoint(c.Query("at"))
+		if err != nil {
+			_ = c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+		rq.Baker = address
+
+		bd, err := getter.GetBakerSnapshot(c.Request.Context(), rq)
+		if err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		resp := bakerSnapshotJs{
+			Baker:      address,
+			Level:      rq.Level,
+			Count:      bd.Count,
+			Total:      bd.Total,
+			Delegators: []delegatorJs{},
+		}
+		if !rq.At.IsZero() {
+			resp.At = &rq.At
+		}
+		for _, st := range bd.Delegators {
+			resp.Delegators = append(resp.Delegators, toDelegatorJs(st))
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": resp})
+	}
+}
+
+// parseSnapshotPoint reads the point of a snapshot, given either as a level or as an RFC 3339 timestamp.
+func parseSnapshotPoint(at string) (entity.BakerSnapshotRequest, error) {
+	if len(at) == 0 {
+		return entity.BakerSnapshotRequest{}, errors.New("at is required")
+	}
+
+	if level, err := strconv.ParseInt(at, 10, 64); err == nil {
+		if level <= 0 {
+			return entity.BakerSnapshotRequest{}, errors.New("at level must be positive")
+		}
+		return entity.BakerSnapshotRequest{Level: level}, nil
+	}
+
+	tm, err := time.Parse(time.RFC3339, at)
+	if err != nil {
+		return entity.BakerSnapshotRequest{}, errors.New("at must be a level or an RFC 3339 timestamp")
+	}
+
+	return entity.BakerSnapshotRequest{At: tm.UTC()}, nil
+}
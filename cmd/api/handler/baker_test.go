@@ -0,0 +1,256 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/frisk038/tezos-delegation-service/domain/entity"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockBakerUsecase struct {
+	mock.Mock
+}
+
+func (mu *mockBakerUsecase) GetBakerDelegators(ctx context.Context, rq entity.BakerDelegatorsRequest) (entity.BakerDelegators, error) {
+	called := mu.Called(ctx, rq)
+	return called.Get(0).(entity.BakerDelegators), called.Error(1)
+}
+
+func (mu *mockBakerUsecase) GetBakerSnapshot(ctx context.Context, rq entity.BakerSnapshotRequest) (entity.BakerDelegators, error) {
+	called := mu.Called(ctx, rq)
+	return called.Get(0).(entity.BakerDelegators), called.Error(1)
+}
+
+func TestGetBakerDelegators(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := Config{
+		MaxLimit:     100,
+		DefaultLimit: 10,
+	}
+	baker := "tz1aRoaRhSpRYvFdyvgWLL6TGyRoGF51wDjM"
+	tn, _ := time.Parse(time.RFC3339, "2023-09-16T11:53:01Z")
+
+	t.Run("success", func(t *testing.T) {
+		c, w := getAddressTestContext(baker)
+		c.Request.URL.RawQuery = "limit=1&sort=date"
+		mu := &mockBakerUsecase{}
+		mu.On("GetBakerDelegators", c.Request.Context(), entity.BakerDelegatorsRequest{
+			Baker: baker,
+			Limit: 1,
+			Sort:  entity.SortDate,
+		}).Return(entity.BakerDelegators{
+			Delegators: []entity.DelegatorState{
+				{
+					Delegator:  "tz1VSUr8wwNhLAzempoch5d6hLRiTh8Cjcjb",
+					Baker:      baker,
+					Since:      tn,
+					SinceLevel: 4000001,
+					LastId:     3034,
+					Amount:     1000034,
+				},
+			},
+			Count: 2,
+			Total: 1001268,
+		}, nil)
+
+		GetBakerDelegators(cfg, mu)(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t,
+			`{
+			"data":{
+				"baker":"tz1aRoaRhSpRYvFdyvgWLL6TGyRoGF51wDjM",
+				"count":2,
+				"total":1001268,
+				"delegators":[{
+					"delegator":"tz1VSUr8wwNhLAzempoch5d6hLRiTh8Cjcjb",
+					"baker":"tz1aRoaRhSpRYvFdyvgWLL6TGyRoGF51wDjM",
+					"since":"2023-09-16T11:53:01Z",
+					"sinceLevel":4000001,
+					"lastId":3034,
+					"amount":1000034
+				}]
+			}}`,
+			w.Body.String(),
+		)
+		mu.AssertExpectations(t)
+	})
+
+	t.Run("no_delegators", func(t *testing.T) {
+		c, w := getAddressTestContext(baker)
+		mu := &mockBakerUsecase{}
+		mu.On("GetBakerDelegators", c.Request.Context(), entity.BakerDelegatorsRequest{
+			Baker: baker,
+			Limit: 10,
+			Sort:  entity.SortAmount,
+		}).Return(entity.BakerDelegators{}, nil)
+
+		GetBakerDelegators(cfg, mu)(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t,
+			`{"data":{"baker":"tz1aRoaRhSpRYvFdyvgWLL6TGyRoGF51wDjM","count":0,"total":0,"delegators":[]}}`,
+			w.Body.String(),
+		)
+		mu.AssertExpectations(t)
+	})
+
+	t.Run("invalid_address", func(t *testing.T) {
+		c, w := getAddressTestContext("baker1")
+		mu := &mockBakerUsecase{}
+
+		GetBakerDelegators(cfg, mu)(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		mu.AssertExpectations(t)
+	})
+
+	t.Run("wrong_sort", func(t *testing.T) {
+		c, w := getAddressTestContext(baker)
+		c.Request.URL.RawQuery = "sort=delegator"
+		mu := &mockBakerUsecase{}
+
+		GetBakerDelegators(cfg, mu)(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		mu.AssertExpectations(t)
+	})
+
+	t.Run("fail_from_uc", func(t *testing.T) {
+		c, w := getAddressTestContext(baker)
+		mu := &mockBakerUsecase{}
+		mu.On("GetBakerDelegators", c.Request.Context(), entity.BakerDelegatorsRequest{
+			Baker: baker,
+			Limit: 10,
+			Sort:  entity.SortAmount,
+		}).Return(entity.BakerDelegators{}, errors.New("err"))
+
+		GetBakerDelegators(cfg, mu)(c)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		mu.AssertExpectations(t)
+	})
+}
+
+func TestGetBakerSnapshot(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	baker := "tz1aRoaRhSpRYvFdyvgWLL6TGyRoGF51wDjM"
+	tn, _ := time.Parse(time.RFC3339, "2023-09-16T11:53:01Z")
+	snapshot := entity.BakerDelegators{
+		Delegators: []entity.DelegatorState{
+			{
+				Delegator:  "tz1VSUr8wwNhLAzempoch5d6hLRiTh8Cjcjb",
+				Baker:      baker,
+				Since:      tn,
+				SinceLevel: 4000001,
+				LastId:     3034,
+				Amount:     1000034,
+			},
+		},
+		Count: 1,
+		Total: 1000034,
+	}
+	delegators := `[{
+		"delegator":"tz1VSUr8wwNhLAzempoch5d6hLRiTh8Cjcjb",
+		"baker":"tz1aRoaRhSpRYvFdyvgWLL6TGyRoGF51wDjM",
+		"since":"2023-09-16T11:53:01Z",
+		"sinceLevel":4000001,
+		"lastId":3034,
+		"amount":1000034
+	}]`
+
+	t.Run("at_level", func(t *testing.T) {
+		c, w := getAddressTestContext(baker)
+		c.Request.URL.RawQuery = "at=4000001"
+		mu := &mockBakerUsecase{}
+		mu.On("GetBakerSnapshot", c.Request.Context(), entity.BakerSnapshotRequest{
+			Baker: baker,
+			Level: 4000001,
+		}).Return(snapshot, nil)
+
+		GetBakerSnapshot(mu)(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t,
+			`{"data":{"baker":"tz1aRoaRhSpRYvFdyvgWLL6TGyRoGF51wDjM","level":4000001,"count":1,"total":1000034,
+			"delegators":`+delegators+`}}`,
+			w.Body.String(),
+		)
+		mu.AssertExpectations(t)
+	})
+
+	t.Run("at_timestamp", func(t *testing.T) {
+		c, w := getAddressTestContext(baker)
+		c.Request.URL.RawQuery = "at=2023-09-16T13:53:01%2B02:00"
+		mu := &mockBakerUsecase{}
+		mu.On("GetBakerSnapshot", c.Request.Context(), entity.BakerSnapshotRequest{
+			Baker: baker,
+			At:    tn,
+		}).Return(snapshot, nil)
+
+		GetBakerSnapshot(mu)(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t,
+			`{"data":{"baker":"tz1aRoaRhSpRYvFdyvgWLL6TGyRoGF51wDjM","at":"2023-09-16T11:53:01Z","count":1,"total":1000034,
+			"delegators":`+delegators+`}}`,
+			w.Body.String(),
+		)
+		mu.AssertExpectations(t)
+	})
+
+	t.Run("invalid_address", func(t *testing.T) {
+		c, w := getAddressTestContext("baker1")
+		c.Request.URL.RawQuery = "at=4000001"
+		mu := &mockBakerUsecase{}
+
+		GetBakerSnapshot(mu)(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		mu.AssertExpectations(t)
+	})
+
+	t.Run("missing_at", func(t *testing.T) {
+		c, w := getAddressTestContext(baker)
+		mu := &mockBakerUsecase{}
+
+		GetBakerSnapshot(mu)(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		mu.AssertExpectations(t)
+	})
+
+	t.Run("wrong_at", func(t *testing.T) {
+		for _, at := range []string{"0", "-1", "2023-09-16", "yesterday"} {
+			c, w := getAddressTestContext(baker)
+			c.Request.URL.RawQuery = "at=" + at
+			mu := &mockBakerUsecase{}
+
+			GetBakerSnapshot(mu)(c)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, at)
+			mu.AssertExpectations(t)
+		}
+	})
+
+	t.Run("fail_from_uc", func(t *testing.T) {
+		c, w := getAddressTestContext(baker)
+		c.Request.URL.RawQuery = "at=4000001"
+		mu := &mockBakerUsecase{}
+		mu.On("GetBakerSnapshot", c.Request.Context(), entity.BakerSnapshotRequest{
+			Baker: baker,
+			Level: 4000001,
+		}).Return(entity.BakerDelegators{}, errors.New("err"))
+
+		GetBakerSnapshot(mu)(c)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		mu.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/frisk038/tezos-delegation-service/domain/entity"
+)
+
+// errInvalidCursor is returned when a cursor wasn't issued by the API.
+var errInvalidCursor = errors.New("cursor is not valid")
+
+// encodeCursor returns the opaque cursor listing the delegations after the given one.
+// It holds every value the delegations may be sorted by, so that it works whatever the sort of the request.
+func encodeCursor(cur entity.DelegationCursor) string {
+	raw := strconv.FormatInt(cur.TimeStamp.UnixNano(), 10) + "." +
+		strconv.FormatInt(cur.Amount, 10) + "." +
+		strconv.FormatInt(cur.Id, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor reads a cursor returned by encodeCursor.
+func decodeCursor(cursor string) (entity.DelegationCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return entity.DelegationCursor{}, errInvalidCursor
+	}
+
+	parts := strings.Split(string(raw), ".")
+	if len(parts) != 3 {
+		return entity.DelegationCursor{}, errInvalidCursor
+	}
+	values := make([]int64, len(parts))
+	for i, part := range parts {
+		if values[i], err = strconv.ParseInt(part, 10, 64); err != nil {
+			return entity.DelegationCursor{}, errInvalidCursor
+		}
+	}
+	if values[2] <= 0 {
+		return entity.DelegationCursor{}, errInvalidCursor
+	}
+
+	return entity.DelegationCursor{TimeStamp: time.Unix(0, values[0]).UTC(), Amount: values[1], Id: values[2]}, nil
+}
@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/frisk038/tezos-delegation-service/domain/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCursor(t *testing.T) {
+	t.Run("round_trip", func(t *testing.T) {
+		cur := entity.DelegationCursor{
+			TimeStamp: time.Date(2023, 9, 16, 11, 53, 1, 123456000, time.UTC),
+			Amount:    1000034,
+			Id:        3034,
+		}
+
+		got, err := decodeCursor(encodeCursor(cur))
+		assert.NoError(t, err)
+		assert.Equal(t, cur, got)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, cursor := range []string{
+			"not base64!",
+			base64.RawURLEncoding.EncodeToString([]byte("1694865181000000000.3034")),
+			base64.RawURLEncoding.EncodeToString([]byte("yesterday.1000034.3034")),
+			base64.RawURLEncoding.EncodeToString([]byte("1694865181000000000.1000034.id")),
+			base64.RawURLEncoding.EncodeToString([]byte("1694865181000000000.1000034.0")),
+		} {
+			_, err := decodeCursor(cursor)
+			assert.ErrorIs(t, err, errInvalidCursor, cursor)
+		}
+	})
+}
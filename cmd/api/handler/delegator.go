@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/frisk038/tezos-delegation-service/domain/entity"
+	"github.com/gin-gonic/gin"
+)
+
+// addressFormat matches the base58 Tezos addresses of implicit and originated accounts.
+var addressFormat = regexp.MustCompile(`^(tz[1-4]|KT1)[1-9A-HJ-NP-Za-km-z]{33}$`)
+
+// delegatorGetter defines an interface for getting the current delegation of a delegator.
+type delegatorGetter interface {
+	GetDelegator(ctx context.Context, delegator string) (entity.DelegatorState, error)
+}
+
+// delegatorHistoryGetter defines an interface for getting the delegations of a delegator.
+type delegatorHistoryGetter interface {
+	GetDelegatorHistory(ctx context.Context, rq entity.DelegatorHistoryRequest) ([]entity.Delegation, error)
+}
+
+// delegatorJs represents the JSON response format for the current delegation of a delegator.
+type delegatorJs struct {
+	Delegator  string    `json:"delegator"`
+	Baker      string    `json:"baker,omitempty"`
+	Since      time.Time `json:"since"`
+	SinceLevel int64     `json:"sinceLevel,omitempty"`
+	LastId     int64     `json:"lastId"`
+	Amount     int64     `json:"amount"`
+}
+
+// GetDelegator is a Gin HTTP handler that retrieves the current delegation of a delegator.
+// @Summary Get a delegator
+// @Description Retrieve who a delegator is delegating to right now, as of its last applied operation
+// @ID get-delegator
+// @Accept  json
+// @Produce  json
+// @Param address path string true "Address of the delegator"
+// @Success 200 {object} delegatorJs
+// @Failure 400 "Invalid address"
+// @Failure 404 "No delegation stored for this address"
+// @Router /xtz/delegators/{address} [get]
+func GetDelegator(getter delegatorGetter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		address := c.Param("address")
+		if !addressFormat.MatchString(address) {
+			_ = c.AbortWithError(http.StatusBadRequest, errors.New("address is not a valid Tezos address"))
+			return
+		}
+
+		st, err := getter.GetDelegator(c.Request.Context(), address)
+		if errors.Is(err, entity.ErrNotFound) {
+			_ = c.AbortWithError(http.StatusNotFound, err)
+			return
+		}
+		if err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": toDelegatorJs(st)})
+	}
+}
+
+// GetDelegatorHistory is a Gin HTTP handler that retrieves the delegation timeline of a delegator.
+// @Summary Get the delegations of a delegator
+// @Description Retrieve every delegation operation of a delegator, from the oldest to the newest
+// @ID get-delegator-delegations
+// @Accept  json
+// @Produce  json
+// @Param address path string true "Address of the delegator"
+// @Param limit query int false "Limit the number of results (default is 10)"
+// @Param offset query int false "Offset for pagination"
+// @Success 200 {array} delegationJs
+// @Failure 400 "Invalid address or pagination"
+// @Router /xtz/delegators/{address}/delegations [get]
+//
+//goland:noinspection GoPreferNilSlice
+func GetDelegatorHistory(cfg Config, getter delegatorHistoryGetter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		address := c.Param("address")
+		if !addressFormat.MatchString(address) {
+			_ = c.AbortWithError(http.StatusBadRequest, errors.New("address is not a valid Tezos address"))
+			return
+		}
+
+		limit, offset, err := parsePagination(c, cfg)
+		if err != nil {
+			_ = c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+
+		dgs, err := getter.GetDelegatorHistory(c.Request.Context(), entity.DelegatorHistoryRequest{
+			Delegator: address,
+			Limit:     limit,
+			Offset:    offset,
+		})
+		if err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		resp := []delegationJs{}
+		for _, dg := range dgs {
+			resp = append(resp, toDelegationJs(dg))
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": resp})
+	}
+}
+
+// toDelegatorJs maps the current delegation of a delegator onto its JSON response format.
+func toDelegatorJs(st entity.DelegatorState) delegatorJs {
+	return delegatorJs{
+		Delegator:  st.Delegator,
+		Baker:      st.Baker,
+		Since:      st.Since,
+		SinceLevel: st.SinceLevel,
+		LastId:     st.LastId,
+		Amount:     st.Amount,
+	}
+}
@@ -0,0 +1,246 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/frisk038/tezos-delegation-service/domain/entity"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockDelegatorUsecase struct {
+	mock.Mock
+}
+
+func (mu *mockDelegatorUsecase) GetDelegator(ctx context.Context, delegator string) (entity.DelegatorState, error) {
+	called := mu.Called(ctx, delegator)
+	return called.Get(0).(entity.DelegatorState), called.Error(1)
+}
+
+func (mu *mockDelegatorUsecase) GetDelegatorHistory(ctx context.Context, rq entity.DelegatorHistoryRequest) ([]entity.Delegation, error) {
+	called := mu.Called(ctx, rq)
+	return called.Get(0).([]entity.Delegation), called.Error(1)
+}
+
+func getAddressTestContext(address string) (*gin.Context, *httptest.ResponseRecorder) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = &http.Request{
+		Method: "GET",
+		Header: make(http.Header),
+		URL:    &url.URL{},
+	}
+	c.Params = gin.Params{{Key: "address", Value: address}}
+
+	return c, w
+}
+
+func TestGetDelegator(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	address := "tz1VSUr8wwNhLAzempoch5d6hLRiTh8Cjcjb"
+	tn, _ := time.Parse(time.RFC3339, "2023-09-16T11:53:01Z")
+
+	t.Run("success", func(t *testing.T) {
+		c, w := getAddressTestContext(address)
+		mu := &mockDelegatorUsecase{}
+		mu.On("GetDelegator", c.Request.Context(), address).Return(entity.DelegatorState{
+			Delegator:  address,
+			Baker:      "tz1aRoaRhSpRYvFdyvgWLL6TGyRoGF51wDjM",
+			Since:      tn,
+			SinceLevel: 4000001,
+			LastId:     3034,
+			Amount:     1000034,
+		}, nil)
+
+		GetDelegator(mu)(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t,
+			`{
+			"data":{
+				"delegator":"tz1VSUr8wwNhLAzempoch5d6hLRiTh8Cjcjb",
+				"baker":"tz1aRoaRhSpRYvFdyvgWLL6TGyRoGF51wDjM",
+				"since":"2023-09-16T11:53:01Z",
+				"sinceLevel":4000001,
+				"lastId":3034,
+				"amount":1000034
+			}}`,
+			w.Body.String(),
+		)
+		mu.AssertExpectations(t)
+	})
+
+	t.Run("undelegated", func(t *testing.T) {
+		c, w := getAddressTestContext(address)
+		mu := &mockDelegatorUsecase{}
+		mu.On("GetDelegator", c.Request.Context(), address).Return(entity.DelegatorState{
+			Delegator: address,
+			Since:     tn,
+			LastId:    3035,
+			Amount:    1000034,
+		}, nil)
+
+		GetDelegator(mu)(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t,
+			`{
+			"data":{
+				"delegator":"tz1VSUr8wwNhLAzempoch5d6hLRiTh8Cjcjb",
+				"since":"2023-09-16T11:53:01Z",
+				"lastId":3035,
+				"amount":1000034
+			}}`,
+			w.Body.String(),
+		)
+		mu.AssertExpectations(t)
+	})
+
+	t.Run("invalid_address", func(t *testing.T) {
+		c, w := getAddressTestContext("tz1Invalid")
+		mu := &mockDelegatorUsecase{}
+
+		GetDelegator(mu)(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		mu.AssertExpectations(t)
+	})
+
+	t.Run("not_found", func(t *testing.T) {
+		c, w := getAddressTestContext(address)
+		mu := &mockDelegatorUsecase{}
+		mu.On("GetDelegator", c.Request.Context(), address).Return(entity.DelegatorState{}, entity.ErrNotFound)
+
+		GetDelegator(mu)(c)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		mu.AssertExpectations(t)
+	})
+
+	t.Run("fail_from_uc", func(t *testing.T) {
+		c, w := getAddressTestContext(address)
+		mu := &mockDelegatorUsecase{}
+		mu.On("GetDelegator", c.Request.Context(), address).Return(entity.DelegatorState{}, errors.New("err"))
+
+		GetDelegator(mu)(c)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		mu.AssertExpectations(t)
+	})
+}
+
+func TestGetDelegatorHistory(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := Config{
+		MaxLimit:     100,
+		DefaultLimit: 10,
+	}
+	address := "tz1VSUr8wwNhLAzempoch5d6hLRiTh8Cjcjb"
+	tn, _ := time.Parse(time.RFC3339, "2023-09-16T11:53:01Z")
+	dgs := []entity.Delegation{
+		{
+			Amount:    1234,
+			Block:     "block1",
+			Id:        3034,
+			Delegator: address,
+			TimeStamp: tn,
+		},
+		{
+			Amount:    1000034,
+			Block:     "block2",
+			Id:        30004,
+			Delegator: address,
+			TimeStamp: tn.Add(time.Minute),
+		},
+	}
+
+	t.Run("success", func(t *testing.T) {
+		c, w := getAddressTestContext(address)
+		c.Request.URL.RawQuery = "limit=2&offset=4"
+		mu := &mockDelegatorUsecase{}
+		mu.On("GetDelegatorHistory", c.Request.Context(), entity.DelegatorHistoryRequest{
+			Delegator: address,
+			Limit:     2,
+			Offset:    4,
+		}).Return(dgs, nil)
+
+		GetDelegatorHistory(cfg, mu)(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t,
+			`{
+			"data":[{
+					"timestamp":"2023-09-16T11:53:01Z",
+					"amount":1234,
+					"delegator":"tz1VSUr8wwNhLAzempoch5d6hLRiTh8Cjcjb",
+					"block":"block1"
+				},
+				{
+					"timestamp":"2023-09-16T11:54:01Z",
+					"amount":1000034,
+					"delegator":"tz1VSUr8wwNhLAzempoch5d6hLRiTh8Cjcjb",
+					"block":"block2"
+				}]
+			}`,
+			w.Body.String(),
+		)
+		mu.AssertExpectations(t)
+	})
+
+	t.Run("no_delegations", func(t *testing.T) {
+		c, w := getAddressTestContext(address)
+		mu := &mockDelegatorUsecase{}
+		mu.On("GetDelegatorHistory", c.Request.Context(), entity.DelegatorHistoryRequest{
+			Delegator: address,
+			Limit:     10,
+		}).Return([]entity.Delegation(nil), nil)
+
+		GetDelegatorHistory(cfg, mu)(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"data":[]}`, w.Body.String())
+		mu.AssertExpectations(t)
+	})
+
+	t.Run("invalid_address", func(t *testing.T) {
+		c, w := getAddressTestContext("dg1")
+		mu := &mockDelegatorUsecase{}
+
+		GetDelegatorHistory(cfg, mu)(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		mu.AssertExpectations(t)
+	})
+
+	t.Run("limit_over_max", func(t *testing.T) {
+		c, w := getAddressTestContext(address)
+		c.Request.URL.RawQuery = "limit=1000"
+		mu := &mockDelegatorUsecase{}
+
+		GetDelegatorHistory(cfg, mu)(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		mu.AssertExpectations(t)
+	})
+
+	t.Run("fail_from_uc", func(t *testing.T) {
+		c, w := getAddressTestContext(address)
+		mu := &mockDelegatorUsecase{}
+		mu.On("GetDelegatorHistory", c.Request.Context(), entity.DelegatorHistoryRequest{
+			Delegator: address,
+			Limit:     10,
+		}).Return([]entity.Delegation(nil), errors.New("err"))
+
+		GetDelegatorHistory(cfg, mu)(c)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		mu.AssertExpectations(t)
+	})
+}
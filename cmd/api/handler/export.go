@@ -0,0 +1,204 @@
+package handler
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/frisk038/tezos-delegation-service/domain/entity"
+	"github.com/gin-gonic/gin"
+)
+
+// Formats of the export of the delegations.
+const (
+	formatCSV    = "csv"
+	formatNDJSON = "ndjson"
+)
+
+// exportFlushRows is the count of rows after which the export is flushed to the client.
+const exportFlushRows = 1000
+
+// delegationExporter defines an interface for exporting every delegation matching some filters.
+type delegationExporter interface {
+	ExportDelegations(ctx context.Context, drq entity.DelegationRequest, onRow func(dg entity.Delegation) error) error
+}
+
+// csvHeader lists the columns of the CSV export, named after the fields of delegationJs.
+var csvHeader = []string{
+	"timestamp", "amount", "delegator", "block", "hash", "level", "baker",
+	"prevBaker", "status", "bakerFee", "gasUsed", "counter", "kind",
+}
+
+// rowWriter writes the delegations of an export in a given format.
+type rowWriter interface {
+	write(dg entity.Delegation) error
+	flush() error
+}
+
+// csvWriter writes the delegations as CSV rows, preceded by csvHeader.
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func (cw csvWriter) write(dg entity.Delegation) error {
+	js := toDelegationJs(dg)
+	return cw.w.Write([]string{
+		js.TimeStamp.Format(time.RFC3339),
+		strconv.FormatInt(js.Amount, 10),
+		js.Delegator,
+		js.Block,
+		js.Hash,
+		strconv.FormatInt(js.Level, 10),
+		js.Baker,
+		js.PrevBaker,
+		js.Status,
+		strconv.FormatInt(js.BakerFee, 10),
+		strconv.FormatInt(js.GasUsed, 10),
+		strconv.FormatInt(js.Counter, 10),
+		js.Kind,
+	})
+}
+
+func (cw csvWriter) flush() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// ndjsonWriter writes the delegations as one JSON object per line.
+type ndjsonWriter struct {
+	enc *json.Encoder
+}
+
+func (nw ndjsonWriter) write(dg entity.Delegation) error {
+	return nw.enc.Encode(toDelegationJs(dg))
+}
+
+func (nw ndjsonWriter) flush() error {
+	return nil
+}
+
+// GetDelegationsExport is a Gin HTTP handler that exports every delegation matching the filters.
+// @Summary Export delegations
+// @Description Stream every delegation matching the filters, as CSV with a header row or as newline delimited JSON.
+// @Description Delegations are sorted as by get-delegations, without pagination.
+// @Description An error after the first rows cuts the response short, without its final chunk.
+// @ID get-delegations-export
+// @Produce  text/csv
+// @Produce  application/x-ndjson
+// @Param format query string false "Format of the export (default is csv)" Enums(csv, ndjson)
+// @Param sort query string false "Sort by timestamp, amount or id (default is timestamp)" Enums(timestamp, amount, id)
+// @Param order query string false "Order of the sort (default is desc)" Enums(asc, desc)
+// @Param year query int false "Filter by year (optional)"
+// @Param kind query string false "Filter by kind of operation" Enums(delegate, undelegate, re-delegate, failed)
+// @Param status query string false "Filter by status of operation, all for any (default is applied, any with kind failed)" Enums(applied, failed, backtracked, skipped, all)
+// @Param delegator query string false "Filter by address of the delegator"
+// @Param baker query string false "Filter by address of the new baker"
+// @Param block query string false "Filter by hash of the block"
+// @Param level.gte query int false "Filter by minimum level (included)"
+// @Param level.lte query int false "Filter by maximum level (included)"
+// @Param amount.gte query int false "Filter by minimum amount in mutez (included)"
+// @Param amount.lte query int false "Filter by maximum amount in mutez (included)"
+// @Param from query string false "Filter by minimum timestamp, RFC 3339 or date (included)"
+// @Param to query string false "Filter by maximum timestamp, RFC 3339 or date (excluded)"
+// @Success 200 {file} file
+// @Failure 400 "Invalid format or filter"
+// @Router /xtz/delegations/export [get]
+func GetDelegationsExport(exporter delegationExporter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		format := c.DefaultQuery("format", formatCSV)
+		var contentType string
+		var newWriter func(w io.Writer) (rowWriter, error)
+		switch format {
+		case formatCSV:
+			contentType = "text/csv; charset=utf-8"
+			newWriter = func(w io.Writer) (rowWriter, error) {
+				cw := csvWriter{w: csv.NewWriter(w)}
+				return cw, cw.w.Write(csvHeader)
+			}
+		case formatNDJSON:
+			contentType = "application/x-ndjson"
+			newWriter = func(w io.Writer) (rowWriter, error) {
+				return ndjsonWriter{enc: json.NewEncoder(w)}, nil
+			}
+		default:
+			_ = c.AbortWithError(http.StatusBadRequest, errors.New("format must be csv or ndjson"))
+			return
+		}
+
+		drq, err := parseDelegationFilter(c)
+		if err != nil {
+			_ = c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+		if drq.Sort, drq.Order, err = parseDelegationSort(c); err != nil {
+			_ = c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+
+		// The response only starts with the first row, so that a failing query still gets an error status.
+		var rw rowWriter
+		start := func() error {
+			c.Header("Content-Type", contentType)
+			c.Header("Content-Disposition", `attachment; filename="delegations.`+format+`"`)
+			c.Status(http.StatusOK)
+			w, err := newWriter(c.Writer)
+			rw = w
+			return err
+		}
+
+		rows := 0
+		err = exporter.ExportDelegations(c.Request.Context(), drq, func(dg entity.Delegation) error {
+			if rw == nil {
+				if err := start(); err != nil {
+					return err
+				}
+			}
+			if err := rw.write(dg); err != nil {
+				return err
+			}
+			rows++
+			if rows%exportFlushRows == 0 {
+				if err := rw.flush(); err != nil {
+					return err
+				}
+				c.Writer.Flush()
+			}
+			return nil
+		})
+		if err == nil && rw == nil {
+			err = start()
+		}
+		if err == nil {
+			err = rw.flush()
+		}
+		if err != nil {
+			if !c.Writer.Written() {
+				c.Writer.Header().Del("Content-Type")
+				c.Writer.Header().Del("Content-Disposition")
+				_ = c.AbortWithError(http.StatusInternalServerError, err)
+				return
+			}
+			_ = c.Error(err)
+			c.Abort()
+			abortStream(c)
+		}
+	}
+}
+
+// abortStream cuts short a response already started, so that the client can't take it for a complete one.
+// HTTP/1.x responses are chunked, closing the connection leaves them without their final chunk.
+func abortStream(c *gin.Context) {
+	if c.Request.ProtoMajor != 1 {
+		return
+	}
+	conn, _, err := c.Writer.Hijack()
+	if err != nil {
+		return
+	}
+	_ = conn.Close()
+}
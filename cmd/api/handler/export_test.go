@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/frisk038/tezos-delegation-service/domain/entity"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockExportUsecase struct {
+	mock.Mock
+}
+
+// ExportDelegations hands each of the returned delegations to onRow before returning the error, if any.
+func (mu *mockExportUsecase) ExportDelegations(ctx context.Context, drq entity.DelegationRequest, onRow func(dg entity.Delegation) error) error {
+	called := mu.Called(ctx, drq)
+	for _, dg := range called.Get(0).([]entity.Delegation) {
+		if err := onRow(dg); err != nil {
+			return err
+		}
+	}
+	return called.Error(1)
+}
+
+func TestGetDelegationsExport(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ts := time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC)
+	dgs := []entity.Delegation{
+		{TimeStamp: ts, Amount: 1000, Delegator: "tz1Delegator1", Block: "block1", Hash: "op1", Level: 4000001,
+			Baker: "tz1Baker1", Status: entity.StatusApplied, Counter: 12},
+		{TimeStamp: ts.Add(-time.Minute), Amount: 20, Delegator: "tz1Delegator2", Block: "block0", Level: 4000000,
+			PrevBaker: "tz1Baker1", Status: entity.StatusApplied},
+	}
+
+	t.Run("csv", func(t *testing.T) {
+		c, w := getTestContext("GET", "", "", "")
+		c.Request.URL.RawQuery = "baker=tz1aRoaRhSpRYvFdyvgWLL6TGyRoGF51wDjM&sort=amount&order=asc"
+		mu := &mockExportUsecase{}
+		mu.On("ExportDelegations", c.Request.Context(), entity.DelegationRequest{
+			Baker:  "tz1aRoaRhSpRYvFdyvgWLL6TGyRoGF51wDjM",
+			Status: entity.StatusApplied,
+			Sort:   entity.SortAmount,
+			Order:  entity.OrderAsc,
+		}).Return(dgs, nil)
+
+		GetDelegationsExport(mu)(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Equal(t, `attachment; filename="delegations.csv"`, w.Header().Get("Content-Disposition"))
+		assert.Equal(t,
+			"timestamp,amount,delegator,block,hash,level,baker,prevBaker,status,bakerFee,gasUsed,counter,kind\n"+
+				"2023-10-01T12:00:00Z,1000,tz1Delegator1,block1,op1,4000001,tz1Baker1,,applied,0,0,12,delegate\n"+
+				"2023-10-01T11:59:00Z,20,tz1Delegator2,block0,,4000000,,tz1Baker1,applied,0,0,0,undelegate\n",
+			w.Body.String(),
+		)
+		mu.AssertExpectations(t)
+	})
+
+	t.Run("ndjson", func(t *testing.T) {
+		c, w := getTestContext("GET", "", "", "")
+		c.Request.URL.RawQuery = "format=ndjson"
+		mu := &mockExportUsecase{}
+		mu.On("ExportDelegations", c.Request.Context(), entity.DelegationRequest{
+			Status: entity.StatusApplied,
+		}).Return(dgs, nil)
+
+		GetDelegationsExport(mu)(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+		lines := strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n")
+		if assert.Len(t, lines, 2) {
+			assert.JSONEq(t,
+				`{"timestamp":"2023-10-01T12:00:00Z","amount":1000,"delegator":"tz1Delegator1","block":"block1",
+				"hash":"op1","level":4000001,"baker":"tz1Baker1","status":"applied","counter":12,"kind":"delegate"}`,
+				lines[0],
+			)
+			assert.JSONEq(t,
+				`{"timestamp":"2023-10-01T11:59:00Z","amount":20,"delegator":"tz1Delegator2","block":"block0",
+				"level":4000000,"prevBaker":"tz1Baker1","status":"applied","kind":"undelegate"}`,
+				lines[1],
+			)
+		}
+		mu.AssertExpectations(t)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		c, w := getTestContext("GET", "", "", "")
+		c.Request.URL.RawQuery = "delegator=tz1VSUr8wwNhLAzempoch5d6hLRiTh8Cjcjb"
+		mu := &mockExportUsecase{}
+		mu.On("ExportDelegations", c.Request.Context(), entity.DelegationRequest{
+			Delegator: "tz1VSUr8wwNhLAzempoch5d6hLRiTh8Cjcjb",
+			Status:    entity.StatusApplied,
+		}).Return([]entity.Delegation{}, nil)
+
+		GetDelegationsExport(mu)(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, strings.Join(csvHeader, ",")+"\n", w.Body.String())
+		mu.AssertExpectations(t)
+	})
+
+	t.Run("wrong_params", func(t *testing.T) {
+		for _, query := range []string{
+			"format=xml",
+			"level.gte=abc",
+			"from=yesterday",
+			"sort=delegator",
+		} {
+			c, w := getTestContext("GET", "", "", "")
+			c.Request.URL.RawQuery = query
+			mu := &mockExportUsecase{}
+
+			GetDelegationsExport(mu)(c)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, query)
+			mu.AssertNotCalled(t, "ExportDelegations")
+		}
+	})
+
+	t.Run("err_before_rows", func(t *testing.T) {
+		c, w := getTestContext("GET", "", "", "")
+		c.Request.URL.RawQuery = ""
+		mu := &mockExportUsecase{}
+		mu.On("ExportDelegations", c.Request.Context(), entity.DelegationRequest{
+			Status: entity.StatusApplied,
+		}).Return(dgs, errors.New("err"))
+
+		GetDelegationsExport(mu)(c)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Empty(t, w.Header().Get("Content-Disposition"))
+		assert.Empty(t, w.Body.String())
+		mu.AssertExpectations(t)
+	})
+
+	t.Run("err_after_flush", func(t *testing.T) {
+		many := make([]entity.Delegation, exportFlushRows)
+		for i := range many {
+			many[i] = dgs[i%len(dgs)]
+		}
+		mu := &mockExportUsecase{}
+		mu.On("ExportDelegations", mock.Anything, entity.DelegationRequest{
+			Status: entity.StatusApplied,
+		}).Return(many, errors.New("err"))
+		r := gin.New()
+		r.GET("/export", GetDelegationsExport(mu))
+		srv := httptest.NewServer(r)
+		defer srv.Close()
+
+		resp, err := http.Get(srv.URL + "/export?format=ndjson")
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer resp.Body.Close()
+
+		// The rows already flushed are received, but the response is cut short.
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		body, err := io.ReadAll(resp.Body)
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+		assert.Equal(t, exportFlushRows, strings.Count(string(body), "\n"))
+		mu.AssertExpectations(t)
+	})
+}
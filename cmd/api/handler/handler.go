@@ -5,7 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/frisk038/tezos-delegation-service/domain/entity"
@@ -15,108 +17,385 @@ import (
 // delegationGetter defines an interface for getting delegations.
 type delegationGetter interface {
 	GetDelegations(ctx context.Context, drq entity.DelegationRequest) ([]entity.Delegation, error)
+	CountDelegations(ctx context.Context, drq entity.DelegationRequest) (int64, error)
 }
 
 // Config defines configuration parameters for the handler.
 type Config struct {
-	MaxLimit     int `yaml:"max-limit" env:"MAX-LIMIT" env-default:"100"`
-	DefaultLimit int `yaml:"default-limit" env:"DEFAULT-LIMIT" env-default:"10"`
+	MaxLimit        int           `yaml:"max-limit" env:"MAX-LIMIT" env-default:"100"`
+	DefaultLimit    int           `yaml:"default-limit" env:"DEFAULT-LIMIT" env-default:"10"`
+	CountTTL        time.Duration `yaml:"count-ttl" env:"COUNT-TTL" env-default:"30s"`
+	StreamBuffer    int           `yaml:"stream-buffer" env:"STREAM-BUFFER" env-default:"256"`
+	StreamHeartbeat time.Duration `yaml:"stream-heartbeat" env:"STREAM-HEARTBEAT" env-default:"15s"`
 }
 
+// statusAll is the status filter matching operations of any status.
+const statusAll = "all"
+
 // delegationJs represents the JSON response format for delegations.
+// Details of the operation are omitted when unknown, e.g. the baker of an undelegation.
 type delegationJs struct {
 	TimeStamp time.Time `json:"timestamp"`
 	Amount    int64     `json:"amount"`
 	Delegator string    `json:"delegator"`
 	Block     string    `json:"block"`
+	Hash      string    `json:"hash,omitempty"`
+	Level     int64     `json:"level,omitempty"`
+	Baker     string    `json:"baker,omitempty"`
+	PrevBaker string    `json:"prevBaker,omitempty"`
+	Status    string    `json:"status,omitempty"`
+	BakerFee  int64     `json:"bakerFee,omitempty"`
+	GasUsed   int64     `json:"gasUsed,omitempty"`
+	Counter   int64     `json:"counter,omitempty"`
+	Kind      string    `json:"kind,omitempty"`
 }
 
 // GetDelegations is a Gin HTTP handler that retrieves delegations.
 // @Summary Get delegations
-// @Description Retrieve a list of delegations
+// @Description Retrieve a list of delegations, the most recent first unless sorted otherwise, ties being broken by id.
+// @Description A full page comes with a next_cursor, to pass as cursor in order to get the following page.
+// @Description With include_total, a meta object gives the total of the matching delegations along with the pagination.
+// @Description The next and previous pages are linked in a Link header.
 // @ID get-delegations
 // @Accept  json
 // @Produce  json
 // @Param limit query int false "Limit the number of results (default is 10)"
 // @Param offset query int false "Offset for pagination"
+// @Param cursor query string false "Cursor for pagination, next_cursor of the previous page, exclusive with offset"
+// @Param sort query string false "Sort by timestamp, amount or id (default is timestamp)" Enums(timestamp, amount, id)
+// @Param order query string false "Order of the sort (default is desc)" Enums(asc, desc)
+// @Param include_total query bool false "Return the total of the matching delegations in meta"
 // @Param year query int false "Filter by year (optional)"
+// @Param kind query string false "Filter by kind of operation" Enums(delegate, undelegate, re-delegate, failed)
+// @Param status query string false "Filter by status of operation, all for any (default is applied, any with kind failed)" Enums(applied, failed, backtracked, skipped, all)
+// @Param delegator query string false "Filter by address of the delegator"
+// @Param baker query string false "Filter by address of the new baker"
+// @Param block query string false "Filter by hash of the block"
+// @Param level.gte query int false "Filter by minimum level (included)"
+// @Param level.lte query int false "Filter by maximum level (included)"
+// @Param amount.gte query int false "Filter by minimum amount in mutez (included)"
+// @Param amount.lte query int false "Filter by maximum amount in mutez (included)"
+// @Param from query string false "Filter by minimum timestamp, RFC 3339 or date (included)"
+// @Param to query string false "Filter by maximum timestamp, RFC 3339 or date (excluded)"
 // @Success 200 {array} delegationJs
+// @Header 200 {string} Link "Links to the next and previous pages"
+// @Failure 400 "Invalid pagination or filter"
 // @Router /xtz/delegations [get]
 //
 //goland:noinspection GoPreferNilSlice
 func GetDelegations(cfg Config, getter delegationGetter) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var err error
-		var tm time.Time
-		limit := cfg.DefaultLimit
-		offset := 0
-
-		limitRq := c.Query("limit")
-		if len(limitRq) != 0 {
-			limit, err = strconv.Atoi(limitRq)
-			if err != nil {
-				_ = c.AbortWithError(http.StatusBadRequest, errors.New("limit must be numeric"))
+		limit, offset, err := parsePagination(c, cfg)
+		if err != nil {
+			_ = c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+
+		drq, err := parseDelegationFilter(c)
+		if err != nil {
+			_ = c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+		drq.Limit = limit
+		drq.Offset = offset
+		if drq.Sort, drq.Order, err = parseDelegationSort(c); err != nil {
+			_ = c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+
+		cursor := c.Query("cursor")
+		if len(cursor) != 0 {
+			if offset != 0 {
+				_ = c.AbortWithError(http.StatusBadRequest, errors.New("cursor and offset can't be combined"))
 				return
 			}
-			if limit > cfg.MaxLimit || limit < 0 {
-				_ = c.AbortWithError(http.StatusBadRequest, fmt.Errorf("limit must be [0; %d]", cfg.MaxLimit))
+			if drq.After, err = decodeCursor(cursor); err != nil {
+				_ = c.AbortWithError(http.StatusBadRequest, err)
 				return
 			}
 		}
-		offsetRq := c.Query("offset")
-		if len(offsetRq) != 0 {
-			offset, err = strconv.Atoi(offsetRq)
-			if err != nil {
-				_ = c.AbortWithError(http.StatusBadRequest, errors.New("offset must be numeric"))
-				return
-			}
 
-			if offset < 0 {
-				_ = c.AbortWithError(http.StatusBadRequest, errors.New("offset must be positive"))
+		includeTotal := false
+		if includeTotalRq := c.Query("include_total"); len(includeTotalRq) != 0 {
+			if includeTotal, err = strconv.ParseBool(includeTotalRq); err != nil {
+				_ = c.AbortWithError(http.StatusBadRequest, errors.New("include_total must be true or false"))
 				return
 			}
 		}
 
-		yearRq := c.Query("year")
-		if len(yearRq) != 0 {
-			if len(yearRq) != 4 {
-				_ = c.AbortWithError(http.StatusBadRequest, errors.New("year must respect XXXX format"))
-				return
-			}
+		dgs, err := getter.GetDelegations(c.Request.Context(), drq)
+		if err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
 
-			year, err := strconv.Atoi(yearRq)
+		resp := []delegationJs{}
+		for _, dg := range dgs {
+			resp = append(resp, toDelegationJs(dg))
+		}
+
+		body := gin.H{"data": resp}
+		if includeTotal {
+			total, err := getter.CountDelegations(c.Request.Context(), drq)
 			if err != nil {
-				_ = c.AbortWithError(http.StatusBadRequest, errors.New("year is not a valid number"))
+				_ = c.AbortWithError(http.StatusInternalServerError, err)
 				return
 			}
 
-			tm, err = time.Parse(time.DateOnly, fmt.Sprintf("%d-01-01", year))
-			if err != nil {
-				_ = c.AbortWithError(http.StatusBadRequest, fmt.Errorf("can't format correct date with given year %w", err))
-				return
+			meta := gin.H{"total": total, "limit": limit}
+			if len(cursor) != 0 {
+				meta["cursor"] = cursor
+			} else {
+				meta["offset"] = offset
 			}
+			body["meta"] = meta
 		}
 
-		dgs, err := getter.GetDelegations(c.Request.Context(), entity.DelegationRequest{
-			Limit:  limit,
-			Offset: offset,
-			Date:   tm,
-		})
+		// A full page may be followed by more delegations, the cursor lists them.
+		// The next link keeps to the pagination used by the request, only offsets have a previous page.
+		var links []string
+		if len(dgs) != 0 && len(dgs) >= limit {
+			last := dgs[len(dgs)-1]
+			next := encodeCursor(entity.DelegationCursor{TimeStamp: last.TimeStamp, Amount: last.Amount, Id: last.Id})
+			body["next_cursor"] = next
+			if len(cursor) != 0 {
+				links = append(links, pageLink(c, "cursor", next, "next"))
+			} else {
+				links = append(links, pageLink(c, "offset", strconv.Itoa(offset+limit), "next"))
+			}
+		}
+		if offset > 0 {
+			prev := offset - limit
+			if prev < 0 {
+				prev = 0
+			}
+			links = append(links, pageLink(c, "offset", strconv.Itoa(prev), "prev"))
+		}
+		if len(links) != 0 {
+			c.Header("Link", strings.Join(links, ", "))
+		}
+
+		c.JSON(http.StatusOK, body)
+	}
+}
+
+// parseDelegationSort reads the sort and order of the delegations, left empty for the most recent first.
+func parseDelegationSort(c *gin.Context) (string, string, error) {
+	sort := c.Query("sort")
+	switch sort {
+	case "", entity.SortTimestamp, entity.SortAmount, entity.SortId:
+	default:
+		return "", "", errors.New("sort must be timestamp, amount or id")
+	}
+
+	order := c.Query("order")
+	switch order {
+	case "", entity.OrderAsc, entity.OrderDesc:
+	default:
+		return "", "", errors.New("order must be asc or desc")
+	}
+
+	return sort, order, nil
+}
+
+// pageLink returns an RFC 8288 link to the request with the given pagination parameter changed.
+func pageLink(c *gin.Context, key, value, rel string) string {
+	q := c.Request.URL.Query()
+	q.Set(key, value)
+	return fmt.Sprintf(`<%s?%s>; rel="%s"`, c.Request.URL.Path, q.Encode(), rel)
+}
+
+// blockFormat matches the hash of a Tezos block.
+var blockFormat = regexp.MustCompile(`^B[1-9A-HJ-NP-Za-km-z]{50}$`)
+
+// parseDelegationFilter reads the filters of the delegations from the query parameters, the status defaulting to applied
+// unless the kind is failed.
+func parseDelegationFilter(c *gin.Context) (entity.DelegationRequest, error) {
+	var drq entity.DelegationRequest
+	var err error
+
+	yearRq := c.Query("year")
+	if len(yearRq) != 0 {
+		if len(yearRq) != 4 {
+			return drq, errors.New("year must respect XXXX format")
+		}
+
+		year, err := strconv.Atoi(yearRq)
 		if err != nil {
-			_ = c.AbortWithError(http.StatusInternalServerError, err)
-			return
+			return drq, errors.New("year is not a valid number")
 		}
 
-		resp := []delegationJs{}
-		for _, dg := range dgs {
-			resp = append(resp, delegationJs{
-				TimeStamp: dg.TimeStamp,
-				Amount:    dg.Amount,
-				Delegator: dg.Delegator,
-				Block:     dg.Block,
-			})
+		drq.Date, err = time.Parse(time.DateOnly, fmt.Sprintf("%d-01-01", year))
+		if err != nil {
+			return drq, fmt.Errorf("can't format correct date with given year %w", err)
+		}
+	}
+
+	drq.Kind = c.Query("kind")
+	switch drq.Kind {
+	case "", entity.KindDelegate, entity.KindUndelegate, entity.KindRedelegate, entity.KindFailed:
+	default:
+		return drq, errors.New("kind must be delegate, undelegate, re-delegate or failed")
+	}
+
+	drq.Status = c.Query("status")
+	switch drq.Status {
+	case "":
+		// Failed operations are never applied.
+		if drq.Kind != entity.KindFailed {
+			drq.Status = entity.StatusApplied
+		}
+	case entity.StatusApplied:
+		if drq.Kind == entity.KindFailed {
+			return drq, errors.New("kind failed never matches status applied")
 		}
+	case entity.StatusFailed, entity.StatusBacktracked, entity.StatusSkipped:
+	case statusAll:
+		drq.Status = ""
+	default:
+		return drq, errors.New("status must be applied, failed, backtracked, skipped or all")
+	}
+
+	drq.Delegator = c.Query("delegator")
+	if len(drq.Delegator) != 0 && !addressFormat.MatchString(drq.Delegator) {
+		return drq, errors.New("delegator is not a valid Tezos address")
+	}
+	drq.Baker = c.Query("baker")
+	if len(drq.Baker) != 0 && !addressFormat.MatchString(drq.Baker) {
+		return drq, errors.New("baker is not a valid Tezos address")
+	}
+	drq.Block = c.Query("block")
+	if len(drq.Block) != 0 && !blockFormat.MatchString(drq.Block) {
+		return drq, errors.New("block is not a valid block hash")
+	}
+
+	if drq.LevelMin, err = parseLevel(c, "level.gte"); err != nil {
+		return drq, err
+	}
+	if drq.LevelMax, err = parseLevel(c, "level.lte"); err != nil {
+		return drq, err
+	}
+	if drq.LevelMin > 0 && drq.LevelMax > 0 && drq.LevelMin > drq.LevelMax {
+		return drq, errors.New("level.gte must not be greater than level.lte")
+	}
+
+	if drq.AmountMin, err = parseAmount(c, "amount.gte"); err != nil {
+		return drq, err
+	}
+	if drq.AmountMax, err = parseAmount(c, "amount.lte"); err != nil {
+		return drq, err
+	}
+	if drq.AmountMin != nil && drq.AmountMax != nil && *drq.AmountMin > *drq.AmountMax {
+		return drq, errors.New("amount.gte must not be greater than amount.lte")
+	}
+
+	if drq.From, err = parseTimestamp(c, "from"); err != nil {
+		return drq, err
+	}
+	if drq.To, err = parseTimestamp(c, "to"); err != nil {
+		return drq, err
+	}
+	if !drq.From.IsZero() && !drq.To.IsZero() && !drq.From.Before(drq.To) {
+		return drq, errors.New("from must be before to")
+	}
+
+	return drq, nil
+}
+
+// parseLevel reads a level query parameter, zero when it is missing.
+func parseLevel(c *gin.Context, key string) (int64, error) {
+	levelRq := c.Query(key)
+	if len(levelRq) == 0 {
+		return 0, nil
+	}
+
+	level, err := strconv.ParseInt(levelRq, 10, 64)
+	if err != nil || level <= 0 {
+		return 0, fmt.Errorf("%s must be a positive number", key)
+	}
+
+	return level, nil
+}
+
+// parseAmount reads an amount query parameter in mutez, nil when it is missing.
+func parseAmount(c *gin.Context, key string) (*int64, error) {
+	amountRq := c.Query(key)
+	if len(amountRq) == 0 {
+		return nil, nil
+	}
+
+	amount, err := strconv.ParseInt(amountRq, 10, 64)
+	if err != nil || amount < 0 {
+		return nil, fmt.Errorf("%s must be a positive number", key)
+	}
+
+	return &amount, nil
+}
+
+// parseTimestamp reads a timestamp query parameter given in RFC 3339 or as a date, zero when it is missing.
+func parseTimestamp(c *gin.Context, key string) (time.Time, error) {
+	tsRq := c.Query(key)
+	if len(tsRq) == 0 {
+		return time.Time{}, nil
+	}
+
+	if tm, err := time.Parse(time.RFC3339, tsRq); err == nil {
+		return tm.UTC(), nil
+	}
+	tm, err := time.Parse(time.DateOnly, tsRq)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be an RFC 3339 timestamp or a YYYY-MM-DD date", key)
+	}
+
+	return tm, nil
+}
+
+// parsePagination reads the limit and offset query parameters, the limit defaulting to the configured one.
+func parsePagination(c *gin.Context, cfg Config) (int, int, error) {
+	var err error
+	limit := cfg.DefaultLimit
+	offset := 0
+
+	limitRq := c.Query("limit")
+	if len(limitRq) != 0 {
+		limit, err = strconv.Atoi(limitRq)
+		if err != nil {
+			return 0, 0, errors.New("limit must be numeric")
+		}
+		if limit > cfg.MaxLimit || limit < 0 {
+			return 0, 0, fmt.Errorf("limit must be [0; %d]", cfg.MaxLimit)
+		}
+	}
+	offsetRq := c.Query("offset")
+	if len(offsetRq) != 0 {
+		offset, err = strconv.Atoi(offsetRq)
+		if err != nil {
+			return 0, 0, errors.New("offset must be numeric")
+		}
+
+		if offset < 0 {
+			return 0, 0, errors.New("offset must be positive")
+		}
+	}
+
+	return limit, offset, nil
+}
 
-		c.JSON(http.StatusOK, gin.H{"data": resp})
+// toDelegationJs maps a delegation onto its JSON response format.
+func toDelegationJs(dg entity.Delegation) delegationJs {
+	return delegationJs{
+		TimeStamp: dg.TimeStamp,
+		Amount:    dg.Amount,
+		Delegator: dg.Delegator,
+		Block:     dg.Block,
+		Hash:      dg.Hash,
+		Level:     dg.Level,
+		Baker:     dg.Baker,
+		PrevBaker: dg.PrevBaker,
+		Status:    dg.Status,
+		BakerFee:  dg.BakerFee,
+		GasUsed:   dg.GasUsed,
+		Counter:   dg.Counter,
+		Kind:      dg.Kind(),
 	}
 }
@@ -24,6 +24,11 @@ func (mu *mockUsecase) GetDelegations(ctx context.Context, drq entity.Delegation
 	return called.Get(0).([]entity.Delegation), called.Error(1)
 }
 
+func (mu *mockUsecase) CountDelegations(ctx context.Context, drq entity.DelegationRequest) (int64, error) {
+	called := mu.Called(ctx, drq)
+	return called.Get(0).(int64), called.Error(1)
+}
+
 func getTestContext(method, limit, offset, year string) (*gin.Context, *httptest.ResponseRecorder) {
 	w := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(w)
@@ -72,6 +77,7 @@ func TestGetDelegations(t *testing.T) {
 			Limit:  1,
 			Offset: 0,
 			Date:   time.Time{},
+			Status: entity.StatusApplied,
 		}).Return(dgs, nil)
 
 		GetDelegations(cfg, mu)(c)
@@ -90,7 +96,8 @@ func TestGetDelegations(t *testing.T) {
 					"amount":1234,
 					"delegator":"dg1",
 					"block":"block1"
-				}]
+				}],
+			"next_cursor":"MTY5NDg2NTE4MTAwMDAwMDAwMC4xMjM0LjMwMDA0"
 			}`,
 			w.Body.String(),
 		)
@@ -104,6 +111,7 @@ func TestGetDelegations(t *testing.T) {
 			Limit:  10,
 			Offset: 1,
 			Date:   time.Time{},
+			Status: entity.StatusApplied,
 		}).Return(dgs, nil)
 
 		GetDelegations(cfg, mu)(c)
@@ -136,6 +144,7 @@ func TestGetDelegations(t *testing.T) {
 			Limit:  10,
 			Offset: 0,
 			Date:   time.Date(2012, 01, 01, 0, 0, 0, 0, time.UTC),
+			Status: entity.StatusApplied,
 		}).Return(dgs, nil)
 
 		GetDelegations(cfg, mu)(c)
@@ -161,6 +170,344 @@ func TestGetDelegations(t *testing.T) {
 		mu.AssertExpectations(t)
 	})
 
+	t.Run("success_with_operation", func(t *testing.T) {
+		c, w := getTestContext("GET", "", "", "")
+		mu := &mockUsecase{}
+		mu.On("GetDelegations", c.Request.Context(), entity.DelegationRequest{
+			Limit:  10,
+			Offset: 0,
+			Date:   time.Time{},
+			Status: entity.StatusApplied,
+		}).Return([]entity.Delegation{
+			{
+				Amount:    1000034,
+				Block:     "block2",
+				Id:        3034,
+				Delegator: "dg2",
+				TimeStamp: tn,
+				Hash:      "ooHash",
+				Level:     4000001,
+				Baker:     "baker2",
+				PrevBaker: "baker1",
+				Status:    "applied",
+				BakerFee:  380,
+				GasUsed:   1000,
+				Counter:   71829,
+			},
+		}, nil)
+
+		GetDelegations(cfg, mu)(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t,
+			`{
+			"data":[{
+					"timestamp":"2023-09-16T11:53:01Z",
+					"amount":1000034,
+					"delegator":"dg2",
+					"block":"block2",
+					"hash":"ooHash",
+					"level":4000001,
+					"baker":"baker2",
+					"prevBaker":"baker1",
+					"status":"applied",
+					"bakerFee":380,
+					"gasUsed":1000,
+					"counter":71829,
+					"kind":"re-delegate"
+				}]
+			}`,
+			w.Body.String(),
+		)
+		mu.AssertExpectations(t)
+	})
+
+	t.Run("success_with_kind_and_status", func(t *testing.T) {
+		c, w := getTestContext("GET", "", "", "")
+		c.Request.URL.RawQuery += "&kind=undelegate&status=failed"
+		mu := &mockUsecase{}
+		mu.On("GetDelegations", c.Request.Context(), entity.DelegationRequest{
+			Limit:  10,
+			Kind:   entity.KindUndelegate,
+			Status: entity.StatusFailed,
+		}).Return([]entity.Delegation{}, nil)
+
+		GetDelegations(cfg, mu)(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"data":[]}`, w.Body.String())
+		mu.AssertExpectations(t)
+	})
+
+	t.Run("success_with_failed_kind", func(t *testing.T) {
+		c, w := getTestContext("GET", "", "", "")
+		c.Request.URL.RawQuery += "&kind=failed"
+		mu := &mockUsecase{}
+		mu.On("GetDelegations", c.Request.Context(), entity.DelegationRequest{
+			Limit: 10,
+			Kind:  entity.KindFailed,
+		}).Return([]entity.Delegation{}, nil)
+
+		GetDelegations(cfg, mu)(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mu.AssertExpectations(t)
+	})
+
+	t.Run("failed_kind_applied_status", func(t *testing.T) {
+		c, w := getTestContext("GET", "", "", "")
+		c.Request.URL.RawQuery += "&kind=failed&status=applied"
+		mu := &mockUsecase{}
+
+		GetDelegations(cfg, mu)(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		mu.AssertNotCalled(t, "GetDelegations")
+	})
+
+	t.Run("success_with_any_status", func(t *testing.T) {
+		c, w := getTestContext("GET", "", "", "")
+		c.Request.URL.RawQuery += "&status=all"
+		mu := &mockUsecase{}
+		mu.On("GetDelegations", c.Request.Context(), entity.DelegationRequest{
+			Limit: 10,
+		}).Return([]entity.Delegation{}, nil)
+
+		GetDelegations(cfg, mu)(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mu.AssertExpectations(t)
+	})
+
+	t.Run("success_with_cursor", func(t *testing.T) {
+		c, w := getTestContext("GET", "2", "", "")
+		c.Request.URL.RawQuery += "&cursor=MTY5NDg2NTE4MTAwMDAwMDAwMC4xMjM0LjMwMDA0"
+		mu := &mockUsecase{}
+		mu.On("GetDelegations", c.Request.Context(), entity.DelegationRequest{
+			Limit:  2,
+			Status: entity.StatusApplied,
+			After:  entity.DelegationCursor{TimeStamp: tn, Amount: 1234, Id: 30004},
+		}).Return(dgs[:1], nil)
+
+		GetDelegations(cfg, mu)(c)
+
+		// The last page has no next cursor.
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), "next_cursor")
+		mu.AssertExpectations(t)
+	})
+
+	t.Run("cursor_with_offset", func(t *testing.T) {
+		c, w := getTestContext("GET", "", "1", "")
+		c.Request.URL.RawQuery += "&cursor=MTY5NDg2NTE4MTAwMDAwMDAwMC4xMjM0LjMwMDA0"
+		mu := &mockUsecase{}
+		GetDelegations(cfg, mu)(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		mu.AssertExpectations(t)
+	})
+
+	t.Run("wrong_cursor", func(t *testing.T) {
+		c, w := getTestContext("GET", "", "", "")
+		c.Request.URL.RawQuery += "&cursor=3034"
+		mu := &mockUsecase{}
+		GetDelegations(cfg, mu)(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		mu.AssertExpectations(t)
+	})
+
+	t.Run("success_with_total_and_links", func(t *testing.T) {
+		c, w := getTestContext("GET", "2", "3", "")
+		c.Request.URL.Path = "/xtz/delegations"
+		c.Request.URL.RawQuery += "&include_total=true&kind=delegate"
+		drq := entity.DelegationRequest{
+			Limit:  2,
+			Offset: 3,
+			Kind:   entity.KindDelegate,
+			Status: entity.StatusApplied,
+		}
+		mu := &mockUsecase{}
+		mu.On("GetDelegations", c.Request.Context(), drq).Return(dgs, nil)
+		mu.On("CountDelegations", c.Request.Context(), drq).Return(int64(12), nil)
+
+		GetDelegations(cfg, mu)(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"meta":{"limit":2,"offset":3,"total":12}`)
+		assert.Equal(t,
+			`</xtz/delegations?include_total=true&kind=delegate&limit=2&offset=5&year=>; rel="next", `+
+				`</xtz/delegations?include_total=true&kind=delegate&limit=2&offset=1&year=>; rel="prev"`,
+			w.Header().Get("Link"))
+		mu.AssertExpectations(t)
+	})
+
+	t.Run("success_with_total_and_cursor", func(t *testing.T) {
+		c, w := getTestContext("GET", "2", "", "")
+		c.Request.URL.Path = "/xtz/delegations"
+		c.Request.URL.RawQuery = "limit=2&include_total=1&cursor=MTY5NDg2NTE4MTAwMDAwMDAwMC4xMjM0LjMwMDA0"
+		drq := entity.DelegationRequest{
+			Limit:  2,
+			Status: entity.StatusApplied,
+			After:  entity.DelegationCursor{TimeStamp: tn, Amount: 1234, Id: 30004},
+		}
+		mu := &mockUsecase{}
+		mu.On("GetDelegations", c.Request.Context(), drq).Return(dgs, nil)
+		mu.On("CountDelegations", c.Request.Context(), drq).Return(int64(12), nil)
+
+		GetDelegations(cfg, mu)(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"meta":{"cursor":"MTY5NDg2NTE4MTAwMDAwMDAwMC4xMjM0LjMwMDA0","limit":2,"total":12}`)
+		assert.Equal(t, `</xtz/delegations?cursor=MTY5NDg2NTE4MTAwMDAwMDAwMC4xMjM0LjMwMDA0&include_total=1&limit=2>; rel="next"`, w.Header().Get("Link"))
+		mu.AssertExpectations(t)
+	})
+
+	t.Run("last_page_without_links", func(t *testing.T) {
+		c, w := getTestContext("GET", "", "", "")
+		mu := &mockUsecase{}
+		mu.On("GetDelegations", c.Request.Context(), entity.DelegationRequest{
+			Limit:  10,
+			Status: entity.StatusApplied,
+		}).Return(dgs, nil)
+
+		GetDelegations(cfg, mu)(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), "meta")
+		assert.Empty(t, w.Header().Get("Link"))
+		mu.AssertExpectations(t)
+	})
+
+	t.Run("wrong_include_total", func(t *testing.T) {
+		c, w := getTestContext("GET", "", "", "")
+		c.Request.URL.RawQuery += "&include_total=yes"
+		mu := &mockUsecase{}
+		GetDelegations(cfg, mu)(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		mu.AssertExpectations(t)
+	})
+
+	t.Run("fail_from_count", func(t *testing.T) {
+		c, w := getTestContext("GET", "", "", "")
+		c.Request.URL.RawQuery += "&include_total=true"
+		drq := entity.DelegationRequest{
+			Limit:  10,
+			Status: entity.StatusApplied,
+		}
+		mu := &mockUsecase{}
+		mu.On("GetDelegations", c.Request.Context(), drq).Return(dgs, nil)
+		mu.On("CountDelegations", c.Request.Context(), drq).Return(int64(0), errors.New("err"))
+
+		GetDelegations(cfg, mu)(c)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		mu.AssertExpectations(t)
+	})
+
+	t.Run("success_with_filters", func(t *testing.T) {
+		c, w := getTestContext("GET", "", "", "")
+		c.Request.URL.RawQuery += "&delegator=tz1VSUr8wwNhLAzempoch5d6hLRiTh8Cjcjb&baker=tz1aRoaRhSpRYvFdyvgWLL6TGyRoGF51wDjM" +
+			"&block=BLockGenesisGenesisGenesisGenesisGenesisb83baZgbyZe&level.gte=4000000&level.lte=4000100" +
+			"&amount.gte=0&amount.lte=5000&from=2023-09-16T13:53:01%2B02:00&to=2023-09-17"
+		amountMin, amountMax := int64(0), int64(5000)
+		to, _ := time.Parse(time.DateOnly, "2023-09-17")
+		mu := &mockUsecase{}
+		mu.On("GetDelegations", c.Request.Context(), entity.DelegationRequest{
+			Limit:     10,
+			Status:    entity.StatusApplied,
+			Delegator: "tz1VSUr8wwNhLAzempoch5d6hLRiTh8Cjcjb",
+			Baker:     "tz1aRoaRhSpRYvFdyvgWLL6TGyRoGF51wDjM",
+			Block:     "BLockGenesisGenesisGenesisGenesisGenesisb83baZgbyZe",
+			LevelMin:  4000000,
+			LevelMax:  4000100,
+			AmountMin: &amountMin,
+			AmountMax: &amountMax,
+			From:      tn,
+			To:        to,
+		}).Return([]entity.Delegation{}, nil)
+
+		GetDelegations(cfg, mu)(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mu.AssertExpectations(t)
+	})
+
+	t.Run("wrong_filters", func(t *testing.T) {
+		for _, query := range []string{
+			"delegator=dg1",
+			"baker=tz1aRoaRhSpRYvFdyvgWLL6TGyRoGF51wDj",
+			"block=block1",
+			"level.gte=0",
+			"level.lte=high",
+			"level.gte=4000100&level.lte=4000000",
+			"amount.gte=-1",
+			"amount.lte=1.5",
+			"amount.gte=5000&amount.lte=1000",
+			"from=yesterday",
+			"to=2023-09-17T00:00:00",
+			"from=2023-09-17&to=2023-09-17",
+		} {
+			c, w := getTestContext("GET", "", "", "")
+			c.Request.URL.RawQuery += "&" + query
+			mu := &mockUsecase{}
+			GetDelegations(cfg, mu)(c)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, query)
+			mu.AssertExpectations(t)
+		}
+	})
+
+	t.Run("success_with_sort", func(t *testing.T) {
+		c, w := getTestContext("GET", "", "", "")
+		c.Request.URL.RawQuery += "&sort=amount&order=asc"
+		mu := &mockUsecase{}
+		mu.On("GetDelegations", c.Request.Context(), entity.DelegationRequest{
+			Limit:  10,
+			Status: entity.StatusApplied,
+			Sort:   entity.SortAmount,
+			Order:  entity.OrderAsc,
+		}).Return([]entity.Delegation{}, nil)
+
+		GetDelegations(cfg, mu)(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mu.AssertExpectations(t)
+	})
+
+	t.Run("wrong_sort", func(t *testing.T) {
+		for _, query := range []string{"sort=delegator", "order=up", "sort=amount&order=ASC"} {
+			c, w := getTestContext("GET", "", "", "")
+			c.Request.URL.RawQuery += "&" + query
+			mu := &mockUsecase{}
+			GetDelegations(cfg, mu)(c)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, query)
+			mu.AssertExpectations(t)
+		}
+	})
+
+	t.Run("wrong_kind", func(t *testing.T) {
+		c, w := getTestContext("GET", "", "", "")
+		c.Request.URL.RawQuery += "&kind=transfer"
+		mu := &mockUsecase{}
+		GetDelegations(cfg, mu)(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		mu.AssertExpectations(t)
+	})
+
+	t.Run("wrong_status", func(t *testing.T) {
+		c, w := getTestContext("GET", "", "", "")
+		c.Request.URL.RawQuery += "&status=pending"
+		mu := &mockUsecase{}
+		GetDelegations(cfg, mu)(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		mu.AssertExpectations(t)
+	})
+
 	t.Run("wrong_limit_format", func(t *testing.T) {
 		c, w := getTestContext("GET", "1s", "", "")
 		mu := &mockUsecase{}
@@ -240,6 +587,7 @@ func TestGetDelegations(t *testing.T) {
 			Limit:  2,
 			Offset: 0,
 			Date:   time.Time{},
+			Status: entity.StatusApplied,
 		}).Return([]entity.Delegation(nil), errors.New("err"))
 
 		GetDelegations(cfg, mu)(c)
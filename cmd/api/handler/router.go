@@ -2,6 +2,8 @@ package handler
 
 import (
 	"github.com/frisk038/tezos-delegation-service/domain/usecase/delegation"
+	"github.com/frisk038/tezos-delegation-service/domain/usecase/webhook"
+	"github.com/frisk038/tezos-delegation-service/infrastructure/adapter/broker"
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
@@ -9,10 +11,21 @@ import (
 
 // Init initializes the Gin HTTP router and sets up the routes.
 // It returns a Gin Engine instance that can be used to run the API server.
-func Init(cfg Config, dgUC *delegation.UseCase) *gin.Engine {
+func Init(cfg Config, dgUC *delegation.UseCase, br *broker.Broker, whUC *webhook.UseCase) *gin.Engine {
 	r := gin.Default()
 
 	r.GET("/xtz/delegations", GetDelegations(cfg, dgUC))
+	r.GET("/xtz/delegations/stats", GetStats(dgUC))
+	r.GET("/xtz/delegations/export", GetDelegationsExport(dgUC))
+	r.GET("/xtz/delegations/stream", GetDelegationsStream(cfg, dgUC, br))
+	r.GET("/xtz/delegators/:address", GetDelegator(dgUC))
+	r.GET("/xtz/delegators/:address/delegations", GetDelegatorHistory(cfg, dgUC))
+	r.GET("/xtz/bakers/:address/delegators", GetBakerDelegators(cfg, dgUC))
+	r.GET("/xtz/bakers/:address/snapshot", GetBakerSnapshot(dgUC))
+	r.POST("/xtz/webhooks", CreateSubscription(whUC))
+	r.GET("/xtz/webhooks", GetSubscriptions(whUC))
+	r.DELETE("/xtz/webhooks/:id", DeleteSubscription(whUC))
+	r.GET("/xtz/webhooks/:id/deliveries", GetDeliveries(cfg, whUC))
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
 	return r
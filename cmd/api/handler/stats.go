@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/frisk038/tezos-delegation-service/domain/entity"
+	"github.com/gin-gonic/gin"
+)
+
+// statsGetter defines an interface for getting the aggregates of the delegations by period.
+type statsGetter interface {
+	GetStats(ctx context.Context, rq entity.StatsRequest) ([]entity.DelegationStats, error)
+}
+
+// statsJs represents the JSON response format for the aggregate of the delegations of a period.
+type statsJs struct {
+	Bucket     time.Time `json:"bucket"`
+	Count      int64     `json:"count"`
+	Delegators int64     `json:"delegators"`
+	Total      int64     `json:"total"`
+	Median     int64     `json:"median"`
+}
+
+// GetStats is a Gin HTTP handler that retrieves the aggregates of the applied delegations by period.
+// @Summary Get statistics of the delegations
+// @Description Aggregate the applied delegations by period: count of operations, of distinct delegators,
+// @Description total and median amount. Periods are listed oldest first and left out when without delegation.
+// @Description They are read from a daily rollup refreshed along with the stored delegations, so the time range is
+// @Description applied by day.
+// @ID get-delegation-stats
+// @Accept  json
+// @Produce  json
+// @Param bucket query string false "Period of the aggregates, weeks start on Monday (default is day)" Enums(day, week, month, year)
+// @Param from query string false "Filter by minimum day, YYYY-MM-DD or RFC 3339 at midnight UTC (included)"
+// @Param to query string false "Filter by maximum day, YYYY-MM-DD or RFC 3339 at midnight UTC (excluded)"
+// @Success 200 {array} statsJs
+// @Failure 400 "Invalid bucket or time range"
+// @Router /xtz/delegations/stats [get]
+//
+//goland:noinspection GoPreferNilSlice
+func GetStats(getter statsGetter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var err error
+		rq := entity.StatsRequest{Bucket: c.Query("bucket")}
+		switch rq.Bucket {
+		case "":
+			rq.Bucket = entity.BucketDay
+		case entity.BucketDay, entity.BucketWeek, entity.BucketMonth, entity.BucketYear:
+		default:
+			_ = c.AbortWithError(http.StatusBadRequest, errors.New("bucket must be day, week, month or year"))
+			return
+		}
+
+		if rq.From, err = parseTimestamp(c, "from"); err != nil {
+			_ = c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+		if rq.To, err = parseTimestamp(c, "to"); err != nil {
+			_ = c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+		// Statistics are stored by day, a range starting within a day would be silently widened.
+		if !rq.From.Equal(rq.From.Truncate(24 * time.Hour)) {
+			_ = c.AbortWithError(http.StatusBadRequest, errors.New("from must be a day, statistics are computed by day"))
+			return
+		}
+		if !rq.To.Equal(rq.To.Truncate(24 * time.Hour)) {
+			_ = c.AbortWithError(http.StatusBadRequest, errors.New("to must be a day, statistics are computed by day"))
+			return
+		}
+		if !rq.From.IsZero() && !rq.To.IsZero() && !rq.From.Before(rq.To) {
+			_ = c.AbortWithError(http.StatusBadRequest, errors.New("from must be before to"))
+			return
+		}
+
+		stats, err := getter.GetStats(c.Request.Context(), rq)
+		if err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		resp := []statsJs{}
+		for _, st := range stats {
+			resp = append(resp, statsJs{
+				Bucket:     st.Bucket,
+				Count:      st.Count,
+				Delegators: st.Delegators,
+				Total:      st.Total,
+				Median:     st.Median,
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": resp})
+	}
+}
@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/frisk038/tezos-delegation-service/domain/entity"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockStatsUsecase struct {
+	mock.Mock
+}
+
+func (mu *mockStatsUsecase) GetStats(ctx context.Context, rq entity.StatsRequest) ([]entity.DelegationStats, error) {
+	called := mu.Called(ctx, rq)
+	return called.Get(0).([]entity.DelegationStats), called.Error(1)
+}
+
+func TestGetStats(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	month := time.Date(2023, 9, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("success", func(t *testing.T) {
+		c, w := getTestContext("GET", "", "", "")
+		c.Request.URL.RawQuery = "bucket=month&from=2023-09-01&to=2023-11-01T00:00:00Z"
+		mu := &mockStatsUsecase{}
+		mu.On("GetStats", c.Request.Context(), entity.StatsRequest{
+			Bucket: entity.BucketMonth,
+			From:   month,
+			To:     month.AddDate(0, 2, 0),
+		}).Return([]entity.DelegationStats{
+			{Bucket: month, Count: 3, Delegators: 2, Total: 1002268, Median: 1000},
+			{Bucket: month.AddDate(0, 1, 0), Count: 1, Delegators: 1, Total: 1234, Median: 1234},
+		}, nil)
+
+		GetStats(mu)(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t,
+			`{"data":[
+				{"bucket":"2023-09-01T00:00:00Z","count":3,"delegators":2,"total":1002268,"median":1000},
+				{"bucket":"2023-10-01T00:00:00Z","count":1,"delegators":1,"total":1234,"median":1234}
+			]}`,
+			w.Body.String(),
+		)
+		mu.AssertExpectations(t)
+	})
+
+	t.Run("default_bucket", func(t *testing.T) {
+		c, w := getTestContext("GET", "", "", "")
+		c.Request.URL.RawQuery = ""
+		mu := &mockStatsUsecase{}
+		mu.On("GetStats", c.Request.Context(), entity.StatsRequest{
+			Bucket: entity.BucketDay,
+		}).Return([]entity.DelegationStats{}, nil)
+
+		GetStats(mu)(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"data":[]}`, w.Body.String())
+		mu.AssertExpectations(t)
+	})
+
+	t.Run("wrong_params", func(t *testing.T) {
+		for _, query := range []string{
+			"bucket=hour",
+			"from=yesterday",
+			"to=2023-13-01",
+			"from=2023-10-01&to=2023-09-01",
+			"from=2023-09-01T12:00:00Z",
+			"to=2023-10-01T00:00:00%2B02:00",
+		} {
+			c, w := getTestContext("GET", "", "", "")
+			c.Request.URL.RawQuery = query
+			mu := &mockStatsUsecase{}
+
+			GetStats(mu)(c)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, query)
+			mu.AssertExpectations(t)
+		}
+	})
+
+	t.Run("fail_from_uc", func(t *testing.T) {
+		c, w := getTestContext("GET", "", "", "")
+		c.Request.URL.RawQuery = "bucket=year"
+		mu := &mockStatsUsecase{}
+		mu.On("GetStats", c.Request.Context(), entity.StatsRequest{
+			Bucket: entity.BucketYear,
+		}).Return([]entity.DelegationStats(nil), errors.New("err"))
+
+		GetStats(mu)(c)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		mu.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,181 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/frisk038/tezos-delegation-service/domain/entity"
+	"github.com/gin-gonic/gin"
+)
+
+// streamGetter defines an interface for getting the stored delegations missed by a stream.
+type streamGetter interface {
+	GetDelegations(ctx context.Context, drq entity.DelegationRequest) ([]entity.Delegation, error)
+}
+
+// delegationSubscriber defines an interface for receiving the delegations as soon as they are stored or removed.
+type delegationSubscriber interface {
+	Subscribe(f entity.StreamFilter) (<-chan entity.StreamEvent, func())
+}
+
+// GetDelegationsStream is a Gin HTTP handler that pushes the delegations as Server-Sent Events as soon as they are stored.
+// @Summary Stream delegations
+// @Description Push each applied delegation matching the filters as soon as it is stored, as a Server-Sent Event
+// @Description named delegation, whose id is the id of the operation and data the delegation.
+// @Description With a Last-Event-ID header, the delegations stored after that operation are sent first.
+// @Description The stream is closed if the client falls behind, it resumes from the last event id on reconnection.
+// @Description A delegation removed by a chain reorganization is sent as an event named removed, without id, whose data
+// @Description is the delegation as it was stored. If it is stored back, e.g. in another block, it is sent again with
+// @Description its id. Removals are not replayed on reconnection.
+// @ID get-delegations-stream
+// @Produce  text/event-stream
+// @Param Last-Event-ID header int false "Id of the last operation received, to resume the stream after it"
+// @Param delegator query string false "Filter by address of the delegator"
+// @Param baker query string false "Filter by address of the new baker"
+// @Param amount.gte query int false "Filter by minimum amount in mutez (included)"
+// @Success 200 {object} delegationJs "Events of the delegations"
+// @Failure 400 "Invalid filter or Last-Event-ID"
+// @Router /xtz/delegations/stream [get]
+func GetDelegationsStream(cfg Config, getter streamGetter, sub delegationSubscriber) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		f := entity.StreamFilter{Delegator: c.Query("delegator"), Baker: c.Query("baker")}
+		if len(f.Delegator) != 0 && !addressFormat.MatchString(f.Delegator) {
+			_ = c.AbortWithError(http.StatusBadRequest, errors.New("delegator is not a valid Tezos address"))
+			return
+		}
+		if len(f.Baker) != 0 && !addressFormat.MatchString(f.Baker) {
+			_ = c.AbortWithError(http.StatusBadRequest, errors.New("baker is not a valid Tezos address"))
+			return
+		}
+		amountMin, err := parseAmount(c, "amount.gte")
+		if err != nil {
+			_ = c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+		if amountMin != nil {
+			f.AmountMin = *amountMin
+		}
+
+		var lastId int64
+		if lastEventId := c.GetHeader("Last-Event-ID"); len(lastEventId) != 0 {
+			lastId, err = strconv.ParseInt(lastEventId, 10, 64)
+			if err != nil || lastId < 0 {
+				_ = c.AbortWithError(http.StatusBadRequest, errors.New("Last-Event-ID must be the id of an operation"))
+				return
+			}
+		}
+
+		// Subscribed before the missed delegations are read, so that none stored meanwhile is lost.
+		ch, cancel := sub.Subscribe(f)
+		defer cancel()
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("X-Accel-Buffering", "no")
+		c.Status(http.StatusOK)
+		c.Writer.Flush()
+
+		// On failure the stream ends, the client resumes from the last event it received.
+		if lastId != 0 {
+			if lastId, err = replayDelegations(c, cfg, getter, f, lastId); err != nil {
+				_ = c.Error(err)
+				return
+			}
+		}
+
+		// Ids sent then removed, sent again if stored back.
+		removed := map[int64]struct{}{}
+		heartbeat := time.NewTicker(cfg.StreamHeartbeat)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case <-c.Request.Context().Done():
+				return
+			case ev, ok := <-ch:
+				if !ok {
+					// Fallen behind the poller.
+					return
+				}
+				id := ev.Delegation.Id
+				switch {
+				case ev.Removed:
+					if id <= lastId {
+						removed[id] = struct{}{}
+					}
+				case id <= lastId:
+					if _, ok = removed[id]; !ok {
+						continue
+					}
+					delete(removed, id)
+				default:
+					lastId = id
+				}
+				if err = writeEvent(c.Writer, ev); err != nil {
+					return
+				}
+			case <-heartbeat.C:
+				// A comment keeps proxies from closing an idle stream.
+				if _, err = io.WriteString(c.Writer, ": ping\n\n"); err != nil {
+					return
+				}
+			}
+			c.Writer.Flush()
+		}
+	}
+}
+
+// replayDelegations sends the stored delegations matching the filter with an id above lastId, in id order,
+// and returns the id of the last one sent.
+func replayDelegations(c *gin.Context, cfg Config, getter streamGetter, f entity.StreamFilter, lastId int64) (int64, error) {
+	drq := entity.DelegationRequest{
+		Limit:     cfg.MaxLimit,
+		Status:    entity.StatusApplied,
+		Delegator: f.Delegator,
+		Baker:     f.Baker,
+		Sort:      entity.SortId,
+		Order:     entity.OrderAsc,
+	}
+	if f.AmountMin != 0 {
+		drq.AmountMin = &f.AmountMin
+	}
+
+	for {
+		drq.After = entity.DelegationCursor{Id: lastId}
+		dgs, err := getter.GetDelegations(c.Request.Context(), drq)
+		if err != nil {
+			return lastId, err
+		}
+		for _, dg := range dgs {
+			if err = writeEvent(c.Writer, entity.StreamEvent{Delegation: dg}); err != nil {
+				return lastId, err
+			}
+			lastId = dg.Id
+		}
+		c.Writer.Flush()
+
+		if len(dgs) < drq.Limit {
+			return lastId, nil
+		}
+	}
+}
+
+// writeEvent writes the event as a Server-Sent Event, a stored delegation being identified by its operation id.
+// A removal has no id, so that the client still resumes after the last delegation it received.
+func writeEvent(w io.Writer, ev entity.StreamEvent) error {
+	data, err := json.Marshal(toDelegationJs(ev.Delegation))
+	if err != nil {
+		return err
+	}
+	if ev.Removed {
+		_, err = fmt.Fprintf(w, "event: removed\ndata: %s\n\n", data)
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: delegation\ndata: %s\n\n", ev.Delegation.Id, data)
+	return err
+}
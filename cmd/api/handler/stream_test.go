@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/frisk038/tezos-delegation-service/domain/entity"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockStreamUsecase struct {
+	mock.Mock
+}
+
+func (mu *mockStreamUsecase) GetDelegations(ctx context.Context, drq entity.DelegationRequest) ([]entity.Delegation, error) {
+	called := mu.Called(ctx, drq)
+	return called.Get(0).([]entity.Delegation), called.Error(1)
+}
+
+// fakeSubscriber hands out a channel holding the events of the given delegations, then the given events,
+// closed as if the stream had fallen behind.
+type fakeSubscriber struct {
+	dgs       []entity.Delegation
+	events    []entity.StreamEvent
+	filter    entity.StreamFilter
+	cancelled bool
+}
+
+func (fs *fakeSubscriber) Subscribe(f entity.StreamFilter) (<-chan entity.StreamEvent, func()) {
+	fs.filter = f
+	ch := make(chan entity.StreamEvent, len(fs.dgs)+len(fs.events))
+	for _, dg := range fs.dgs {
+		ch <- entity.StreamEvent{Delegation: dg}
+	}
+	for _, ev := range fs.events {
+		ch <- ev
+	}
+	close(ch)
+	return ch, func() { fs.cancelled = true }
+}
+
+func TestGetDelegationsStream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := Config{MaxLimit: 2, StreamHeartbeat: time.Hour}
+	ts := time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC)
+	dgs := []entity.Delegation{
+		{TimeStamp: ts, Amount: 1000, Delegator: "tz1Delegator1", Block: "block1", Id: 3033, Baker: "tz1Baker1"},
+		{TimeStamp: ts, Amount: 20, Delegator: "tz1Delegator2", Block: "block1", Id: 3034, Baker: "tz1Baker1"},
+		{TimeStamp: ts, Amount: 500, Delegator: "tz1Delegator3", Block: "block2", Id: 3035, Baker: "tz1Baker1"},
+	}
+	events := []string{
+		"id: 3033\nevent: delegation\ndata: " +
+			`{"timestamp":"2023-10-01T12:00:00Z","amount":1000,"delegator":"tz1Delegator1","block":"block1","baker":"tz1Baker1"}` +
+			"\n\n",
+		"id: 3034\nevent: delegation\ndata: " +
+			`{"timestamp":"2023-10-01T12:00:00Z","amount":20,"delegator":"tz1Delegator2","block":"block1","baker":"tz1Baker1"}` +
+			"\n\n",
+		"id: 3035\nevent: delegation\ndata: " +
+			`{"timestamp":"2023-10-01T12:00:00Z","amount":500,"delegator":"tz1Delegator3","block":"block2","baker":"tz1Baker1"}` +
+			"\n\n",
+	}
+
+	t.Run("live", func(t *testing.T) {
+		c, w := getTestContext("GET", "", "", "")
+		c.Request.URL.RawQuery = "baker=tz1aRoaRhSpRYvFdyvgWLL6TGyRoGF51wDjM&amount.gte=20"
+		mu := &mockStreamUsecase{}
+		fs := &fakeSubscriber{dgs: dgs}
+
+		GetDelegationsStream(cfg, mu, fs)(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+		assert.Equal(t, events[0]+events[1]+events[2], w.Body.String())
+		assert.Equal(t, entity.StreamFilter{Baker: "tz1aRoaRhSpRYvFdyvgWLL6TGyRoGF51wDjM", AmountMin: 20}, fs.filter)
+		assert.True(t, fs.cancelled)
+		mu.AssertNotCalled(t, "GetDelegations")
+	})
+
+	t.Run("resume", func(t *testing.T) {
+		c, w := getTestContext("GET", "", "", "")
+		c.Request.URL.RawQuery = "delegator=tz1VSUr8wwNhLAzempoch5d6hLRiTh8Cjcjb"
+		c.Request.Header.Set("Last-Event-ID", "3000")
+		mu := &mockStreamUsecase{}
+		drq := entity.DelegationRequest{
+			Limit:     2,
+			Status:    entity.StatusApplied,
+			Delegator: "tz1VSUr8wwNhLAzempoch5d6hLRiTh8Cjcjb",
+			Sort:      entity.SortId,
+			Order:     entity.OrderAsc,
+			After:     entity.DelegationCursor{Id: 3000},
+		}
+		mu.On("GetDelegations", c.Request.Context(), drq).Return(dgs[:2], nil)
+		drq.After.Id = 3034
+		mu.On("GetDelegations", c.Request.Context(), drq).Return(dgs[2:], nil)
+		// Published while the missed delegations were read, they are not sent twice.
+		fs := &fakeSubscriber{dgs: dgs[1:]}
+
+		GetDelegationsStream(cfg, mu, fs)(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, events[0]+events[1]+events[2], w.Body.String())
+		mu.AssertExpectations(t)
+	})
+
+	t.Run("removed", func(t *testing.T) {
+		c, w := getTestContext("GET", "", "", "")
+		mu := &mockStreamUsecase{}
+		moved := dgs[1]
+		moved.Block = "block2"
+		fs := &fakeSubscriber{dgs: dgs[:2], events: []entity.StreamEvent{
+			{Delegation: dgs[1], Removed: true},
+			// Stored back in another block, along with one which was never removed.
+			{Delegation: dgs[0]},
+			{Delegation: moved},
+		}}
+
+		GetDelegationsStream(cfg, mu, fs)(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, events[0]+events[1]+
+			"event: removed\ndata: "+
+			`{"timestamp":"2023-10-01T12:00:00Z","amount":20,"delegator":"tz1Delegator2","block":"block1","baker":"tz1Baker1"}`+
+			"\n\n"+
+			"id: 3034\nevent: delegation\ndata: "+
+			`{"timestamp":"2023-10-01T12:00:00Z","amount":20,"delegator":"tz1Delegator2","block":"block2","baker":"tz1Baker1"}`+
+			"\n\n",
+			w.Body.String())
+	})
+
+	t.Run("resume_err", func(t *testing.T) {
+		c, w := getTestContext("GET", "", "", "")
+		c.Request.URL.RawQuery = ""
+		c.Request.Header.Set("Last-Event-ID", "3000")
+		mu := &mockStreamUsecase{}
+		mu.On("GetDelegations", c.Request.Context(), mock.Anything).Return([]entity.Delegation(nil), errors.New("err"))
+		fs := &fakeSubscriber{dgs: dgs}
+
+		GetDelegationsStream(cfg, mu, fs)(c)
+
+		// The stream ends, the client tries again from the same event.
+		assert.Empty(t, w.Body.String())
+		assert.True(t, fs.cancelled)
+		mu.AssertExpectations(t)
+	})
+
+	t.Run("wrong_params", func(t *testing.T) {
+		for _, tc := range []struct {
+			query, lastEventId string
+		}{
+			{query: "delegator=dg1"},
+			{query: "baker=tz1aRoaRhSpRYvFdyvgWLL6TGyRoGF51wDj"},
+			{query: "amount.gte=abc"},
+			{lastEventId: "abc"},
+			{lastEventId: "-1"},
+		} {
+			c, w := getTestContext("GET", "", "", "")
+			c.Request.URL.RawQuery = tc.query
+			if len(tc.lastEventId) != 0 {
+				c.Request.Header.Set("Last-Event-ID", tc.lastEventId)
+			}
+			fs := &fakeSubscriber{}
+
+			GetDelegationsStream(cfg, &mockStreamUsecase{}, fs)(c)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, tc)
+			assert.False(t, fs.cancelled, tc)
+		}
+	})
+}
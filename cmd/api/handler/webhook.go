@@ -0,0 +1,256 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/frisk038/tezos-delegation-service/domain/entity"
+	"github.com/gin-gonic/gin"
+)
+
+// subscriptionCreator defines an interface for creating webhook subscriptions.
+type subscriptionCreator interface {
+	CreateSubscription(ctx context.Context, sub entity.Subscription) (entity.Subscription, error)
+}
+
+// subscriptionGetter defines an interface for getting the webhook subscriptions.
+type subscriptionGetter interface {
+	GetSubscriptions(ctx context.Context) ([]entity.Subscription, error)
+}
+
+// subscriptionDeleter defines an interface for deleting webhook subscriptions.
+type subscriptionDeleter interface {
+	DeleteSubscription(ctx context.Context, id int64) error
+}
+
+// deliveryGetter defines an interface for getting the delivery attempts of a webhook subscription.
+type deliveryGetter interface {
+	GetDeliveries(ctx context.Context, subscriptionId int64, limit, offset int) ([]entity.Delivery, error)
+}
+
+// subscriptionRq represents the JSON request format for creating a webhook subscription.
+type subscriptionRq struct {
+	Url       string   `json:"url"`
+	Addresses []string `json:"addresses"`
+	Events    []string `json:"events"`
+}
+
+// subscriptionJs represents the JSON response format for webhook subscriptions.
+// The secret is only returned on creation.
+type subscriptionJs struct {
+	Id        int64     `json:"id"`
+	Url       string    `json:"url"`
+	Addresses []string  `json:"addresses"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// deliveryJs represents the JSON response format for the delivery attempts of a webhook subscription.
+type deliveryJs struct {
+	Id          int64     `json:"id"`
+	OperationId int64     `json:"operationId"`
+	Event       string    `json:"event"`
+	Attempt     int       `json:"attempt"`
+	StatusCode  int       `json:"statusCode,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// CreateSubscription is a Gin HTTP handler that creates a webhook subscription.
+// @Summary Create a webhook subscription
+// @Description Subscribe an url to the delegators joining or leaving the given bakers. Each event is posted as JSON
+// @Description with its kind in the X-Webhook-Event header and the HMAC-SHA256 of the body, keyed by the secret of the
+// @Description subscription, in the X-Webhook-Signature header as sha256=<hex>. Failed deliveries are retried with backoff.
+// @Description The secret is only returned on creation. Only the operations stored after the creation are notified, at
+// @Description least once each.
+// @ID create-webhook
+// @Accept  json
+// @Produce  json
+// @Param subscription body subscriptionRq true "Url, addresses of the watched bakers and kinds of events (default is all)"
+// @Success 201 {object} subscriptionJs
+// @Failure 400 "Invalid url, address or event"
+// @Router /xtz/webhooks [post]
+func CreateSubscription(creator subscriptionCreator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var rq subscriptionRq
+		if err := c.ShouldBindJSON(&rq); err != nil {
+			_ = c.AbortWithError(http.StatusBadRequest, fmt.Errorf("invalid subscription: %w", err))
+			return
+		}
+
+		u, err := url.Parse(rq.Url)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+			_ = c.AbortWithError(http.StatusBadRequest, errors.New("url must be an absolute http or https url"))
+			return
+		}
+		if len(rq.Addresses) == 0 {
+			_ = c.AbortWithError(http.StatusBadRequest, errors.New("addresses must list at least one baker"))
+			return
+		}
+		for _, address := range rq.Addresses {
+			if !addressFormat.MatchString(address) {
+				_ = c.AbortWithError(http.StatusBadRequest, fmt.Errorf("%q is not a valid Tezos address", address))
+				return
+			}
+		}
+		if len(rq.Events) == 0 {
+			rq.Events = []string{entity.EventJoined, entity.EventLeft}
+		}
+		for _, event := range rq.Events {
+			if event != entity.EventJoined && event != entity.EventLeft {
+				_ = c.AbortWithError(http.StatusBadRequest, errors.New("events must be joined or left"))
+				return
+			}
+		}
+
+		sub, err := creator.CreateSubscription(c.Request.Context(), entity.Subscription{
+			Url:       rq.Url,
+			Addresses: rq.Addresses,
+			Events:    rq.Events,
+		})
+		if err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		resp := toSubscriptionJs(sub)
+		resp.Secret = sub.Secret
+		c.JSON(http.StatusCreated, gin.H{"data": resp})
+	}
+}
+
+// GetSubscriptions is a Gin HTTP handler that retrieves the webhook subscriptions.
+// @Summary Get webhook subscriptions
+// @Description Retrieve every webhook subscription, the oldest first, without their secret.
+// @ID get-webhooks
+// @Accept  json
+// @Produce  json
+// @Success 200 {array} subscriptionJs
+// @Router /xtz/webhooks [get]
+//
+//goland:noinspection GoPreferNilSlice
+func GetSubscriptions(getter subscriptionGetter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		subs, err := getter.GetSubscriptions(c.Request.Context())
+		if err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		resp := []subscriptionJs{}
+		for _, sub := range subs {
+			resp = append(resp, toSubscriptionJs(sub))
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": resp})
+	}
+}
+
+// DeleteSubscription is a Gin HTTP handler that deletes a webhook subscription along with its delivery log.
+// @Summary Delete a webhook subscription
+// @Description Stop notifying a webhook subscription and delete its delivery log.
+// @ID delete-webhook
+// @Param id path int true "Id of the subscription"
+// @Success 204
+// @Failure 400 "Invalid id"
+// @Failure 404 "No subscription with this id"
+// @Router /xtz/webhooks/{id} [delete]
+func DeleteSubscription(deleter subscriptionDeleter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := parseSubscriptionId(c)
+		if err != nil {
+			_ = c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+
+		err = deleter.DeleteSubscription(c.Request.Context(), id)
+		if errors.Is(err, entity.ErrNotFound) {
+			_ = c.AbortWithError(http.StatusNotFound, err)
+			return
+		}
+		if err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// GetDeliveries is a Gin HTTP handler that retrieves the delivery log of a webhook subscription.
+// @Summary Get the deliveries of a webhook subscription
+// @Description Retrieve the attempts to deliver the events of a webhook subscription, the most recent first.
+// @Description The status code is omitted when the receiver wasn't reached, the error once the event was delivered.
+// @ID get-webhook-deliveries
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Id of the subscription"
+// @Param limit query int false "Limit the number of results (default is 10)"
+// @Param offset query int false "Offset for pagination"
+// @Success 200 {array} deliveryJs
+// @Failure 400 "Invalid id or pagination"
+// @Router /xtz/webhooks/{id}/deliveries [get]
+//
+//goland:noinspection GoPreferNilSlice
+func GetDeliveries(cfg Config, getter deliveryGetter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := parseSubscriptionId(c)
+		if err != nil {
+			_ = c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+		limit, offset, err := parsePagination(c, cfg)
+		if err != nil {
+			_ = c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+
+		dlvs, err := getter.GetDeliveries(c.Request.Context(), id, limit, offset)
+		if err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		resp := []deliveryJs{}
+		for _, dlv := range dlvs {
+			resp = append(resp, deliveryJs{
+				Id:          dlv.Id,
+				OperationId: dlv.OperationId,
+				Event:       dlv.Event,
+				Attempt:     dlv.Attempt,
+				StatusCode:  dlv.StatusCode,
+				Error:       dlv.Error,
+				CreatedAt:   dlv.CreatedAt,
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": resp})
+	}
+}
+
+// parseSubscriptionId reads the id of the subscription in the path.
+func parseSubscriptionId(c *gin.Context) (int64, error) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, errors.New("id must be a positive number")
+	}
+
+	return id, nil
+}
+
+// toSubscriptionJs maps a subscription onto its JSON response format, without its secret.
+func toSubscriptionJs(sub entity.Subscription) subscriptionJs {
+	return subscriptionJs{
+		Id:        sub.Id,
+		Url:       sub.Url,
+		Addresses: sub.Addresses,
+		Events:    sub.Events,
+		CreatedAt: sub.CreatedAt,
+	}
+}
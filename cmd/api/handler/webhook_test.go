@@ -0,0 +1,259 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/frisk038/tezos-delegation-service/domain/entity"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockWebhookUsecase struct {
+	mock.Mock
+}
+
+func (mu *mockWebhookUsecase) CreateSubscription(ctx context.Context, sub entity.Subscription) (entity.Subscription, error) {
+	called := mu.Called(ctx, sub)
+	return called.Get(0).(entity.Subscription), called.Error(1)
+}
+
+func (mu *mockWebhookUsecase) GetSubscriptions(ctx context.Context) ([]entity.Subscription, error) {
+	called := mu.Called(ctx)
+	return called.Get(0).([]entity.Subscription), called.Error(1)
+}
+
+func (mu *mockWebhookUsecase) DeleteSubscription(ctx context.Context, id int64) error {
+	return mu.Called(ctx, id).Error(0)
+}
+
+func (mu *mockWebhookUsecase) GetDeliveries(ctx context.Context, subscriptionId int64, limit, offset int) ([]entity.Delivery, error) {
+	called := mu.Called(ctx, subscriptionId, limit, offset)
+	return called.Get(0).([]entity.Delivery), called.Error(1)
+}
+
+func getWebhookTestContext(method, id, body string) (*gin.Context, *httptest.ResponseRecorder) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = &http.Request{
+		Method: method,
+		Header: make(http.Header),
+		URL:    &url.URL{},
+		Body:   io.NopCloser(strings.NewReader(body)),
+	}
+	c.Request.Header.Set("Content-Type", "application/json")
+	if len(id) != 0 {
+		c.Params = gin.Params{{Key: "id", Value: id}}
+	}
+
+	return c, w
+}
+
+func TestCreateSubscription(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	baker := "tz1aRoaRhSpRYvFdyvgWLL6TGyRoGF51wDjM"
+	created := time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("success", func(t *testing.T) {
+		c, w := getWebhookTestContext("POST", "",
+			`{"url":"https://example.com/hook","addresses":["`+baker+`"],"events":["left"]}`)
+		mu := &mockWebhookUsecase{}
+		mu.On("CreateSubscription", c.Request.Context(), entity.Subscription{
+			Url:       "https://example.com/hook",
+			Addresses: []string{baker},
+			Events:    []string{entity.EventLeft},
+		}).Return(entity.Subscription{
+			Id:        1,
+			Url:       "https://example.com/hook",
+			Secret:    "s3cr3t",
+			Addresses: []string{baker},
+			Events:    []string{entity.EventLeft},
+			CreatedAt: created,
+		}, nil)
+
+		CreateSubscription(mu)(c)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.JSONEq(t,
+			`{"data":{"id":1,"url":"https://example.com/hook","addresses":["`+baker+`"],"events":["left"],
+			"secret":"s3cr3t","createdAt":"2023-10-01T12:00:00Z"}}`,
+			w.Body.String(),
+		)
+		mu.AssertExpectations(t)
+	})
+
+	t.Run("default_events", func(t *testing.T) {
+		c, w := getWebhookTestContext("POST", "", `{"url":"http://localhost:9000","addresses":["`+baker+`"]}`)
+		mu := &mockWebhookUsecase{}
+		mu.On("CreateSubscription", c.Request.Context(), entity.Subscription{
+			Url:       "http://localhost:9000",
+			Addresses: []string{baker},
+			Events:    []string{entity.EventJoined, entity.EventLeft},
+		}).Return(entity.Subscription{Id: 2}, nil)
+
+		CreateSubscription(mu)(c)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		mu.AssertExpectations(t)
+	})
+
+	t.Run("wrong_params", func(t *testing.T) {
+		for _, body := range []string{
+			`{"url":`,
+			`{"url":"ftp://example.com","addresses":["` + baker + `"]}`,
+			`{"url":"/hook","addresses":["` + baker + `"]}`,
+			`{"url":"https://example.com/hook","addresses":[]}`,
+			`{"url":"https://example.com/hook","addresses":["tz1Invalid"]}`,
+			`{"url":"https://example.com/hook","addresses":["` + baker + `"],"events":["moved"]}`,
+		} {
+			c, w := getWebhookTestContext("POST", "", body)
+			mu := &mockWebhookUsecase{}
+
+			CreateSubscription(mu)(c)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, body)
+			mu.AssertNotCalled(t, "CreateSubscription")
+		}
+	})
+
+	t.Run("create_err", func(t *testing.T) {
+		c, w := getWebhookTestContext("POST", "", `{"url":"https://example.com/hook","addresses":["`+baker+`"]}`)
+		mu := &mockWebhookUsecase{}
+		mu.On("CreateSubscription", c.Request.Context(), mock.Anything).Return(entity.Subscription{}, errors.New("err"))
+
+		CreateSubscription(mu)(c)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		mu.AssertExpectations(t)
+	})
+}
+
+func TestGetSubscriptions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("without_secret", func(t *testing.T) {
+		c, w := getWebhookTestContext("GET", "", "")
+		mu := &mockWebhookUsecase{}
+		mu.On("GetSubscriptions", c.Request.Context()).Return([]entity.Subscription{{
+			Id:        1,
+			Url:       "https://example.com/hook",
+			Secret:    "s3cr3t",
+			Addresses: []string{"baker1"},
+			Events:    []string{entity.EventJoined},
+			CreatedAt: time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC),
+		}}, nil)
+
+		GetSubscriptions(mu)(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t,
+			`{"data":[{"id":1,"url":"https://example.com/hook","addresses":["baker1"],"events":["joined"],
+			"createdAt":"2023-10-01T12:00:00Z"}]}`,
+			w.Body.String(),
+		)
+		mu.AssertExpectations(t)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		c, w := getWebhookTestContext("GET", "", "")
+		mu := &mockWebhookUsecase{}
+		mu.On("GetSubscriptions", c.Request.Context()).Return([]entity.Subscription(nil), nil)
+
+		GetSubscriptions(mu)(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"data":[]}`, w.Body.String())
+		mu.AssertExpectations(t)
+	})
+}
+
+func TestDeleteSubscription(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("success", func(t *testing.T) {
+		c, _ := getWebhookTestContext("DELETE", "1", "")
+		mu := &mockWebhookUsecase{}
+		mu.On("DeleteSubscription", c.Request.Context(), int64(1)).Return(nil)
+
+		DeleteSubscription(mu)(c)
+
+		assert.Equal(t, http.StatusNoContent, c.Writer.Status())
+		mu.AssertExpectations(t)
+	})
+
+	t.Run("not_found", func(t *testing.T) {
+		c, w := getWebhookTestContext("DELETE", "2", "")
+		mu := &mockWebhookUsecase{}
+		mu.On("DeleteSubscription", c.Request.Context(), int64(2)).Return(entity.ErrNotFound)
+
+		DeleteSubscription(mu)(c)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		mu.AssertExpectations(t)
+	})
+
+	t.Run("invalid_id", func(t *testing.T) {
+		for _, id := range []string{"abc", "0", "-1"} {
+			c, w := getWebhookTestContext("DELETE", id, "")
+			mu := &mockWebhookUsecase{}
+
+			DeleteSubscription(mu)(c)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, id)
+			mu.AssertNotCalled(t, "DeleteSubscription")
+		}
+	})
+}
+
+func TestGetDeliveries(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := Config{MaxLimit: 100, DefaultLimit: 10}
+	tn := time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("success", func(t *testing.T) {
+		c, w := getWebhookTestContext("GET", "1", "")
+		c.Request.URL.RawQuery = "limit=2&offset=4"
+		mu := &mockWebhookUsecase{}
+		mu.On("GetDeliveries", c.Request.Context(), int64(1), 2, 4).Return([]entity.Delivery{
+			{Id: 6, SubscriptionId: 1, OperationId: 3034, Event: entity.EventJoined, Attempt: 2, StatusCode: 200, CreatedAt: tn.Add(time.Second)},
+			{Id: 5, SubscriptionId: 1, OperationId: 3034, Event: entity.EventJoined, Attempt: 1, Error: "connection refused", CreatedAt: tn},
+		}, nil)
+
+		GetDeliveries(cfg, mu)(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t,
+			`{"data":[
+				{"id":6,"operationId":3034,"event":"joined","attempt":2,"statusCode":200,"createdAt":"2023-10-01T12:00:01Z"},
+				{"id":5,"operationId":3034,"event":"joined","attempt":1,"error":"connection refused","createdAt":"2023-10-01T12:00:00Z"}
+			]}`,
+			w.Body.String(),
+		)
+		mu.AssertExpectations(t)
+	})
+
+	t.Run("wrong_params", func(t *testing.T) {
+		for _, tc := range []struct{ id, query string }{
+			{id: "abc"},
+			{id: "1", query: "limit=1000"},
+			{id: "1", query: "offset=-1"},
+		} {
+			c, w := getWebhookTestContext("GET", tc.id, "")
+			c.Request.URL.RawQuery = tc.query
+			mu := &mockWebhookUsecase{}
+
+			GetDeliveries(cfg, mu)(c)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, tc)
+			mu.AssertNotCalled(t, "GetDeliveries")
+		}
+	})
+}
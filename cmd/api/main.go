@@ -1,20 +1,33 @@
 package main
 
 import (
+	"context"
 	"errors"
+	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/frisk038/tezos-delegation-service/cmd/api/handler"
 	"github.com/frisk038/tezos-delegation-service/cmd/cron"
 	"github.com/frisk038/tezos-delegation-service/config"
 	_ "github.com/frisk038/tezos-delegation-service/docs"
+	"github.com/frisk038/tezos-delegation-service/domain/adapter"
 	"github.com/frisk038/tezos-delegation-service/domain/usecase/delegation"
 	"github.com/frisk038/tezos-delegation-service/domain/usecase/poller"
+	"github.com/frisk038/tezos-delegation-service/domain/usecase/webhook"
+	"github.com/frisk038/tezos-delegation-service/infrastructure/adapter/broker"
+	"github.com/frisk038/tezos-delegation-service/infrastructure/adapter/notifier"
 	"github.com/frisk038/tezos-delegation-service/infrastructure/adapter/tezos"
 	"github.com/frisk038/tezos-delegation-service/infrastructure/repository"
 	"golang.org/x/exp/slog"
 )
 
+// shutdownTimeout bounds the time given to the pending requests once the service is asked to stop.
+const shutdownTimeout = 10 * time.Second
+
 // @title           Tezos Delegation Service
 // @version         1.0
 // @description     This is a simple service that will poll/return delegations on tezos protocol
@@ -63,28 +76,84 @@ func initDeps(log *slog.Logger) error {
 	}
 	defer db.Close()
 
-	tzApi, err := tezos.New(config.Cfg.Tezos)
+	tzApi, err := config.NewAPI(log)
 	if err != nil {
 		return err
 	}
 
-	pollerUC := poller.New(db, tzApi)
-	cr, err := cron.New(config.Cfg.Cron, pollerUC, log)
-	if err != nil {
-		return err
+	// In-flight polls are cancelled once the service is asked to stop.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	// The goroutines are stopped and waited for before the database is closed, ListenAndServe returning as soon as
+	// the shutdown starts.
+	var wg sync.WaitGroup
+	defer func() {
+		stop()
+		wg.Wait()
+	}()
+
+	// Stored delegations are handed to the streams of the api, and wake the delivery of the webhook events up.
+	br := broker.New(config.Cfg.Api.StreamBuffer)
+	whUC := webhook.New(db, notifier.New(config.Cfg.Webhook.Timeout), config.Cfg.Webhook, log)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := whUC.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			log.Error(err.Error())
+		}
+	}()
+	pollerUC := poller.New(db, tzApi, adapter.Publishers{br, whUC}, config.Cfg.Cron.ReorgDepth, log)
+	if config.Cfg.Events.Url != "" {
+		// Delegations are pushed by the events hub, polling is only used to catch up on reconnection.
+		if config.Cfg.Source == "node" ||
+			config.Cfg.Source == "failover" && config.Cfg.Failover.Sources[0].Kind != "tzkt" {
+			return errors.New("the events hub can only be used with tzkt sources")
+		}
+		sub, err := tezos.NewSubscriber(config.Cfg.Events, log)
+		if err != nil {
+			return err
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := pollerUC.Listen(ctx, sub); err != nil && !errors.Is(err, context.Canceled) {
+				log.Error(err.Error())
+			}
+		}()
+	} else {
+		cr, err := cron.New(config.Cfg.Cron, pollerUC, log)
+		if err != nil {
+			return err
+		}
+		cr.Cr.Start()
+		defer cr.Stop()
 	}
-	cr.Cr.Start()
-	defer cr.Cr.Stop()
 
-	dgUC := delegation.New(db)
-	router := handler.Init(config.Cfg.Api, dgUC)
+	dgUC := delegation.New(db, config.Cfg.Api.CountTTL)
+	router := handler.Init(config.Cfg.Api, dgUC, br, whUC)
 
 	port := os.Getenv("PORT")
 	if port == "" {
 		return errors.New("$PORT must be set")
 	}
-	err = router.Run(":" + port)
-	if err != nil {
+	srv := &http.Server{Addr: ":" + port, Handler: router}
+	// Streams only end with their client, they are closed so that the shutdown doesn't wait for them.
+	srv.RegisterOnShutdown(br.Close)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		<-ctx.Done()
+		log.Info("shutting down")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Error(err.Error())
+		}
+	}()
+
+	err = srv.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		return err
 	}
 
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/frisk038/tezos-delegation-service/config"
+	"github.com/frisk038/tezos-delegation-service/domain/usecase/backfill"
+	"github.com/frisk038/tezos-delegation-service/infrastructure/repository"
+	"golang.org/x/exp/slog"
+)
+
+// main loads the delegations of a past time range, independently of the cron poller.
+// It can be killed at any time, running it again with the same range resumes from the last stored chunk.
+func main() {
+	log := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	if err := run(log); err != nil {
+		log.Error(err.Error())
+		os.Exit(1)
+	}
+}
+
+func printHelp(log *slog.Logger) {
+	log.Info("Usage: ./backfill -from 2023-01-01 -to 2023-02-01 [-chunk 24h] conf-file.yml " +
+		"or CONFIG_FILE='conf-file.yml' ./backfill -from 2023-01-01 -to 2023-02-01")
+}
+
+// parseTime accepts either a date or a RFC3339 timestamp and returns it in UTC.
+func parseTime(value string) (time.Time, error) {
+	tm, err := time.Parse(time.DateOnly, value)
+	if err == nil {
+		return tm, nil
+	}
+	tm, err = time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither a date nor a RFC3339 timestamp", value)
+	}
+	return tm.UTC(), nil
+}
+
+func run(log *slog.Logger) error {
+	fromRq := flag.String("from", "", "inclusive start of the range (YYYY-MM-DD or RFC3339)")
+	toRq := flag.String("to", "", "exclusive end of the range (YYYY-MM-DD or RFC3339)")
+	chunk := flag.Duration("chunk", 24*time.Hour, "time window fetched and stored at once")
+	flag.Parse()
+
+	configFile := os.Getenv("CONFIG_FILE")
+	if flag.NArg() == 1 && flag.Arg(0) != "" {
+		configFile = flag.Arg(0)
+	}
+
+	if configFile == "" || *fromRq == "" || *toRq == "" {
+		printHelp(log)
+		return errors.New("wrong count of arguments")
+	}
+
+	from, err := parseTime(*fromRq)
+	if err != nil {
+		return err
+	}
+	to, err := parseTime(*toRq)
+	if err != nil {
+		return err
+	}
+
+	err = config.Load(configFile)
+	if err != nil {
+		printHelp(log)
+		return err
+	}
+
+	db, err := repository.New(config.Cfg.Database, log)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tzApi, err := config.NewAPI(log)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	summary, err := backfill.New(db, tzApi, *chunk, log).Run(ctx, from, to)
+	if err != nil {
+		return err
+	}
+	log.Info("backfill done",
+		"inserted", summary.Inserted,
+		"updated", summary.Updated,
+		"skipped", summary.Skipped)
+
+	return nil
+}
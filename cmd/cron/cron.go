@@ -2,43 +2,84 @@ package cron
 
 import (
 	"context"
+	"time"
 
+	"github.com/frisk038/tezos-delegation-service/domain/entity"
 	"github.com/robfig/cron/v3"
 	"golang.org/x/exp/slog"
 )
 
 // Config represents the configuration for the Cron service.
 type Config struct {
-	Spec string `yaml:"spec" env-default:"@hourly"`
+	Spec       string        `yaml:"spec" env-default:"@hourly"`
+	Timeout    time.Duration `yaml:"timeout" env-default:"5m"`
+	ReorgDepth int           `yaml:"reorg-depth" env-default:"3"`
 }
 
 // Cron is a service that manages cron jobs.
 type Cron struct {
-	Cr  *cron.Cron
-	log *slog.Logger
+	Cr     *cron.Cron
+	log    *slog.Logger
+	cancel context.CancelFunc
 }
 
 // delegationFetcher is an interface for fetching delegations.
 type delegationFetcher interface {
-	Fetch(ctx context.Context) error
+	Fetch(ctx context.Context) (entity.InsertSummary, error)
+}
+
+// cronLogger reports the messages of the cron scheduler, such as skipped runs, to the service logger.
+type cronLogger struct {
+	log *slog.Logger
+}
+
+func (l cronLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.log.Debug(msg, keysAndValues...)
+}
+
+func (l cronLogger) Error(err error, msg string, keysAndValues ...interface{}) {
+	l.log.Error(msg, append(keysAndValues, "err", err)...)
 }
 
 // New creates a new Cron service with the provided configuration, delegation fetcher, and logger.
+// Each run is bounded by cfg.Timeout and skipped while the previous one is still running.
 // It returns a pointer to the Cron instance and an error if initialization fails.
 func New(cfg Config, fetcher delegationFetcher, log *slog.Logger) (*Cron, error) {
-	c := cron.New()
+	ctx, cancel := context.WithCancel(context.Background())
+	c := cron.New(cron.WithChain(cron.SkipIfStillRunning(cronLogger{log: log})))
 	_, err := c.AddFunc(cfg.Spec, func() {
-		err := fetcher.Fetch(context.Background())
+		runCtx := ctx
+		if cfg.Timeout > 0 {
+			var cancelRun context.CancelFunc
+			runCtx, cancelRun = context.WithTimeout(ctx, cfg.Timeout)
+			defer cancelRun()
+		}
+
+		summary, err := fetcher.Fetch(runCtx)
 		if err != nil {
 			log.Error(err.Error())
+			return
 		}
+		log.Info("delegations polled",
+			"inserted", summary.Inserted,
+			"updated", summary.Updated,
+			"skipped", summary.Skipped)
 	})
 	if err != nil {
+		cancel()
 		return nil, err
 	}
 
 	return &Cron{
-		Cr:  c,
-		log: log,
+		Cr:     c,
+		log:    log,
+		cancel: cancel,
 	}, nil
 }
+
+// Stop stops scheduling new runs, cancels the one in flight and waits for it to return.
+func (c *Cron) Stop() {
+	done := c.Cr.Stop()
+	c.cancel()
+	<-done.Done()
+}
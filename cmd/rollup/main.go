@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/frisk038/tezos-delegation-service/config"
+	"github.com/frisk038/tezos-delegation-service/infrastructure/repository"
+	"golang.org/x/exp/slog"
+)
+
+// main computes again the daily rollup of the delegations from the stored ones.
+// The migration fills it and the poller keeps it up to date, a rebuild is only needed when delegations were stored
+// or removed by other means.
+func main() {
+	log := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	if err := run(log); err != nil {
+		log.Error(err.Error())
+		os.Exit(1)
+	}
+}
+
+func printHelp(log *slog.Logger) {
+	log.Info("Usage: ./rollup conf-file.yml or CONFIG_FILE='conf-file.yml' ./rollup")
+}
+
+func run(log *slog.Logger) error {
+	configFile := os.Getenv("CONFIG_FILE")
+	if len(os.Args) == 2 && os.Args[1] != "" {
+		configFile = os.Args[1]
+	}
+
+	if configFile == "" {
+		printHelp(log)
+		return errors.New("wrong count of arguments")
+	}
+
+	err := config.Load(configFile)
+	if err != nil {
+		printHelp(log)
+		return err
+	}
+
+	db, err := repository.New(config.Cfg.Database, log)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	days, err := db.RebuildDailyStats(ctx)
+	if err != nil {
+		return err
+	}
+	log.Info("rollup rebuilt", "days", days)
+
+	return nil
+}
@@ -1,30 +1,94 @@
 package config
 
 import (
+	"fmt"
+
 	"github.com/frisk038/tezos-delegation-service/cmd/api/handler"
 	"github.com/frisk038/tezos-delegation-service/cmd/cron"
+	"github.com/frisk038/tezos-delegation-service/domain/adapter"
+	"github.com/frisk038/tezos-delegation-service/domain/usecase/webhook"
+	"github.com/frisk038/tezos-delegation-service/infrastructure/adapter/failover"
+	"github.com/frisk038/tezos-delegation-service/infrastructure/adapter/octez"
 	"github.com/frisk038/tezos-delegation-service/infrastructure/adapter/tezos"
 	"github.com/frisk038/tezos-delegation-service/infrastructure/repository"
 	"github.com/ilyakaznacheev/cleanenv"
+	"golang.org/x/exp/slog"
 )
 
 // Config represents the configuration structure for the application.
 type Config struct {
-	Api      handler.Config    `yaml:"api"`
-	Tezos    tezos.Config      `yaml:"tezos-client"`
-	Database repository.Config `yaml:"database"`
-	Cron     cron.Config       `yaml:"cron"`
+	Api      handler.Config     `yaml:"api"`
+	Source   string             `yaml:"source" env:"SOURCE" env-default:"tzkt"`
+	Tezos    tezos.Config       `yaml:"tezos-client"`
+	Node     octez.Config       `yaml:"tezos-node"`
+	Failover failover.Config    `yaml:"failover"`
+	Events   tezos.EventsConfig `yaml:"tezos-events"`
+	Database repository.Config  `yaml:"database"`
+	Cron     cron.Config        `yaml:"cron"`
+	Webhook  webhook.Config     `yaml:"webhook"`
 }
 
 // Cfg is the global configuration instance.
 var Cfg Config
 
 // Load reads the configuration from the specified file and populates the global Cfg instance.
-// It returns an error if there is an issue reading or parsing the configuration.
+// It returns an error if there is an issue reading or parsing the configuration, or if a setting is invalid.
 func Load(file string) error {
 	err := cleanenv.ReadConfig(file, &Cfg)
 	if err != nil {
 		return err
 	}
+	if Cfg.Api.StreamHeartbeat <= 0 {
+		return fmt.Errorf("api stream-heartbeat must be positive, got %s", Cfg.Api.StreamHeartbeat)
+	}
 	return nil
 }
+
+// NewAPI creates the delegation source selected in the global Cfg instance:
+// the Tezos API (tzkt), a Tezos node (node) or an ordered list of them (failover).
+func NewAPI(log *slog.Logger) (adapter.API, error) {
+	switch Cfg.Source {
+	case "tzkt":
+		return tezos.New(Cfg.Tezos)
+	case "node":
+		return octez.New(Cfg.Node)
+	case "failover":
+		return newFailover(log)
+	default:
+		return nil, fmt.Errorf("unknown delegation source %q", Cfg.Source)
+	}
+}
+
+// newFailover creates every failover source, they take the settings of tezos-client or tezos-node except for the url.
+// Delegation ids differ between the Tezos API and a node, so all sources must be of the same kind.
+func newFailover(log *slog.Logger) (adapter.API, error) {
+	sources := make([]failover.Source, 0, len(Cfg.Failover.Sources))
+	for i, src := range Cfg.Failover.Sources {
+		if src.Kind != Cfg.Failover.Sources[0].Kind {
+			return nil, fmt.Errorf("failover source %d is %q while the first one is %q, delegation ids would not match",
+				i, src.Kind, Cfg.Failover.Sources[0].Kind)
+		}
+
+		var api adapter.API
+		var err error
+		switch src.Kind {
+		case "tzkt":
+			cfg := Cfg.Tezos
+			cfg.Url = src.Url
+			api, err = tezos.New(cfg)
+		case "node":
+			cfg := Cfg.Node
+			cfg.Url = src.Url
+			api, err = octez.New(cfg)
+		default:
+			err = fmt.Errorf("unknown delegation source %q", src.Kind)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		sources = append(sources, failover.Source{Name: src.Url, API: api})
+	}
+
+	return failover.New(Cfg.Failover, sources, log)
+}
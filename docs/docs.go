@@ -19,9 +19,104 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/xtz/bakers/{address}/delegators": {
+            "get": {
+                "description": "Retrieve the current delegators of a baker with their amount, along with their count and total amount",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get the delegators of a baker",
+                "operationId": "get-baker-delegators",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Address of the baker",
+                        "name": "address",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Limit the number of results (default is 10)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Offset for pagination",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "amount",
+                            "date"
+                        ],
+                        "type": "string",
+                        "description": "Sort by amount or date, descending (default is amount)",
+                        "name": "sort",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.bakerDelegatorsJs"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid address, pagination or sort"
+                    }
+                }
+            }
+        },
+        "/xtz/bakers/{address}/snapshot": {
+            "get": {
+                "description": "Rebuild from the stored history who was delegating to a baker at a given timestamp or level, with the amount known at that point",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get a snapshot of the delegators of a baker",
+                "operationId": "get-baker-snapshot",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Address of the baker",
+                        "name": "address",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Level, or RFC 3339 timestamp, of the snapshot (included)",
+                        "name": "at",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.bakerSnapshotJs"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid address or snapshot point"
+                    }
+                }
+            }
+        },
         "/xtz/delegations": {
             "get": {
-                "description": "Retrieve a list of delegations",
+                "description": "Retrieve a list of delegations, the most recent first unless sorted otherwise, ties being broken by id.\nA full page comes with a next_cursor, to pass as cursor in order to get the following page.\nWith include_total, a meta object gives the total of the matching delegations along with the pagination.\nThe next and previous pages are linked in a Link header.",
                 "consumes": [
                     "application/json"
                 ],
@@ -43,11 +138,453 @@ const docTemplate = `{
                         "name": "offset",
                         "in": "query"
                     },
+                    {
+                        "type": "string",
+                        "description": "Cursor for pagination, next_cursor of the previous page, exclusive with offset",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "timestamp",
+                            "amount",
+                            "id"
+                        ],
+                        "type": "string",
+                        "description": "Sort by timestamp, amount or id (default is timestamp)",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "asc",
+                            "desc"
+                        ],
+                        "type": "string",
+                        "description": "Order of the sort (default is desc)",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Return the total of the matching delegations in meta",
+                        "name": "include_total",
+                        "in": "query"
+                    },
                     {
                         "type": "integer",
                         "description": "Filter by year (optional)",
                         "name": "year",
                         "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "delegate",
+                            "undelegate",
+                            "re-delegate",
+                            "failed"
+                        ],
+                        "type": "string",
+                        "description": "Filter by kind of operation",
+                        "name": "kind",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "applied",
+                            "failed",
+                            "backtracked",
+                            "skipped",
+                            "all"
+                        ],
+                        "type": "string",
+                        "description": "Filter by status of operation, all for any (default is applied, any with kind failed)",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by address of the delegator",
+                        "name": "delegator",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by address of the new baker",
+                        "name": "baker",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by hash of the block",
+                        "name": "block",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Filter by minimum level (included)",
+                        "name": "level.gte",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Filter by maximum level (included)",
+                        "name": "level.lte",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Filter by minimum amount in mutez (included)",
+                        "name": "amount.gte",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Filter by maximum amount in mutez (included)",
+                        "name": "amount.lte",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by minimum timestamp, RFC 3339 or date (included)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by maximum timestamp, RFC 3339 or date (excluded)",
+                        "name": "to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/handler.delegationJs"
+                            }
+                        },
+                        "headers": {
+                            "Link": {
+                                "type": "string",
+                                "description": "Links to the next and previous pages"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid pagination or filter"
+                    }
+                }
+            }
+        },
+        "/xtz/delegations/export": {
+            "get": {
+                "description": "Stream every delegation matching the filters, as CSV with a header row or as newline delimited JSON.\nDelegations are sorted as by get-delegations, without pagination.\nAn error after the first rows cuts the response short, without its final chunk.",
+                "produces": [
+                    "text/csv",
+                    "application/x-ndjson"
+                ],
+                "summary": "Export delegations",
+                "operationId": "get-delegations-export",
+                "parameters": [
+                    {
+                        "enum": [
+                            "csv",
+                            "ndjson"
+                        ],
+                        "type": "string",
+                        "description": "Format of the export (default is csv)",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "timestamp",
+                            "amount",
+                            "id"
+                        ],
+                        "type": "string",
+                        "description": "Sort by timestamp, amount or id (default is timestamp)",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "asc",
+                            "desc"
+                        ],
+                        "type": "string",
+                        "description": "Order of the sort (default is desc)",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Filter by year (optional)",
+                        "name": "year",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "delegate",
+                            "undelegate",
+                            "re-delegate",
+                            "failed"
+                        ],
+                        "type": "string",
+                        "description": "Filter by kind of operation",
+                        "name": "kind",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "applied",
+                            "failed",
+                            "backtracked",
+                            "skipped",
+                            "all"
+                        ],
+                        "type": "string",
+                        "description": "Filter by status of operation, all for any (default is applied, any with kind failed)",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by address of the delegator",
+                        "name": "delegator",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by address of the new baker",
+                        "name": "baker",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by hash of the block",
+                        "name": "block",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Filter by minimum level (included)",
+                        "name": "level.gte",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Filter by maximum level (included)",
+                        "name": "level.lte",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Filter by minimum amount in mutez (included)",
+                        "name": "amount.gte",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Filter by maximum amount in mutez (included)",
+                        "name": "amount.lte",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by minimum timestamp, RFC 3339 or date (included)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by maximum timestamp, RFC 3339 or date (excluded)",
+                        "name": "to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid format or filter"
+                    }
+                }
+            }
+        },
+        "/xtz/delegations/stats": {
+            "get": {
+                "description": "Aggregate the applied delegations by period: count of operations, of distinct delegators,\ntotal and median amount. Periods are listed oldest first and left out when without delegation.\nThey are read from a daily rollup refreshed along with the stored delegations, so the time range is\napplied by day.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get statistics of the delegations",
+                "operationId": "get-delegation-stats",
+                "parameters": [
+                    {
+                        "enum": [
+                            "day",
+                            "week",
+                            "month",
+                            "year"
+                        ],
+                        "type": "string",
+                        "description": "Period of the aggregates, weeks start on Monday (default is day)",
+                        "name": "bucket",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by minimum day, YYYY-MM-DD or RFC 3339 at midnight UTC (included)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by maximum day, YYYY-MM-DD or RFC 3339 at midnight UTC (excluded)",
+                        "name": "to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/handler.statsJs"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid bucket or time range"
+                    }
+                }
+            }
+        },
+        "/xtz/delegations/stream": {
+            "get": {
+                "description": "Push each applied delegation matching the filters as soon as it is stored, as a Server-Sent Event\nnamed delegation, whose id is the id of the operation and data the delegation.\nWith a Last-Event-ID header, the delegations stored after that operation are sent first.\nThe stream is closed if the client falls behind, it resumes from the last event id on reconnection.\nA delegation removed by a chain reorganization is sent as an event named removed, without id, whose data\nis the delegation as it was stored. If it is stored back, e.g. in another block, it is sent again with\nits id. Removals are not replayed on reconnection.",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "summary": "Stream delegations",
+                "operationId": "get-delegations-stream",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Id of the last operation received, to resume the stream after it",
+                        "name": "Last-Event-ID",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by address of the delegator",
+                        "name": "delegator",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by address of the new baker",
+                        "name": "baker",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Filter by minimum amount in mutez (included)",
+                        "name": "amount.gte",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Events of the delegations",
+                        "schema": {
+                            "$ref": "#/definitions/handler.delegationJs"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid filter or Last-Event-ID"
+                    }
+                }
+            }
+        },
+        "/xtz/delegators/{address}": {
+            "get": {
+                "description": "Retrieve who a delegator is delegating to right now, as of its last applied operation",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get a delegator",
+                "operationId": "get-delegator",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Address of the delegator",
+                        "name": "address",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.delegatorJs"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid address"
+                    },
+                    "404": {
+                        "description": "No delegation stored for this address"
+                    }
+                }
+            }
+        },
+        "/xtz/delegators/{address}/delegations": {
+            "get": {
+                "description": "Retrieve every delegation operation of a delegator, from the oldest to the newest",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get the delegations of a delegator",
+                "operationId": "get-delegator-delegations",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Address of the delegator",
+                        "name": "address",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Limit the number of results (default is 10)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Offset for pagination",
+                        "name": "offset",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -59,28 +596,354 @@ const docTemplate = `{
                                 "$ref": "#/definitions/handler.delegationJs"
                             }
                         }
+                    },
+                    "400": {
+                        "description": "Invalid address or pagination"
+                    }
+                }
+            }
+        },
+        "/xtz/webhooks": {
+            "get": {
+                "description": "Retrieve every webhook subscription, the oldest first, without their secret.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get webhook subscriptions",
+                "operationId": "get-webhooks",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/handler.subscriptionJs"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Subscribe an url to the delegators joining or leaving the given bakers. Each event is posted as JSON\nwith its kind in the X-Webhook-Event header and the HMAC-SHA256 of the body, keyed by the secret of the\nsubscription, in the X-Webhook-Signature header as sha256=\u003chex\u003e. Failed deliveries are retried with backoff.\nThe secret is only returned on creation. Only the operations stored after the creation are notified, at\nleast once each.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Create a webhook subscription",
+                "operationId": "create-webhook",
+                "parameters": [
+                    {
+                        "description": "Url, addresses of the watched bakers and kinds of events (default is all)",
+                        "name": "subscription",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.subscriptionRq"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/handler.subscriptionJs"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid url, address or event"
+                    }
+                }
+            }
+        },
+        "/xtz/webhooks/{id}": {
+            "delete": {
+                "description": "Stop notifying a webhook subscription and delete its delivery log.",
+                "summary": "Delete a webhook subscription",
+                "operationId": "delete-webhook",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Id of the subscription",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Invalid id"
+                    },
+                    "404": {
+                        "description": "No subscription with this id"
+                    }
+                }
+            }
+        },
+        "/xtz/webhooks/{id}/deliveries": {
+            "get": {
+                "description": "Retrieve the attempts to deliver the events of a webhook subscription, the most recent first.\nThe status code is omitted when the receiver wasn't reached, the error once the event was delivered.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get the deliveries of a webhook subscription",
+                "operationId": "get-webhook-deliveries",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Id of the subscription",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Limit the number of results (default is 10)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Offset for pagination",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/handler.deliveryJs"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid id or pagination"
                     }
                 }
             }
         }
     },
     "definitions": {
+        "handler.bakerDelegatorsJs": {
+            "type": "object",
+            "properties": {
+                "baker": {
+                    "type": "string"
+                },
+                "count": {
+                    "type": "integer"
+                },
+                "delegators": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handler.delegatorJs"
+                    }
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handler.bakerSnapshotJs": {
+            "type": "object",
+            "properties": {
+                "at": {
+                    "type": "string"
+                },
+                "baker": {
+                    "type": "string"
+                },
+                "count": {
+                    "type": "integer"
+                },
+                "delegators": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handler.delegatorJs"
+                    }
+                },
+                "level": {
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
         "handler.delegationJs": {
             "type": "object",
             "properties": {
                 "amount": {
                     "type": "integer"
                 },
+                "baker": {
+                    "type": "string"
+                },
+                "bakerFee": {
+                    "type": "integer"
+                },
                 "block": {
                     "type": "string"
                 },
+                "counter": {
+                    "type": "integer"
+                },
                 "delegator": {
                     "type": "string"
                 },
+                "gasUsed": {
+                    "type": "integer"
+                },
+                "hash": {
+                    "type": "string"
+                },
+                "kind": {
+                    "type": "string"
+                },
+                "level": {
+                    "type": "integer"
+                },
+                "prevBaker": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
                 "timestamp": {
                     "type": "string"
                 }
             }
+        },
+        "handler.delegatorJs": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "integer"
+                },
+                "baker": {
+                    "type": "string"
+                },
+                "delegator": {
+                    "type": "string"
+                },
+                "lastId": {
+                    "type": "integer"
+                },
+                "since": {
+                    "type": "string"
+                },
+                "sinceLevel": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handler.deliveryJs": {
+            "type": "object",
+            "properties": {
+                "attempt": {
+                    "type": "integer"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "event": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "operationId": {
+                    "type": "integer"
+                },
+                "statusCode": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handler.statsJs": {
+            "type": "object",
+            "properties": {
+                "bucket": {
+                    "type": "string"
+                },
+                "count": {
+                    "type": "integer"
+                },
+                "delegators": {
+                    "type": "integer"
+                },
+                "median": {
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handler.subscriptionJs": {
+            "type": "object",
+            "properties": {
+                "addresses": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "secret": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "handler.subscriptionRq": {
+            "type": "object",
+            "properties": {
+                "addresses": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
         }
     },
     "externalDocs": {
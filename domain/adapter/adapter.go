@@ -2,12 +2,61 @@ package adapter
 
 import (
 	"context"
-	"time"
 
 	"github.com/frisk038/tezos-delegation-service/domain/entity"
 )
 
+// PageFunc is called with each page of delegations, in id order. Returning an error stops the walk.
+type PageFunc func(ctx context.Context, dgs []entity.Delegation) error
+
 // API is an interface that defines the methods for interacting with the Tezos API.
 type API interface {
-	GetDelegations(ctx context.Context, startTime time.Time) ([]entity.Delegation, error)
+	// GetDelegations returns the delegations within the given range sorted by id.
+	GetDelegations(ctx context.Context, rg entity.DelegationRange) ([]entity.Delegation, error)
+	// StreamDelegations walks the delegations within the given range sorted by id, calling onPage with each
+	// non-empty page as soon as it is received so that the whole range never has to be held in memory.
+	StreamDelegations(ctx context.Context, rg entity.DelegationRange, onPage PageFunc) error
+}
+
+// Subscriber is an interface that defines how to receive delegations as soon as the Tezos API indexes them.
+type Subscriber interface {
+	// Subscribe blocks until ctx is done and reconnects on failures.
+	// onSync is called each time anything may have been missed: once subscribed and after a chain reorganization.
+	// onDelegations is called with every batch of new delegations.
+	Subscribe(ctx context.Context,
+		onSync func(ctx context.Context) error,
+		onDelegations func(ctx context.Context, dgs []entity.Delegation) error) error
+}
+
+// Publisher is an interface that defines how to notify the delegations as soon as they are stored.
+type Publisher interface {
+	// Publish hands the stored delegations, in id order, to whoever listens. It must not block.
+	Publish(dgs []entity.Delegation)
+	// Retract hands the delegations removed by a chain reorganization, as they were stored, to whoever listens.
+	// It must not block.
+	Retract(dgs []entity.Delegation)
+}
+
+// Publishers hands the delegations to each of its publishers in turn.
+type Publishers []Publisher
+
+// Publish hands the delegations to each publisher.
+func (ps Publishers) Publish(dgs []entity.Delegation) {
+	for _, p := range ps {
+		p.Publish(dgs)
+	}
+}
+
+// Retract hands the removed delegations to each publisher.
+func (ps Publishers) Retract(dgs []entity.Delegation) {
+	for _, p := range ps {
+		p.Retract(dgs)
+	}
+}
+
+// Notifier is an interface that defines how to deliver an event to a webhook subscription.
+type Notifier interface {
+	// Notify posts the event to the url of the subscription, signed with its secret. It returns the status code
+	// answered, 0 when the receiver wasn't reached, along with an error unless the status is a success.
+	Notify(ctx context.Context, sub entity.Subscription, ev entity.WebhookEvent) (int, error)
 }
@@ -2,6 +2,34 @@ package entity
 
 import "time"
 
+// Kinds of delegation operations
+const (
+	KindDelegate   = "delegate"    // An account without delegate chose one
+	KindUndelegate = "undelegate"  // An account withdrew its delegate
+	KindRedelegate = "re-delegate" // An account moved from one delegate to another
+	KindFailed     = "failed"      // The operation was not applied
+)
+
+// Statuses of delegation operations
+const (
+	StatusApplied     = "applied"
+	StatusFailed      = "failed"
+	StatusBacktracked = "backtracked"
+	StatusSkipped     = "skipped"
+)
+
+// Sorts of the delegations, besides SortAmount, ties are broken by id
+const (
+	SortTimestamp = "timestamp"
+	SortId        = "id"
+)
+
+// Orders of the delegations
+const (
+	OrderAsc  = "asc"
+	OrderDesc = "desc"
+)
+
 // Delegation struct represent a delegation regarding Delegated POS
 type Delegation struct {
 	Amount    int64
@@ -9,11 +37,78 @@ type Delegation struct {
 	Id        int64
 	Delegator string
 	TimeStamp time.Time
+	Hash      string // Hash of the operation
+	Level     int64  // Level of the block
+	Baker     string // Delegate chosen by the delegator, empty on an undelegation
+	PrevBaker string // Delegate of the delegator before the operation, empty if it had none
+	Status    string // Status of the operation: applied, failed, backtracked or skipped
+	BakerFee  int64  // Fee paid to the baker of the block, in mutez
+	GasUsed   int64  // Gas consumed by the operation
+	Counter   int64  // Counter of the delegator's account used by the operation
+}
+
+// Kind classifies the operation, it is empty when the status of the operation is unknown
+func (d Delegation) Kind() string {
+	switch {
+	case d.Status == "":
+		return ""
+	case d.Status != StatusApplied:
+		return KindFailed
+	case d.Baker == "":
+		return KindUndelegate
+	case d.PrevBaker != "":
+		return KindRedelegate
+	default:
+		return KindDelegate
+	}
 }
 
 // DelegationRequest represent a query in order to show the delegations
+// Each filter is ignored when left to its zero value
 type DelegationRequest struct {
-	Limit  int
-	Offset int
-	Date   time.Time
+	Limit     int
+	Offset    int
+	Date      time.Time        // Only operations of the year starting at this date are shown
+	Kind      string           // Only operations of this kind are shown
+	Status    string           // Only operations with this status are shown
+	Delegator string           // Only operations sent by this delegator are shown
+	Baker     string           // Only operations delegating to this baker are shown
+	Block     string           // Only operations included in this block are shown
+	LevelMin  int64            // Only operations at this level or above are shown
+	LevelMax  int64            // Only operations at this level or below are shown
+	AmountMin *int64           // Only operations with this amount or more are shown
+	AmountMax *int64           // Only operations with this amount or less are shown
+	From      time.Time        // Only operations at this timestamp or after are shown
+	To        time.Time        // Only operations before this timestamp are shown
+	After     DelegationCursor // Only operations listed after this one are shown, ignored when its id is zero
+	Sort      string           // SortTimestamp, SortAmount or SortId, the timestamp when empty
+	Order     string           // OrderAsc or OrderDesc, descending when empty
+}
+
+// DelegationCursor locates a delegation in the sorted list of delegations
+type DelegationCursor struct {
+	TimeStamp time.Time
+	Amount    int64
+	Id        int64
+}
+
+// DelegationRange bounds the delegations fetched from the Tezos API
+type DelegationRange struct {
+	LastId int64     // Only delegations with a greater id are returned
+	From   time.Time // Inclusive lower bound on the timestamp, ignored when zero
+	To     time.Time // Exclusive upper bound on the timestamp, ignored when zero
+}
+
+// InsertSummary reports how a batch of delegations has been stored
+type InsertSummary struct {
+	Inserted int
+	Updated  int
+	Skipped  int
+}
+
+// Add accumulates another summary into this one
+func (s *InsertSummary) Add(o InsertSummary) {
+	s.Inserted += o.Inserted
+	s.Updated += o.Updated
+	s.Skipped += o.Skipped
 }
@@ -0,0 +1,24 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDelegation_Kind(t *testing.T) {
+	for name, tc := range map[string]struct {
+		dg   Delegation
+		kind string
+	}{
+		"delegate":    {dg: Delegation{Status: StatusApplied, Baker: "baker1"}, kind: KindDelegate},
+		"re-delegate": {dg: Delegation{Status: StatusApplied, Baker: "baker2", PrevBaker: "baker1"}, kind: KindRedelegate},
+		"undelegate":  {dg: Delegation{Status: StatusApplied, PrevBaker: "baker1"}, kind: KindUndelegate},
+		"failed":      {dg: Delegation{Status: StatusBacktracked, Baker: "baker1"}, kind: KindFailed},
+		"unknown":     {dg: Delegation{Baker: "baker1"}, kind: ""},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.kind, tc.dg.Kind())
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package entity
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned when the requested resource doesn't exist
+var ErrNotFound = errors.New("not found")
+
+// Sorts of the delegators of a baker, both descending
+const (
+	SortAmount = "amount" // Largest delegated amount first
+	SortDate   = "date"   // Most recent delegators first
+)
+
+// DelegatorState represent the current delegation of a delegator, as of its last applied operation
+type DelegatorState struct {
+	Delegator  string
+	Baker      string    // Current delegate, empty when the delegator withdrew its delegation
+	Since      time.Time // Timestamp of the last applied operation
+	SinceLevel int64     // Level of the last applied operation
+	LastId     int64     // Id of the last applied operation
+	Amount     int64     // Amount known at the last applied operation
+}
+
+// DelegatorHistoryRequest represent a query in order to show the delegations of a delegator, oldest first
+type DelegatorHistoryRequest struct {
+	Delegator string
+	Limit     int
+	Offset    int
+}
+
+// BakerDelegatorsRequest represent a query in order to show the current delegators of a baker
+type BakerDelegatorsRequest struct {
+	Baker  string
+	Limit  int
+	Offset int
+	Sort   string // SortAmount or SortDate
+}
+
+// BakerDelegators represent a page of the current delegators of a baker along with the totals of all of them
+type BakerDelegators struct {
+	Delegators []DelegatorState
+	Count      int64 // Count of the current delegators
+	Total      int64 // Sum of the amounts of the current delegators
+}
+
+// BakerSnapshotRequest represent a query in order to show the delegators of a baker as of a past moment,
+// given either as a timestamp or as a level
+type BakerSnapshotRequest struct {
+	Baker string
+	At    time.Time // Operations up to this timestamp included, when not zero
+	Level int64     // Operations up to this level included, when positive
+}
@@ -0,0 +1,47 @@
+package entity
+
+import (
+	"sort"
+	"time"
+)
+
+// Buckets of the delegation statistics, weeks starting on Monday
+const (
+	BucketDay   = "day"
+	BucketWeek  = "week"
+	BucketMonth = "month"
+	BucketYear  = "year"
+)
+
+// StatsRequest represent a query in order to aggregate the applied delegations by period
+type StatsRequest struct {
+	Bucket string    // BucketDay, BucketWeek, BucketMonth or BucketYear
+	From   time.Time // Only operations of this day or after are aggregated, ignored when zero
+	To     time.Time // Only operations before this day are aggregated, ignored when zero
+}
+
+// DelegationStats represent the aggregate of the applied delegations of a period
+type DelegationStats struct {
+	Bucket     time.Time // Start of the period, in UTC
+	Count      int64     // Count of operations
+	Delegators int64     // Count of distinct delegators
+	Total      int64     // Sum of the amounts
+	Median     int64     // Median of the amounts, rounded
+}
+
+// DelegationDays returns the distinct days of the delegations, as midnight UTC, oldest first
+func DelegationDays(dgs []Delegation) []time.Time {
+	seen := map[time.Time]struct{}{}
+	var days []time.Time
+	for _, dg := range dgs {
+		ts := dg.TimeStamp.UTC()
+		day := time.Date(ts.Year(), ts.Month(), ts.Day(), 0, 0, 0, 0, time.UTC)
+		if _, ok := seen[day]; !ok {
+			seen[day] = struct{}{}
+			days = append(days, day)
+		}
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+
+	return days
+}
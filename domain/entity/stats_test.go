@@ -0,0 +1,28 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDelegationDays(t *testing.T) {
+	day := time.Date(2023, 9, 16, 0, 0, 0, 0, time.UTC)
+	paris := time.FixedZone("CEST", 2*60*60)
+
+	t.Run("distinct_days", func(t *testing.T) {
+		got := DelegationDays([]Delegation{
+			{TimeStamp: day.AddDate(0, 0, 1).Add(time.Hour)},
+			{TimeStamp: day.Add(23 * time.Hour)},
+			{TimeStamp: day.Add(time.Hour)},
+			// Days are taken in UTC.
+			{TimeStamp: day.AddDate(0, 0, 1).Add(time.Hour).In(paris)},
+		})
+		assert.Equal(t, []time.Time{day, day.AddDate(0, 0, 1)}, got)
+	})
+
+	t.Run("no_delegation", func(t *testing.T) {
+		assert.Empty(t, DelegationDays(nil))
+	})
+}
@@ -0,0 +1,29 @@
+package entity
+
+// StreamFilter selects the delegations pushed to a stream as they are stored, an empty field matching any.
+type StreamFilter struct {
+	Delegator string
+	Baker     string
+	AmountMin int64
+}
+
+// StreamEvent is a change of the stored delegations pushed to the streams.
+type StreamEvent struct {
+	Delegation Delegation
+	Removed    bool // The delegation was removed by a chain reorganization, rather than stored.
+}
+
+// Match reports whether the delegation is selected by the filter.
+// Only applied delegations are selected, as by the listing of the delegations by default.
+func (f StreamFilter) Match(dg Delegation) bool {
+	if dg.Status != "" && dg.Status != StatusApplied {
+		return false
+	}
+	if f.Delegator != "" && dg.Delegator != f.Delegator {
+		return false
+	}
+	if f.Baker != "" && dg.Baker != f.Baker {
+		return false
+	}
+	return dg.Amount >= f.AmountMin
+}
@@ -0,0 +1,30 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamFilter_Match(t *testing.T) {
+	dg := Delegation{Delegator: "dg1", Baker: "baker1", Amount: 1000, Status: StatusApplied}
+
+	for name, tc := range map[string]struct {
+		f    StreamFilter
+		dg   Delegation
+		want bool
+	}{
+		"empty_filter":      {f: StreamFilter{}, dg: dg, want: true},
+		"all_fields":        {f: StreamFilter{Delegator: "dg1", Baker: "baker1", AmountMin: 1000}, dg: dg, want: true},
+		"other_delegator":   {f: StreamFilter{Delegator: "dg2"}, dg: dg, want: false},
+		"other_baker":       {f: StreamFilter{Baker: "baker2"}, dg: dg, want: false},
+		"amount_too_low":    {f: StreamFilter{AmountMin: 1001}, dg: dg, want: false},
+		"no_status":         {f: StreamFilter{}, dg: Delegation{Delegator: "dg1"}, want: true},
+		"failed_operation":  {f: StreamFilter{}, dg: Delegation{Delegator: "dg1", Status: StatusFailed}, want: false},
+		"undelegation_kept": {f: StreamFilter{Delegator: "dg1"}, dg: Delegation{Delegator: "dg1", PrevBaker: "baker1"}, want: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, tc.f.Match(tc.dg))
+		})
+	}
+}
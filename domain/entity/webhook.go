@@ -0,0 +1,75 @@
+package entity
+
+import "time"
+
+// Kinds of the events notified to the webhook subscriptions.
+const (
+	EventJoined = "joined" // A delegator starts delegating to a watched baker.
+	EventLeft   = "left"   // A delegator stops delegating to a watched baker.
+)
+
+// States of the events of a subscription.
+const (
+	StatePending   = "pending"   // Not delivered yet, tried again until the retries run out.
+	StateDelivered = "delivered" // Received by the subscription.
+	StateFailed    = "failed"    // Given up once the retries ran out.
+)
+
+// Subscription is a webhook notified of the delegators joining or leaving the watched bakers.
+type Subscription struct {
+	Id        int64
+	Url       string
+	Secret    string   // Key of the HMAC signature of the payloads.
+	Addresses []string // Addresses of the watched bakers.
+	Events    []string // Kinds of the notified events.
+	AfterId   int64    // Last operation stored on creation, only the later ones are notified.
+	CreatedAt time.Time
+}
+
+// WebhookEvent is an event notified to a subscription.
+type WebhookEvent struct {
+	Kind       string
+	Baker      string // The watched baker joined or left.
+	Delegation Delegation
+	Attempts   int // Attempts to deliver the event already made.
+}
+
+// Delivery is an attempt to notify an event to a subscription.
+type Delivery struct {
+	Id             int64
+	SubscriptionId int64
+	OperationId    int64
+	Event          string
+	Attempt        int
+	StatusCode     int    // Status answered by the receiver, 0 when it wasn't reached.
+	Error          string // Empty when the event was delivered.
+	CreatedAt      time.Time
+}
+
+// Match returns the events of the delegation the subscription is notified of.
+// Only applied delegations changing of baker are events: a re-delegation between two watched bakers is both.
+// Operations stored before the subscription was created are not.
+func (s Subscription) Match(dg Delegation) []WebhookEvent {
+	if dg.Id <= s.AfterId || (dg.Status != "" && dg.Status != StatusApplied) || dg.Baker == dg.PrevBaker {
+		return nil
+	}
+
+	var evs []WebhookEvent
+	for _, kind := range s.Events {
+		baker := dg.Baker
+		if kind == EventLeft {
+			baker = dg.PrevBaker
+		}
+		if baker == "" {
+			continue
+		}
+		for _, address := range s.Addresses {
+			if address == baker {
+				evs = append(evs, WebhookEvent{Kind: kind, Baker: baker, Delegation: dg})
+				break
+			}
+		}
+	}
+
+	return evs
+}
@@ -0,0 +1,33 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscription_Match(t *testing.T) {
+	sub := Subscription{Addresses: []string{"baker1", "baker2"}, Events: []string{EventJoined, EventLeft}}
+	joined := Delegation{Id: 1, Delegator: "dg1", Baker: "baker1", Status: StatusApplied}
+	left := Delegation{Id: 2, Delegator: "dg1", PrevBaker: "baker1"}
+	moved := Delegation{Id: 3, Delegator: "dg1", Baker: "baker2", PrevBaker: "baker1"}
+
+	for name, tc := range map[string]struct {
+		sub  Subscription
+		dg   Delegation
+		want []WebhookEvent
+	}{
+		"joined":     {sub: sub, dg: joined, want: []WebhookEvent{{Kind: EventJoined, Baker: "baker1", Delegation: joined}}},
+		"left":       {sub: sub, dg: left, want: []WebhookEvent{{Kind: EventLeft, Baker: "baker1", Delegation: left}}},
+		"both":       {sub: sub, dg: moved, want: []WebhookEvent{{Kind: EventJoined, Baker: "baker2", Delegation: moved}, {Kind: EventLeft, Baker: "baker1", Delegation: moved}}},
+		"kind_off":   {sub: Subscription{Addresses: sub.Addresses, Events: []string{EventLeft}}, dg: joined},
+		"unwatched":  {sub: sub, dg: Delegation{Delegator: "dg1", Baker: "baker3", PrevBaker: "baker4"}},
+		"same_baker": {sub: sub, dg: Delegation{Delegator: "dg1", Baker: "baker1", PrevBaker: "baker1"}},
+		"failed":     {sub: sub, dg: Delegation{Delegator: "dg1", Baker: "baker1", Status: StatusFailed}},
+		"before_sub": {sub: Subscription{Addresses: sub.Addresses, Events: sub.Events, AfterId: 1}, dg: joined},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, tc.sub.Match(tc.dg))
+		})
+	}
+}
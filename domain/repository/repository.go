@@ -9,11 +9,39 @@ import (
 
 // Poller represents an interface for managing delegation data insertion and retrieval.
 type Poller interface {
-	InsertDelegations(ctx context.Context, dgs []entity.Delegation) error
-	SelectLastDelegation(ctx context.Context) (time.Time, error)
+	InsertDelegations(ctx context.Context, dgs []entity.Delegation) (entity.InsertSummary, error)
+	SelectLastDelegationId(ctx context.Context) (int64, error)
+	SelectRecentDelegations(ctx context.Context, levels int) ([]entity.Delegation, error)
+	ReplaceDelegations(ctx context.Context, ids []int64, dgs []entity.Delegation) (int64, entity.InsertSummary, error)
+}
+
+// Backfill represents an interface for storing historical delegations with resumable checkpoints.
+type Backfill interface {
+	InsertDelegations(ctx context.Context, dgs []entity.Delegation) (entity.InsertSummary, error)
+	SelectCheckpoint(ctx context.Context, from, to time.Time) (time.Time, error)
+	UpsertCheckpoint(ctx context.Context, from, to, cursor time.Time) error
 }
 
 // Delegation represents an interface for querying delegation data.
 type Delegation interface {
 	SelectDelegations(ctx context.Context, dgr entity.DelegationRequest) ([]entity.Delegation, error)
+	CountDelegations(ctx context.Context, dgr entity.DelegationRequest) (int64, error)
+	ExportDelegations(ctx context.Context, dgr entity.DelegationRequest, onRow func(dg entity.Delegation) error) error
+	SelectDelegationStats(ctx context.Context, rq entity.StatsRequest) ([]entity.DelegationStats, error)
+	SelectDelegatorState(ctx context.Context, delegator string) (entity.DelegatorState, error)
+	SelectDelegatorHistory(ctx context.Context, rq entity.DelegatorHistoryRequest) ([]entity.Delegation, error)
+	SelectBakerDelegators(ctx context.Context, rq entity.BakerDelegatorsRequest) ([]entity.DelegatorState, error)
+	SelectBakerTotals(ctx context.Context, baker string) (count int64, total int64, err error)
+	SelectBakerSnapshot(ctx context.Context, rq entity.BakerSnapshotRequest) ([]entity.DelegatorState, error)
+}
+
+// Webhook is an interface that defines the methods for storing webhook subscriptions and their deliveries.
+type Webhook interface {
+	InsertSubscription(ctx context.Context, sub entity.Subscription) (entity.Subscription, error)
+	SelectSubscriptions(ctx context.Context) ([]entity.Subscription, error)
+	DeleteSubscription(ctx context.Context, id int64) error
+	SelectPendingEvents(ctx context.Context, subscriptionId int64, limit int) ([]entity.WebhookEvent, error)
+	InsertDelivery(ctx context.Context, dlv entity.Delivery, state string) error
+	SelectDeliveries(ctx context.Context, subscriptionId int64, limit, offset int) ([]entity.Delivery, error)
+	DeleteExpiredEvents(ctx context.Context, retention time.Duration) (int64, int64, error)
 }
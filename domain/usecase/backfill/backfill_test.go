@@ -0,0 +1,195 @@
+package backfill
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/frisk038/tezos-delegation-service/domain/adapter"
+	"github.com/frisk038/tezos-delegation-service/domain/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/exp/slog"
+)
+
+type mockRepo struct {
+	mock.Mock
+}
+
+func (mr *mockRepo) InsertDelegations(ctx context.Context, dgs []entity.Delegation) (entity.InsertSummary, error) {
+	called := mr.Called(ctx, dgs)
+	return called.Get(0).(entity.InsertSummary), called.Error(1)
+}
+
+func (mr *mockRepo) SelectCheckpoint(ctx context.Context, from, to time.Time) (time.Time, error) {
+	called := mr.Called(ctx, from, to)
+	return called.Get(0).(time.Time), called.Error(1)
+}
+
+func (mr *mockRepo) UpsertCheckpoint(ctx context.Context, from, to, cursor time.Time) error {
+	return mr.Called(ctx, from, to, cursor).Error(0)
+}
+
+type mockAPI struct {
+	mock.Mock
+}
+
+func (ma *mockAPI) GetDelegations(ctx context.Context, rg entity.DelegationRange) ([]entity.Delegation, error) {
+	called := ma.Called(ctx, rg)
+	return called.Get(0).([]entity.Delegation), called.Error(1)
+}
+
+// StreamDelegations hands the delegations to onPage as one page before returning the error, if any.
+func (ma *mockAPI) StreamDelegations(ctx context.Context, rg entity.DelegationRange, onPage adapter.PageFunc) error {
+	called := ma.Called(ctx, rg)
+	if dgs := called.Get(0).([]entity.Delegation); len(dgs) != 0 {
+		if err := onPage(ctx, dgs); err != nil {
+			return err
+		}
+	}
+	return called.Error(1)
+}
+
+func TestUseCase_Run(t *testing.T) {
+	ctx := context.Background()
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	from := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(60 * time.Hour)
+	day1 := from.AddDate(0, 0, 1)
+	day2 := from.AddDate(0, 0, 2)
+	dgs := []entity.Delegation{
+		{
+			Amount:    1000034,
+			Block:     "block2",
+			Id:        3034,
+			Delegator: "dg2",
+			TimeStamp: from,
+		},
+		{
+			Amount:    1234,
+			Block:     "block1",
+			Id:        30004,
+			Delegator: "dg1",
+			TimeStamp: from,
+		},
+	}
+
+	t.Run("success", func(t *testing.T) {
+		mr := &mockRepo{}
+		mr.On("SelectCheckpoint", ctx, from, to).Return(time.Time{}, nil)
+		mr.On("InsertDelegations", ctx, dgs).Return(entity.InsertSummary{Inserted: 1, Skipped: 1}, nil)
+		mr.On("UpsertCheckpoint", ctx, from, to, day1).Return(nil)
+		mr.On("UpsertCheckpoint", ctx, from, to, day2).Return(nil)
+		mr.On("UpsertCheckpoint", ctx, from, to, to).Return(nil)
+
+		ma := &mockAPI{}
+		ma.On("StreamDelegations", ctx, entity.DelegationRange{From: from, To: day1}).Return(dgs, nil)
+		ma.On("StreamDelegations", ctx, entity.DelegationRange{From: day1, To: day2}).Return([]entity.Delegation{}, nil)
+		ma.On("StreamDelegations", ctx, entity.DelegationRange{From: day2, To: to}).Return(dgs, nil)
+
+		got, err := New(mr, ma, 24*time.Hour, log).Run(ctx, from, to)
+		assert.NoError(t, err)
+		assert.Equal(t, entity.InsertSummary{Inserted: 2, Skipped: 2}, got)
+		mr.AssertExpectations(t)
+		ma.AssertExpectations(t)
+	})
+
+	t.Run("resume_from_checkpoint", func(t *testing.T) {
+		mr := &mockRepo{}
+		mr.On("SelectCheckpoint", ctx, from, to).Return(day2, nil)
+		mr.On("InsertDelegations", ctx, dgs).Return(entity.InsertSummary{Inserted: 2}, nil)
+		mr.On("UpsertCheckpoint", ctx, from, to, to).Return(nil)
+
+		ma := &mockAPI{}
+		ma.On("StreamDelegations", ctx, entity.DelegationRange{From: day2, To: to}).Return(dgs, nil)
+
+		got, err := New(mr, ma, 24*time.Hour, log).Run(ctx, from, to)
+		assert.NoError(t, err)
+		assert.Equal(t, entity.InsertSummary{Inserted: 2}, got)
+		mr.AssertExpectations(t)
+		ma.AssertExpectations(t)
+	})
+
+	t.Run("already_done", func(t *testing.T) {
+		mr := &mockRepo{}
+		mr.On("SelectCheckpoint", ctx, from, to).Return(to, nil)
+		ma := &mockAPI{}
+
+		got, err := New(mr, ma, 24*time.Hour, log).Run(ctx, from, to)
+		assert.NoError(t, err)
+		assert.Empty(t, got)
+		mr.AssertExpectations(t)
+		ma.AssertExpectations(t)
+	})
+
+	t.Run("empty_range", func(t *testing.T) {
+		mr := &mockRepo{}
+		ma := &mockAPI{}
+
+		_, err := New(mr, ma, 24*time.Hour, log).Run(ctx, to, from)
+		assert.Error(t, err)
+	})
+
+	t.Run("wrong_chunk", func(t *testing.T) {
+		mr := &mockRepo{}
+		ma := &mockAPI{}
+
+		_, err := New(mr, ma, 0, log).Run(ctx, from, to)
+		assert.Error(t, err)
+	})
+
+	t.Run("checkpoint_err", func(t *testing.T) {
+		mr := &mockRepo{}
+		mr.On("SelectCheckpoint", ctx, from, to).Return(time.Time{}, errors.New("err"))
+		ma := &mockAPI{}
+
+		_, err := New(mr, ma, 24*time.Hour, log).Run(ctx, from, to)
+		assert.Error(t, err)
+		mr.AssertExpectations(t)
+	})
+
+	t.Run("api_err_keeps_checkpoint", func(t *testing.T) {
+		mr := &mockRepo{}
+		mr.On("SelectCheckpoint", ctx, from, to).Return(time.Time{}, nil)
+		mr.On("InsertDelegations", ctx, dgs).Return(entity.InsertSummary{Inserted: 2}, nil)
+		mr.On("UpsertCheckpoint", ctx, from, to, day1).Return(nil)
+
+		ma := &mockAPI{}
+		ma.On("StreamDelegations", ctx, entity.DelegationRange{From: from, To: day1}).Return(dgs, nil)
+		ma.On("StreamDelegations", ctx, entity.DelegationRange{From: day1, To: day2}).
+			Return([]entity.Delegation(nil), errors.New("err"))
+
+		got, err := New(mr, ma, 24*time.Hour, log).Run(ctx, from, to)
+		assert.Error(t, err)
+		assert.Equal(t, entity.InsertSummary{Inserted: 2}, got)
+		mr.AssertExpectations(t)
+		ma.AssertExpectations(t)
+	})
+
+	t.Run("insert_err", func(t *testing.T) {
+		mr := &mockRepo{}
+		mr.On("SelectCheckpoint", ctx, from, to).Return(time.Time{}, nil)
+		mr.On("InsertDelegations", ctx, dgs).Return(entity.InsertSummary{}, errors.New("err"))
+
+		ma := &mockAPI{}
+		ma.On("StreamDelegations", ctx, entity.DelegationRange{From: from, To: day1}).Return(dgs, nil)
+
+		_, err := New(mr, ma, 24*time.Hour, log).Run(ctx, from, to)
+		assert.Error(t, err)
+		mr.AssertExpectations(t)
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		cctx, cancel := context.WithCancel(ctx)
+		cancel()
+		mr := &mockRepo{}
+		mr.On("SelectCheckpoint", cctx, from, to).Return(time.Time{}, nil)
+		ma := &mockAPI{}
+
+		_, err := New(mr, ma, 24*time.Hour, log).Run(cctx, from, to)
+		assert.ErrorIs(t, err, context.Canceled)
+		ma.AssertExpectations(t)
+	})
+}
@@ -2,6 +2,9 @@ package delegation
 
 import (
 	"context"
+	"encoding/json"
+	"sync"
+	"time"
 
 	"github.com/frisk038/tezos-delegation-service/domain/entity"
 	"github.com/frisk038/tezos-delegation-service/domain/repository"
@@ -9,13 +12,28 @@ import (
 
 // UseCase represents the use case for managing delegation-related operations.
 type UseCase struct {
-	repo repository.Delegation // The repository used for delegation data access.
+	repo     repository.Delegation // The repository used for delegation data access.
+	countTTL time.Duration         // How long the count of the delegations matching a filter is reused.
+	now      func() time.Time
+
+	mu     sync.Mutex
+	counts map[string]cachedCount // Counts of the delegations by filter.
+}
+
+// cachedCount is a count of delegations along with when it stops being reused.
+type cachedCount struct {
+	count   int64
+	expires time.Time
 }
 
 // New creates a new instance of the UseCase with the provided delegation repository.
-func New(repo repository.Delegation) *UseCase {
+// Counts of delegations are reused for countTTL, they are computed on each call when it isn't positive.
+func New(repo repository.Delegation, countTTL time.Duration) *UseCase {
 	return &UseCase{
-		repo: repo,
+		repo:     repo,
+		countTTL: countTTL,
+		now:      time.Now,
+		counts:   map[string]cachedCount{},
 	}
 }
 
@@ -24,3 +42,103 @@ func New(repo repository.Delegation) *UseCase {
 func (uc *UseCase) GetDelegations(ctx context.Context, drq entity.DelegationRequest) ([]entity.Delegation, error) {
 	return uc.repo.SelectDelegations(ctx, drq)
 }
+
+// CountDelegations returns how many delegations match the filters of the request, regardless of its pagination and sort.
+// Counting a large table is slow, so the count of each filter is reused for countTTL.
+func (uc *UseCase) CountDelegations(ctx context.Context, drq entity.DelegationRequest) (int64, error) {
+	if uc.countTTL <= 0 {
+		return uc.repo.CountDelegations(ctx, drq)
+	}
+
+	drq.Limit, drq.Offset, drq.After = 0, 0, entity.DelegationCursor{}
+	drq.Sort, drq.Order = "", ""
+	key, err := json.Marshal(drq)
+	if err != nil {
+		return 0, err
+	}
+
+	now := uc.now()
+	uc.mu.Lock()
+	cached, ok := uc.counts[string(key)]
+	uc.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.count, nil
+	}
+
+	count, err := uc.repo.CountDelegations(ctx, drq)
+	if err != nil {
+		return 0, err
+	}
+
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	for k, c := range uc.counts {
+		if !now.Before(c.expires) {
+			delete(uc.counts, k)
+		}
+	}
+	uc.counts[string(key)] = cachedCount{count: count, expires: now.Add(uc.countTTL)}
+
+	return count, nil
+}
+
+// ExportDelegations hands every delegation matching the filters of the request to onRow, one at a time,
+// regardless of its pagination.
+func (uc *UseCase) ExportDelegations(ctx context.Context, drq entity.DelegationRequest, onRow func(dg entity.Delegation) error) error {
+	return uc.repo.ExportDelegations(ctx, drq, onRow)
+}
+
+// GetStats retrieves the aggregates of the applied delegations by period, from the oldest period to the newest.
+// Periods without any delegation are left out.
+func (uc *UseCase) GetStats(ctx context.Context, rq entity.StatsRequest) ([]entity.DelegationStats, error) {
+	return uc.repo.SelectDelegationStats(ctx, rq)
+}
+
+// GetDelegator retrieves the current delegation of a delegator.
+// It returns entity.ErrNotFound when no applied operation of the delegator is stored.
+func (uc *UseCase) GetDelegator(ctx context.Context, delegator string) (entity.DelegatorState, error) {
+	return uc.repo.SelectDelegatorState(ctx, delegator)
+}
+
+// GetDelegatorHistory retrieves the delegation operations of a delegator, from the oldest to the newest.
+func (uc *UseCase) GetDelegatorHistory(ctx context.Context, rq entity.DelegatorHistoryRequest) ([]entity.Delegation, error) {
+	return uc.repo.SelectDelegatorHistory(ctx, rq)
+}
+
+// GetBakerDelegators retrieves a page of the current delegators of a baker, along with their count and delegated amount.
+func (uc *UseCase) GetBakerDelegators(ctx context.Context, rq entity.BakerDelegatorsRequest) (entity.BakerDelegators, error) {
+	dgs, err := uc.repo.SelectBakerDelegators(ctx, rq)
+	if err != nil {
+		return entity.BakerDelegators{}, err
+	}
+
+	count, total, err := uc.repo.SelectBakerTotals(ctx, rq.Baker)
+	if err != nil {
+		return entity.BakerDelegators{}, err
+	}
+
+	return entity.BakerDelegators{
+		Delegators: dgs,
+		Count:      count,
+		Total:      total,
+	}, nil
+}
+
+// GetBakerSnapshot retrieves all the delegators of a baker as of a past timestamp or level, rebuilt from the stored history,
+// along with their count and delegated amount at that point.
+func (uc *UseCase) GetBakerSnapshot(ctx context.Context, rq entity.BakerSnapshotRequest) (entity.BakerDelegators, error) {
+	dgs, err := uc.repo.SelectBakerSnapshot(ctx, rq)
+	if err != nil {
+		return entity.BakerDelegators{}, err
+	}
+
+	snapshot := entity.BakerDelegators{
+		Delegators: dgs,
+		Count:      int64(len(dgs)),
+	}
+	for _, dg := range dgs {
+		snapshot.Total += dg.Amount
+	}
+
+	return snapshot, nil
+}
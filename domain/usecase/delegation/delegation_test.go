@@ -25,6 +25,52 @@ func (mr *mockRepo) SelectDelegations(ctx context.Context, dgr entity.Delegation
 	return called.Get(0).([]entity.Delegation), called.Error(1)
 }
 
+func (mr *mockRepo) SelectDelegatorState(ctx context.Context, delegator string) (entity.DelegatorState, error) {
+	called := mr.Called(ctx, delegator)
+	return called.Get(0).(entity.DelegatorState), called.Error(1)
+}
+
+func (mr *mockRepo) SelectDelegatorHistory(ctx context.Context, rq entity.DelegatorHistoryRequest) ([]entity.Delegation, error) {
+	called := mr.Called(ctx, rq)
+	return called.Get(0).([]entity.Delegation), called.Error(1)
+}
+
+func (mr *mockRepo) SelectBakerDelegators(ctx context.Context, rq entity.BakerDelegatorsRequest) ([]entity.DelegatorState, error) {
+	called := mr.Called(ctx, rq)
+	return called.Get(0).([]entity.DelegatorState), called.Error(1)
+}
+
+func (mr *mockRepo) SelectBakerTotals(ctx context.Context, baker string) (int64, int64, error) {
+	called := mr.Called(ctx, baker)
+	return called.Get(0).(int64), called.Get(1).(int64), called.Error(2)
+}
+
+func (mr *mockRepo) SelectBakerSnapshot(ctx context.Context, rq entity.BakerSnapshotRequest) ([]entity.DelegatorState, error) {
+	called := mr.Called(ctx, rq)
+	return called.Get(0).([]entity.DelegatorState), called.Error(1)
+}
+
+func (mr *mockRepo) CountDelegations(ctx context.Context, dgr entity.DelegationRequest) (int64, error) {
+	called := mr.Called(ctx, dgr)
+	return called.Get(0).(int64), called.Error(1)
+}
+
+func (mr *mockRepo) SelectDelegationStats(ctx context.Context, rq entity.StatsRequest) ([]entity.DelegationStats, error) {
+	called := mr.Called(ctx, rq)
+	return called.Get(0).([]entity.DelegationStats), called.Error(1)
+}
+
+// ExportDelegations hands each of the returned delegations to onRow before returning the error, if any.
+func (mr *mockRepo) ExportDelegations(ctx context.Context, dgr entity.DelegationRequest, onRow func(dg entity.Delegation) error) error {
+	called := mr.Called(ctx, dgr)
+	for _, dg := range called.Get(0).([]entity.Delegation) {
+		if err := onRow(dg); err != nil {
+			return err
+		}
+	}
+	return called.Error(1)
+}
+
 func TestUseCase_GetDelegations(t *testing.T) {
 	ctx := context.Background()
 	tn := time.Now().Truncate(time.Millisecond)
@@ -52,7 +98,7 @@ func TestUseCase_GetDelegations(t *testing.T) {
 		mr := &mockRepo{}
 		mr.On("SelectDelegations", ctx, dgr).Return(dgs, nil)
 
-		uc := New(mr)
+		uc := New(mr, 0)
 		got, err := uc.GetDelegations(ctx, dgr)
 
 		assert.NoError(t, err)
@@ -63,7 +109,7 @@ func TestUseCase_GetDelegations(t *testing.T) {
 		mr := &mockRepo{}
 		mr.On("SelectDelegations", ctx, dgr).Return([]entity.Delegation(nil), errors.New("err"))
 
-		uc := New(mr)
+		uc := New(mr, 0)
 		got, err := uc.GetDelegations(ctx, dgr)
 
 		assert.Error(t, err)
@@ -71,3 +117,324 @@ func TestUseCase_GetDelegations(t *testing.T) {
 		mr.AssertExpectations(t)
 	})
 }
+
+func TestUseCase_GetDelegator(t *testing.T) {
+	ctx := context.Background()
+	state := entity.DelegatorState{
+		Delegator:  "tz1Sender1",
+		Baker:      "tz1Baker1",
+		Since:      time.Now().Truncate(time.Millisecond),
+		SinceLevel: 4000001,
+		LastId:     3034,
+		Amount:     1000034,
+	}
+
+	t.Run("success", func(t *testing.T) {
+		mr := &mockRepo{}
+		mr.On("SelectDelegatorState", ctx, "tz1Sender1").Return(state, nil)
+
+		got, err := New(mr, 0).GetDelegator(ctx, "tz1Sender1")
+		assert.NoError(t, err)
+		assert.Equal(t, state, got)
+		mr.AssertExpectations(t)
+	})
+
+	t.Run("not_found", func(t *testing.T) {
+		mr := &mockRepo{}
+		mr.On("SelectDelegatorState", ctx, "tz1Sender2").Return(entity.DelegatorState{}, entity.ErrNotFound)
+
+		_, err := New(mr, 0).GetDelegator(ctx, "tz1Sender2")
+		assert.ErrorIs(t, err, entity.ErrNotFound)
+		mr.AssertExpectations(t)
+	})
+}
+
+func TestUseCase_GetDelegatorHistory(t *testing.T) {
+	ctx := context.Background()
+	tn := time.Now().Truncate(time.Millisecond)
+	dgs := []entity.Delegation{
+		{
+			Amount:    1234,
+			Block:     "block1",
+			Id:        3034,
+			Delegator: "tz1Sender1",
+			TimeStamp: tn,
+		},
+		{
+			Amount:    1000034,
+			Block:     "block2",
+			Id:        30004,
+			Delegator: "tz1Sender1",
+			TimeStamp: tn.Add(time.Minute),
+		},
+	}
+	rq := entity.DelegatorHistoryRequest{Delegator: "tz1Sender1", Limit: 2}
+
+	t.Run("success", func(t *testing.T) {
+		mr := &mockRepo{}
+		mr.On("SelectDelegatorHistory", ctx, rq).Return(dgs, nil)
+
+		got, err := New(mr, 0).GetDelegatorHistory(ctx, rq)
+		assert.NoError(t, err)
+		assert.Equal(t, dgs, got)
+		mr.AssertExpectations(t)
+	})
+
+	t.Run("err", func(t *testing.T) {
+		mr := &mockRepo{}
+		mr.On("SelectDelegatorHistory", ctx, rq).Return([]entity.Delegation(nil), errors.New("err"))
+
+		got, err := New(mr, 0).GetDelegatorHistory(ctx, rq)
+		assert.Error(t, err)
+		assert.Nil(t, got)
+		mr.AssertExpectations(t)
+	})
+}
+
+func TestUseCase_GetBakerDelegators(t *testing.T) {
+	ctx := context.Background()
+	tn := time.Now().Truncate(time.Millisecond)
+	states := []entity.DelegatorState{
+		{
+			Delegator: "tz1Sender1",
+			Baker:     "tz1Baker1",
+			Since:     tn,
+			LastId:    3034,
+			Amount:    1000034,
+		},
+	}
+	rq := entity.BakerDelegatorsRequest{Baker: "tz1Baker1", Limit: 1, Sort: entity.SortAmount}
+
+	t.Run("success", func(t *testing.T) {
+		mr := &mockRepo{}
+		mr.On("SelectBakerDelegators", ctx, rq).Return(states, nil)
+		mr.On("SelectBakerTotals", ctx, "tz1Baker1").Return(int64(2), int64(1001268), nil)
+
+		got, err := New(mr, 0).GetBakerDelegators(ctx, rq)
+		assert.NoError(t, err)
+		assert.Equal(t, entity.BakerDelegators{Delegators: states, Count: 2, Total: 1001268}, got)
+		mr.AssertExpectations(t)
+	})
+
+	t.Run("delegators_err", func(t *testing.T) {
+		mr := &mockRepo{}
+		mr.On("SelectBakerDelegators", ctx, rq).Return([]entity.DelegatorState(nil), errors.New("err"))
+
+		_, err := New(mr, 0).GetBakerDelegators(ctx, rq)
+		assert.Error(t, err)
+		mr.AssertExpectations(t)
+	})
+
+	t.Run("totals_err", func(t *testing.T) {
+		mr := &mockRepo{}
+		mr.On("SelectBakerDelegators", ctx, rq).Return(states, nil)
+		mr.On("SelectBakerTotals", ctx, "tz1Baker1").Return(int64(0), int64(0), errors.New("err"))
+
+		_, err := New(mr, 0).GetBakerDelegators(ctx, rq)
+		assert.Error(t, err)
+		mr.AssertExpectations(t)
+	})
+}
+
+func TestUseCase_GetBakerSnapshot(t *testing.T) {
+	ctx := context.Background()
+	tn := time.Now().Truncate(time.Millisecond)
+	states := []entity.DelegatorState{
+		{
+			Delegator: "tz1Sender1",
+			Baker:     "tz1Baker1",
+			Since:     tn,
+			LastId:    3034,
+			Amount:    1000034,
+		},
+		{
+			Delegator: "tz1Sender2",
+			Baker:     "tz1Baker1",
+			Since:     tn,
+			LastId:    3035,
+			Amount:    1234,
+		},
+	}
+	rq := entity.BakerSnapshotRequest{Baker: "tz1Baker1", Level: 4000001}
+
+	t.Run("success", func(t *testing.T) {
+		mr := &mockRepo{}
+		mr.On("SelectBakerSnapshot", ctx, rq).Return(states, nil)
+
+		got, err := New(mr, 0).GetBakerSnapshot(ctx, rq)
+		assert.NoError(t, err)
+		assert.Equal(t, entity.BakerDelegators{Delegators: states, Count: 2, Total: 1001268}, got)
+		mr.AssertExpectations(t)
+	})
+
+	t.Run("no_delegators", func(t *testing.T) {
+		mr := &mockRepo{}
+		mr.On("SelectBakerSnapshot", ctx, rq).Return([]entity.DelegatorState(nil), nil)
+
+		got, err := New(mr, 0).GetBakerSnapshot(ctx, rq)
+		assert.NoError(t, err)
+		assert.Equal(t, entity.BakerDelegators{}, got)
+		mr.AssertExpectations(t)
+	})
+
+	t.Run("repo_err", func(t *testing.T) {
+		mr := &mockRepo{}
+		mr.On("SelectBakerSnapshot", ctx, rq).Return([]entity.DelegatorState(nil), errors.New("err"))
+
+		_, err := New(mr, 0).GetBakerSnapshot(ctx, rq)
+		assert.Error(t, err)
+		mr.AssertExpectations(t)
+	})
+}
+
+func TestUseCase_CountDelegations(t *testing.T) {
+	ctx := context.Background()
+	tn := time.Now()
+	filter := entity.DelegationRequest{Kind: entity.KindDelegate, Status: entity.StatusApplied}
+	page := func(offset int) entity.DelegationRequest {
+		drq := filter
+		drq.Limit = 10
+		drq.Offset = offset
+		drq.Sort = entity.SortAmount
+		return drq
+	}
+
+	t.Run("cached", func(t *testing.T) {
+		mr := &mockRepo{}
+		mr.On("CountDelegations", ctx, filter).Return(int64(42), nil).Once()
+
+		uc := New(mr, time.Minute)
+		uc.now = func() time.Time { return tn }
+		got, err := uc.CountDelegations(ctx, page(0))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(42), got)
+
+		// Another page of the same filter reuses the count.
+		got, err = uc.CountDelegations(ctx, page(10))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(42), got)
+		mr.AssertExpectations(t)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		mr := &mockRepo{}
+		mr.On("CountDelegations", ctx, filter).Return(int64(42), nil).Once()
+		mr.On("CountDelegations", ctx, filter).Return(int64(43), nil).Once()
+
+		uc := New(mr, time.Minute)
+		uc.now = func() time.Time { return tn }
+		_, err := uc.CountDelegations(ctx, page(0))
+		assert.NoError(t, err)
+
+		uc.now = func() time.Time { return tn.Add(time.Minute) }
+		got, err := uc.CountDelegations(ctx, page(0))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(43), got)
+		mr.AssertExpectations(t)
+	})
+
+	t.Run("by_filter", func(t *testing.T) {
+		other := filter
+		other.Kind = entity.KindUndelegate
+		mr := &mockRepo{}
+		mr.On("CountDelegations", ctx, filter).Return(int64(42), nil).Once()
+		mr.On("CountDelegations", ctx, other).Return(int64(7), nil).Once()
+
+		uc := New(mr, time.Minute)
+		_, err := uc.CountDelegations(ctx, filter)
+		assert.NoError(t, err)
+		got, err := uc.CountDelegations(ctx, other)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(7), got)
+		mr.AssertExpectations(t)
+	})
+
+	t.Run("not_cached", func(t *testing.T) {
+		mr := &mockRepo{}
+		mr.On("CountDelegations", ctx, page(0)).Return(int64(42), nil).Twice()
+
+		uc := New(mr, 0)
+		for i := 0; i < 2; i++ {
+			got, err := uc.CountDelegations(ctx, page(0))
+			assert.NoError(t, err)
+			assert.Equal(t, int64(42), got)
+		}
+		mr.AssertExpectations(t)
+	})
+
+	t.Run("repo_err", func(t *testing.T) {
+		mr := &mockRepo{}
+		mr.On("CountDelegations", ctx, filter).Return(int64(0), errors.New("err")).Twice()
+
+		// A failure isn't cached.
+		uc := New(mr, time.Minute)
+		for i := 0; i < 2; i++ {
+			_, err := uc.CountDelegations(ctx, filter)
+			assert.Error(t, err)
+		}
+		mr.AssertExpectations(t)
+	})
+}
+
+func TestUseCase_GetStats(t *testing.T) {
+	ctx := context.Background()
+	day := time.Date(2023, 9, 16, 0, 0, 0, 0, time.UTC)
+	rq := entity.StatsRequest{Bucket: entity.BucketDay, From: day}
+	stats := []entity.DelegationStats{
+		{Bucket: day, Count: 3, Delegators: 2, Total: 1002268, Median: 1000},
+	}
+
+	t.Run("success", func(t *testing.T) {
+		mr := &mockRepo{}
+		mr.On("SelectDelegationStats", ctx, rq).Return(stats, nil)
+
+		got, err := New(mr, 0).GetStats(ctx, rq)
+		assert.NoError(t, err)
+		assert.Equal(t, stats, got)
+		mr.AssertExpectations(t)
+	})
+
+	t.Run("repo_err", func(t *testing.T) {
+		mr := &mockRepo{}
+		mr.On("SelectDelegationStats", ctx, rq).Return([]entity.DelegationStats(nil), errors.New("err"))
+
+		_, err := New(mr, 0).GetStats(ctx, rq)
+		assert.Error(t, err)
+		mr.AssertExpectations(t)
+	})
+}
+
+func TestUseCase_ExportDelegations(t *testing.T) {
+	ctx := context.Background()
+	dgs := []entity.Delegation{
+		{Amount: 1000034, Block: "block2", Id: 3034, Delegator: "dg2"},
+		{Amount: 1234, Block: "block1", Id: 30004, Delegator: "dg1"},
+	}
+	drq := entity.DelegationRequest{Baker: "tz1Baker1", Status: entity.StatusApplied}
+
+	t.Run("success", func(t *testing.T) {
+		mr := &mockRepo{}
+		mr.On("ExportDelegations", ctx, drq).Return(dgs, nil)
+
+		var got []entity.Delegation
+		err := New(mr, 0).ExportDelegations(ctx, drq, func(dg entity.Delegation) error {
+			got = append(got, dg)
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, dgs, got)
+		mr.AssertExpectations(t)
+	})
+
+	t.Run("row_err", func(t *testing.T) {
+		mr := &mockRepo{}
+		mr.On("ExportDelegations", ctx, drq).Return(dgs, nil)
+
+		rowErr := errors.New("err")
+		err := New(mr, 0).ExportDelegations(ctx, drq, func(dg entity.Delegation) error {
+			return rowErr
+		})
+		assert.ErrorIs(t, err, rowErr)
+		mr.AssertExpectations(t)
+	})
+}
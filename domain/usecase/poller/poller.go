@@ -5,44 +5,178 @@ import (
 	"time"
 
 	"github.com/frisk038/tezos-delegation-service/domain/adapter"
+	"github.com/frisk038/tezos-delegation-service/domain/entity"
 	"github.com/frisk038/tezos-delegation-service/domain/repository"
+	"golang.org/x/exp/slog"
 )
 
 // UseCase represents the use case for polling and processing delegation data.
 type UseCase struct {
-	repo repository.Poller // The repository used for delegation data storage.
-	api  adapter.API       // The external API adapter for fetching delegation data.
+	repo       repository.Poller // The repository used for delegation data storage.
+	api        adapter.API       // The external API adapter for fetching delegation data.
+	pub        adapter.Publisher // Notified of the delegations once stored.
+	reorgDepth int               // The count of most recent stored levels verified against the API on each run.
+	log        *slog.Logger
 }
 
-// New creates a new instance of the UseCase with the provided repository and API adapter.
-func New(repo repository.Poller, api adapter.API) *UseCase {
+// New creates a new instance of the UseCase with the provided repository, API adapter and publisher.
+// When reorgDepth is positive, the last reorgDepth stored levels are verified on each Fetch.
+func New(repo repository.Poller, api adapter.API, pub adapter.Publisher, reorgDepth int, log *slog.Logger) *UseCase {
 	return &UseCase{
-		repo: repo,
-		api:  api,
+		repo:       repo,
+		api:        api,
+		pub:        pub,
+		reorgDepth: reorgDepth,
+		log:        log,
 	}
 }
 
-// Fetch retrieves and processes delegation data from an external API.
-// It takes a context and returns an error if any operation encounters an error.
-func (uc *UseCase) Fetch(ctx context.Context) error {
-	lastDttm, err := uc.repo.SelectLastDelegation(ctx)
+// Fetch retrieves and processes delegation data from an external API, resuming after the last stored delegation id.
+// It takes a context and returns a summary of the stored delegations, along with an error if any operation encounters one:
+// the pages stored before the failure are kept and counted in the summary.
+func (uc *UseCase) Fetch(ctx context.Context) (entity.InsertSummary, error) {
+	lastId, err := uc.repo.SelectLastDelegationId(ctx)
 	if err != nil {
-		return err
+		return entity.InsertSummary{}, err
 	}
-	if lastDttm.IsZero() {
+
+	var summary entity.InsertSummary
+	if lastId != 0 && uc.reorgDepth > 0 {
+		summary, err = uc.rollbackReorg(ctx)
+		if err != nil {
+			return entity.InsertSummary{}, err
+		}
+
+		// Orphaned delegations may have been removed, resume after what is left.
+		lastId, err = uc.repo.SelectLastDelegationId(ctx)
+		if err != nil {
+			return entity.InsertSummary{}, err
+		}
+	}
+
+	// On an empty table, start from midnight UTC of today.
+	rg := entity.DelegationRange{LastId: lastId}
+	if lastId == 0 {
 		now := time.Now()
-		lastDttm = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		rg.From = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	}
+
+	// Each page is committed as soon as it is received, a failure only loses the pages not stored yet.
+	err = uc.api.StreamDelegations(ctx, rg, func(ctx context.Context, dgs []entity.Delegation) error {
+		inserted, err := uc.store(ctx, dgs)
+		if err != nil {
+			return err
+		}
+		summary.Add(inserted)
+		uc.log.Info("delegations page stored",
+			"count", len(dgs),
+			"last_id", dgs[len(dgs)-1].Id,
+			"inserted", inserted.Inserted,
+			"updated", inserted.Updated,
+			"skipped", inserted.Skipped)
+		return nil
+	})
+
+	return summary, err
+}
+
+// rollbackReorg compares the delegations of the most recent stored levels with the API.
+// Delegations whose block is no longer part of the chain are replaced by the API version of these levels,
+// in a single transaction, then retracted before the API version is published.
+func (uc *UseCase) rollbackReorg(ctx context.Context) (entity.InsertSummary, error) {
+	stored, err := uc.repo.SelectRecentDelegations(ctx, uc.reorgDepth)
+	if err != nil || len(stored) == 0 {
+		return entity.InsertSummary{}, err
 	}
 
-	dgs, err := uc.api.GetDelegations(ctx, lastDttm)
+	since := stored[0].TimeStamp
+	for _, dg := range stored {
+		if dg.TimeStamp.Before(since) {
+			since = dg.TimeStamp
+		}
+	}
+
+	fresh, err := uc.api.GetDelegations(ctx, entity.DelegationRange{From: since})
 	if err != nil {
-		return err
+		return entity.InsertSummary{}, err
 	}
 
-	// If there are n new delegations, return without further processing.
-	if len(dgs) == 0 {
-		return nil
+	blocks := make(map[int64]string, len(fresh))
+	for _, dg := range fresh {
+		blocks[dg.Id] = dg.Block
 	}
 
-	return uc.repo.InsertDelegations(ctx, dgs)
+	// The depth of the reorganization goes from the lowest orphaned level up to the highest stored one.
+	var orphans []entity.Delegation
+	var orphanIds []int64
+	var top, lowest int64
+	for _, dg := range stored {
+		if dg.Level > top {
+			top = dg.Level
+		}
+		if block, ok := blocks[dg.Id]; !ok || block != dg.Block {
+			orphans = append(orphans, dg)
+			orphanIds = append(orphanIds, dg.Id)
+			if lowest == 0 || dg.Level < lowest {
+				lowest = dg.Level
+			}
+		}
+	}
+	if len(orphanIds) == 0 {
+		return entity.InsertSummary{}, nil
+	}
+
+	deleted, summary, err := uc.repo.ReplaceDelegations(ctx, orphanIds, fresh)
+	if err != nil {
+		return entity.InsertSummary{}, err
+	}
+	uc.log.Warn("chain reorganization detected",
+		"depth", top-lowest+1,
+		"level", lowest,
+		"deleted", deleted)
+	uc.pub.Retract(orphans)
+	if len(fresh) != 0 {
+		uc.pub.Publish(fresh)
+	}
+
+	return summary, nil
+}
+
+// store inserts a batch of delegations and publishes them once committed.
+func (uc *UseCase) store(ctx context.Context, dgs []entity.Delegation) (entity.InsertSummary, error) {
+	summary, err := uc.repo.InsertDelegations(ctx, dgs)
+	if err != nil {
+		return entity.InsertSummary{}, err
+	}
+	uc.pub.Publish(dgs)
+
+	return summary, nil
+}
+
+// Listen stores the delegations pushed by the subscriber as they arrive, until ctx is done.
+// Each time the subscription is established again, Fetch catches up with whatever was missed meanwhile.
+func (uc *UseCase) Listen(ctx context.Context, sub adapter.Subscriber) error {
+	return sub.Subscribe(ctx,
+		func(ctx context.Context) error {
+			summary, err := uc.Fetch(ctx)
+			if err != nil {
+				return err
+			}
+			uc.log.Info("delegations caught up",
+				"inserted", summary.Inserted,
+				"updated", summary.Updated,
+				"skipped", summary.Skipped)
+			return nil
+		},
+		func(ctx context.Context, dgs []entity.Delegation) error {
+			summary, err := uc.store(ctx, dgs)
+			if err != nil {
+				return err
+			}
+			uc.log.Info("delegations received",
+				"inserted", summary.Inserted,
+				"updated", summary.Updated,
+				"skipped", summary.Skipped)
+			return nil
+		})
 }
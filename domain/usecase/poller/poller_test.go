@@ -3,40 +3,81 @@ package poller
 import (
 	"context"
 	"errors"
+	"io"
 	"testing"
 	"time"
 
+	"github.com/frisk038/tezos-delegation-service/domain/adapter"
 	"github.com/frisk038/tezos-delegation-service/domain/entity"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"golang.org/x/exp/slog"
 )
 
 type mockRepo struct {
 	mock.Mock
 }
 
-func (mr *mockRepo) InsertDelegations(ctx context.Context, dgs []entity.Delegation) error {
-	return mr.Called(ctx, dgs).Error(0)
+func (mr *mockRepo) InsertDelegations(ctx context.Context, dgs []entity.Delegation) (entity.InsertSummary, error) {
+	called := mr.Called(ctx, dgs)
+	return called.Get(0).(entity.InsertSummary), called.Error(1)
 }
 
-func (mr *mockRepo) SelectLastDelegation(ctx context.Context) (time.Time, error) {
+func (mr *mockRepo) SelectLastDelegationId(ctx context.Context) (int64, error) {
 	called := mr.Called(ctx)
-	return called.Get(0).(time.Time), called.Error(1)
+	return called.Get(0).(int64), called.Error(1)
+}
+
+func (mr *mockRepo) SelectRecentDelegations(ctx context.Context, levels int) ([]entity.Delegation, error) {
+	called := mr.Called(ctx, levels)
+	return called.Get(0).([]entity.Delegation), called.Error(1)
+}
+
+func (mr *mockRepo) ReplaceDelegations(ctx context.Context, ids []int64, dgs []entity.Delegation) (int64, entity.InsertSummary, error) {
+	called := mr.Called(ctx, ids, dgs)
+	return called.Get(0).(int64), called.Get(1).(entity.InsertSummary), called.Error(2)
+}
+
+// fakePublisher records the published and retracted delegations.
+type fakePublisher struct {
+	published [][]entity.Delegation
+	retracted [][]entity.Delegation
+}
+
+func (fp *fakePublisher) Publish(dgs []entity.Delegation) {
+	fp.published = append(fp.published, dgs)
+}
+
+func (fp *fakePublisher) Retract(dgs []entity.Delegation) {
+	fp.retracted = append(fp.retracted, dgs)
 }
 
 type mockAPI struct {
 	mock.Mock
 }
 
-func (ma *mockAPI) GetDelegations(ctx context.Context, startTime time.Time) ([]entity.Delegation, error) {
-	called := ma.Called(ctx, startTime)
+func (ma *mockAPI) GetDelegations(ctx context.Context, rg entity.DelegationRange) ([]entity.Delegation, error) {
+	called := ma.Called(ctx, rg)
 	return called.Get(0).([]entity.Delegation), called.Error(1)
 }
 
+// StreamDelegations hands each of the returned pages to onPage before returning the error, if any.
+func (ma *mockAPI) StreamDelegations(ctx context.Context, rg entity.DelegationRange, onPage adapter.PageFunc) error {
+	called := ma.Called(ctx, rg)
+	for _, page := range called.Get(0).([][]entity.Delegation) {
+		if err := onPage(ctx, page); err != nil {
+			return err
+		}
+	}
+	return called.Error(1)
+}
+
 func TestPoller_Fetch(t *testing.T) {
 	ctx := context.Background()
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
 	tn := time.Now()
-	preTn := tn.Add(-2 * time.Minute)
+	lastId := int64(3000)
+	summary := entity.InsertSummary{Inserted: 1, Skipped: 1}
 	dgs := []entity.Delegation{
 		{
 			Amount:    1000034,
@@ -56,78 +97,347 @@ func TestPoller_Fetch(t *testing.T) {
 
 	t.Run("success", func(t *testing.T) {
 		mr := &mockRepo{}
-		mr.On("SelectLastDelegation", ctx).Return(preTn, nil)
-		mr.On("InsertDelegations", ctx, dgs).Return(nil)
+		mr.On("SelectLastDelegationId", ctx).Return(lastId, nil)
+		mr.On("InsertDelegations", ctx, dgs).Return(summary, nil)
+
+		ma := &mockAPI{}
+		ma.On("StreamDelegations", ctx, entity.DelegationRange{LastId: lastId}).Return([][]entity.Delegation{dgs}, nil)
+		fp := &fakePublisher{}
+		p := New(mr, ma, fp, 0, log)
+
+		got, err := p.Fetch(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, summary, got)
+		assert.Equal(t, [][]entity.Delegation{dgs}, fp.published)
+	})
+
+	t.Run("page_by_page", func(t *testing.T) {
+		mr := &mockRepo{}
+		mr.On("SelectLastDelegationId", ctx).Return(lastId, nil)
+		mr.On("InsertDelegations", ctx, dgs[:1]).Return(entity.InsertSummary{Inserted: 1}, nil).Once()
+		mr.On("InsertDelegations", ctx, dgs[1:]).Return(entity.InsertSummary{Skipped: 1}, nil).Once()
 
 		ma := &mockAPI{}
-		ma.On("GetDelegations", ctx, preTn).Return(dgs, nil)
-		p := New(mr, ma)
+		ma.On("StreamDelegations", ctx, entity.DelegationRange{LastId: lastId}).
+			Return([][]entity.Delegation{dgs[:1], dgs[1:]}, nil)
+		p := New(mr, ma, &fakePublisher{}, 0, log)
 
-		err := p.Fetch(ctx)
+		got, err := p.Fetch(ctx)
 		assert.NoError(t, err)
+		assert.Equal(t, summary, got)
+		mr.AssertExpectations(t)
+	})
+
+	t.Run("api_err_keeps_stored_pages", func(t *testing.T) {
+		mr := &mockRepo{}
+		mr.On("SelectLastDelegationId", ctx).Return(lastId, nil)
+		mr.On("InsertDelegations", ctx, dgs[:1]).Return(entity.InsertSummary{Inserted: 1}, nil).Once()
+
+		ma := &mockAPI{}
+		ma.On("StreamDelegations", ctx, entity.DelegationRange{LastId: lastId}).
+			Return([][]entity.Delegation{dgs[:1]}, errors.New("err"))
+		p := New(mr, ma, &fakePublisher{}, 0, log)
+
+		got, err := p.Fetch(ctx)
+		assert.Error(t, err)
+		assert.Equal(t, entity.InsertSummary{Inserted: 1}, got)
+		mr.AssertExpectations(t)
 	})
 
-	t.Run("last_date_empty", func(t *testing.T) {
+	t.Run("no_last_id", func(t *testing.T) {
 		mr := &mockRepo{}
-		mr.On("SelectLastDelegation", ctx).Return(time.Time{}, nil)
-		mr.On("InsertDelegations", ctx, dgs).Return(nil)
+		mr.On("SelectLastDelegationId", ctx).Return(int64(0), nil)
+		mr.On("InsertDelegations", ctx, dgs).Return(summary, nil)
 		ma := &mockAPI{}
-		ma.On("GetDelegations",
+		ma.On("StreamDelegations",
 			ctx,
-			time.Date(tn.Year(), tn.Month(), tn.Day(), 0, 0, 0, 0, time.UTC),
-		).Return(dgs, nil)
-		p := New(mr, ma)
+			entity.DelegationRange{From: time.Date(tn.Year(), tn.Month(), tn.Day(), 0, 0, 0, 0, time.UTC)},
+		).Return([][]entity.Delegation{dgs}, nil)
+		p := New(mr, ma, &fakePublisher{}, 0, log)
 
-		err := p.Fetch(ctx)
+		got, err := p.Fetch(ctx)
 		assert.NoError(t, err)
+		assert.Equal(t, summary, got)
 	})
 
-	t.Run("last_date_err", func(t *testing.T) {
+	t.Run("last_id_err", func(t *testing.T) {
 		mr := &mockRepo{}
-		mr.On("SelectLastDelegation", ctx).Return(time.Time{}, errors.New("err"))
+		mr.On("SelectLastDelegationId", ctx).Return(int64(0), errors.New("err"))
 
 		ma := &mockAPI{}
-		p := New(mr, ma)
+		p := New(mr, ma, &fakePublisher{}, 0, log)
 
-		err := p.Fetch(ctx)
+		got, err := p.Fetch(ctx)
 		assert.Error(t, err)
+		assert.Empty(t, got)
 	})
 
 	t.Run("api_err", func(t *testing.T) {
 		mr := &mockRepo{}
-		mr.On("SelectLastDelegation", ctx).Return(preTn, nil)
-		mr.On("InsertDelegations", ctx, dgs).Return(nil)
+		mr.On("SelectLastDelegationId", ctx).Return(lastId, nil)
 
 		ma := &mockAPI{}
-		ma.On("GetDelegations", ctx, preTn).Return(dgs, errors.New("err"))
-		p := New(mr, ma)
+		ma.On("StreamDelegations", ctx, entity.DelegationRange{LastId: lastId}).
+			Return([][]entity.Delegation(nil), errors.New("err"))
+		p := New(mr, ma, &fakePublisher{}, 0, log)
 
-		err := p.Fetch(ctx)
+		got, err := p.Fetch(ctx)
 		assert.Error(t, err)
+		assert.Empty(t, got)
 	})
 
 	t.Run("api_empty", func(t *testing.T) {
 		mr := &mockRepo{}
-		mr.On("SelectLastDelegation", ctx).Return(preTn, nil)
+		mr.On("SelectLastDelegationId", ctx).Return(lastId, nil)
 
 		ma := &mockAPI{}
-		ma.On("GetDelegations", ctx, preTn).Return([]entity.Delegation{}, nil)
-		p := New(mr, ma)
+		ma.On("StreamDelegations", ctx, entity.DelegationRange{LastId: lastId}).Return([][]entity.Delegation(nil), nil)
+		p := New(mr, ma, &fakePublisher{}, 0, log)
 
-		err := p.Fetch(ctx)
+		got, err := p.Fetch(ctx)
 		assert.NoError(t, err)
+		assert.Empty(t, got)
+	})
+
+	t.Run("insert_err", func(t *testing.T) {
+		mr := &mockRepo{}
+		mr.On("SelectLastDelegationId", ctx).Return(lastId, nil)
+		mr.On("InsertDelegations", ctx, dgs).Return(entity.InsertSummary{}, errors.New("err"))
+
+		ma := &mockAPI{}
+		ma.On("StreamDelegations", ctx, entity.DelegationRange{LastId: lastId}).Return([][]entity.Delegation{dgs}, nil)
+		fp := &fakePublisher{}
+		p := New(mr, ma, fp, 0, log)
+
+		got, err := p.Fetch(ctx)
+		assert.Error(t, err)
+		assert.Empty(t, got)
+		assert.Empty(t, fp.published)
+	})
+}
+
+func TestPoller_FetchReorg(t *testing.T) {
+	ctx := context.Background()
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	tn := time.Now().UTC().Truncate(time.Second)
+	stored := []entity.Delegation{
+		{
+			Amount:    1000034,
+			Block:     "block1",
+			Id:        3034,
+			Delegator: "dg1",
+			TimeStamp: tn,
+			Level:     4000001,
+		},
+		{
+			Amount:    1234,
+			Block:     "block2",
+			Id:        3035,
+			Delegator: "dg2",
+			TimeStamp: tn.Add(time.Minute),
+			Level:     4000002,
+		},
+	}
+
+	t.Run("no_reorg", func(t *testing.T) {
+		mr := &mockRepo{}
+		mr.On("SelectLastDelegationId", ctx).Return(int64(3035), nil)
+		mr.On("SelectRecentDelegations", ctx, 2).Return(stored, nil)
+
+		ma := &mockAPI{}
+		ma.On("GetDelegations", ctx, entity.DelegationRange{From: tn}).Return(stored, nil)
+		ma.On("StreamDelegations", ctx, entity.DelegationRange{LastId: 3035}).Return([][]entity.Delegation(nil), nil)
+		p := New(mr, ma, &fakePublisher{}, 2, log)
+
+		got, err := p.Fetch(ctx)
+		assert.NoError(t, err)
+		assert.Empty(t, got)
+		mr.AssertExpectations(t)
+		ma.AssertExpectations(t)
+	})
+
+	t.Run("reorg", func(t *testing.T) {
+		reorged := stored[1]
+		reorged.Block = "block2bis"
+		fresh := []entity.Delegation{stored[0], reorged}
+
+		mr := &mockRepo{}
+		mr.On("SelectLastDelegationId", ctx).Return(int64(3035), nil).Twice()
+		mr.On("SelectRecentDelegations", ctx, 2).Return(stored, nil)
+		mr.On("ReplaceDelegations", ctx, []int64{3035}, fresh).Return(int64(1), entity.InsertSummary{Inserted: 1, Skipped: 1}, nil)
+
+		ma := &mockAPI{}
+		ma.On("GetDelegations", ctx, entity.DelegationRange{From: tn}).Return(fresh, nil)
+		ma.On("StreamDelegations", ctx, entity.DelegationRange{LastId: 3035}).Return([][]entity.Delegation(nil), nil)
+		fp := &fakePublisher{}
+		p := New(mr, ma, fp, 2, log)
+
+		got, err := p.Fetch(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, entity.InsertSummary{Inserted: 1, Skipped: 1}, got)
+		assert.Equal(t, [][]entity.Delegation{stored[1:]}, fp.retracted)
+		assert.Equal(t, [][]entity.Delegation{fresh}, fp.published)
+		mr.AssertExpectations(t)
+		ma.AssertExpectations(t)
+	})
+
+	t.Run("orphaned_operation", func(t *testing.T) {
+		mr := &mockRepo{}
+		mr.On("SelectLastDelegationId", ctx).Return(int64(3035), nil).Once()
+		mr.On("SelectLastDelegationId", ctx).Return(int64(3034), nil).Once()
+		mr.On("SelectRecentDelegations", ctx, 2).Return(stored, nil)
+		mr.On("ReplaceDelegations", ctx, []int64{3035}, stored[:1]).Return(int64(1), entity.InsertSummary{Skipped: 1}, nil)
+
+		ma := &mockAPI{}
+		ma.On("GetDelegations", ctx, entity.DelegationRange{From: tn}).Return(stored[:1], nil)
+		ma.On("StreamDelegations", ctx, entity.DelegationRange{LastId: 3034}).Return([][]entity.Delegation(nil), nil)
+		fp := &fakePublisher{}
+		p := New(mr, ma, fp, 2, log)
+
+		got, err := p.Fetch(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, entity.InsertSummary{Skipped: 1}, got)
+		assert.Equal(t, [][]entity.Delegation{stored[1:]}, fp.retracted)
+		mr.AssertExpectations(t)
+		ma.AssertExpectations(t)
+	})
+
+	t.Run("whole_block_orphaned", func(t *testing.T) {
+		mr := &mockRepo{}
+		mr.On("SelectLastDelegationId", ctx).Return(int64(3035), nil).Once()
+		mr.On("SelectLastDelegationId", ctx).Return(int64(0), nil).Once()
+		mr.On("SelectRecentDelegations", ctx, 2).Return(stored, nil)
+		mr.On("ReplaceDelegations", ctx, []int64{3034, 3035}, []entity.Delegation(nil)).Return(int64(2), entity.InsertSummary{}, nil)
+
+		ma := &mockAPI{}
+		ma.On("GetDelegations", ctx, entity.DelegationRange{From: tn}).Return([]entity.Delegation(nil), nil)
+		ma.On("StreamDelegations", ctx, mock.Anything).Return([][]entity.Delegation(nil), nil)
+		p := New(mr, ma, &fakePublisher{}, 2, log)
+
+		got, err := p.Fetch(ctx)
+		assert.NoError(t, err)
+		assert.Empty(t, got)
+		mr.AssertExpectations(t)
+		ma.AssertExpectations(t)
+	})
+
+	t.Run("recent_err", func(t *testing.T) {
+		mr := &mockRepo{}
+		mr.On("SelectLastDelegationId", ctx).Return(int64(3035), nil)
+		mr.On("SelectRecentDelegations", ctx, 2).Return([]entity.Delegation(nil), errors.New("err"))
+
+		ma := &mockAPI{}
+		p := New(mr, ma, &fakePublisher{}, 2, log)
+
+		_, err := p.Fetch(ctx)
+		assert.Error(t, err)
+		mr.AssertExpectations(t)
+	})
+
+	t.Run("api_err", func(t *testing.T) {
+		mr := &mockRepo{}
+		mr.On("SelectLastDelegationId", ctx).Return(int64(3035), nil)
+		mr.On("SelectRecentDelegations", ctx, 2).Return(stored, nil)
+
+		ma := &mockAPI{}
+		ma.On("GetDelegations", ctx, entity.DelegationRange{From: tn}).Return([]entity.Delegation(nil), errors.New("err"))
+		p := New(mr, ma, &fakePublisher{}, 2, log)
+
+		_, err := p.Fetch(ctx)
+		assert.Error(t, err)
+		mr.AssertExpectations(t)
+	})
+
+	t.Run("replace_err", func(t *testing.T) {
+		mr := &mockRepo{}
+		mr.On("SelectLastDelegationId", ctx).Return(int64(3035), nil)
+		mr.On("SelectRecentDelegations", ctx, 2).Return(stored, nil)
+		mr.On("ReplaceDelegations", ctx, []int64{3035}, stored[:1]).Return(int64(0), entity.InsertSummary{}, errors.New("err"))
+
+		ma := &mockAPI{}
+		ma.On("GetDelegations", ctx, entity.DelegationRange{From: tn}).Return(stored[:1], nil)
+		fp := &fakePublisher{}
+		p := New(mr, ma, fp, 2, log)
+
+		_, err := p.Fetch(ctx)
+		assert.Error(t, err)
+		assert.Empty(t, fp.retracted)
+		assert.Empty(t, fp.published)
+		mr.AssertExpectations(t)
+	})
+}
+
+// fakeSubscriber calls onSync once then pushes its delegations.
+type fakeSubscriber struct {
+	dgs []entity.Delegation
+}
+
+func (fs *fakeSubscriber) Subscribe(ctx context.Context,
+	onSync func(ctx context.Context) error,
+	onDelegations func(ctx context.Context, dgs []entity.Delegation) error) error {
+	if err := onSync(ctx); err != nil {
+		return err
+	}
+	return onDelegations(ctx, fs.dgs)
+}
+
+func TestPoller_Listen(t *testing.T) {
+	ctx := context.Background()
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	tn := time.Now()
+	dgs := []entity.Delegation{
+		{
+			Amount:    1000034,
+			Block:     "block2",
+			Id:        3034,
+			Delegator: "dg2",
+			TimeStamp: tn,
+		},
+	}
+	missed := []entity.Delegation{
+		{
+			Amount:    1234,
+			Block:     "block1",
+			Id:        3033,
+			Delegator: "dg1",
+			TimeStamp: tn,
+		},
+	}
+
+	t.Run("success", func(t *testing.T) {
+		mr := &mockRepo{}
+		mr.On("SelectLastDelegationId", ctx).Return(int64(3000), nil)
+		mr.On("InsertDelegations", ctx, missed).Return(entity.InsertSummary{Inserted: 1}, nil)
+		mr.On("InsertDelegations", ctx, dgs).Return(entity.InsertSummary{Inserted: 1}, nil)
+
+		ma := &mockAPI{}
+		ma.On("StreamDelegations", ctx, entity.DelegationRange{LastId: 3000}).Return([][]entity.Delegation{missed}, nil)
+
+		err := New(mr, ma, &fakePublisher{}, 0, log).Listen(ctx, &fakeSubscriber{dgs: dgs})
+		assert.NoError(t, err)
+		mr.AssertExpectations(t)
+		ma.AssertExpectations(t)
+	})
+
+	t.Run("catch_up_err", func(t *testing.T) {
+		mr := &mockRepo{}
+		mr.On("SelectLastDelegationId", ctx).Return(int64(0), errors.New("err"))
+
+		err := New(mr, &mockAPI{}, &fakePublisher{}, 0, log).Listen(ctx, &fakeSubscriber{dgs: dgs})
+		assert.Error(t, err)
+		mr.AssertExpectations(t)
 	})
 
 	t.Run("insert_err", func(t *testing.T) {
 		mr := &mockRepo{}
-		mr.On("SelectLastDelegation", ctx).Return(preTn, nil)
-		mr.On("InsertDelegations", ctx, dgs).Return(errors.New("err"))
+		mr.On("SelectLastDelegationId", ctx).Return(int64(3000), nil)
+		mr.On("InsertDelegations", ctx, dgs).Return(entity.InsertSummary{}, errors.New("err"))
 
 		ma := &mockAPI{}
-		ma.On("GetDelegations", ctx, preTn).Return(dgs, nil)
-		p := New(mr, ma)
+		ma.On("StreamDelegations", ctx, entity.DelegationRange{LastId: 3000}).Return([][]entity.Delegation(nil), nil)
 
-		err := p.Fetch(ctx)
+		err := New(mr, ma, &fakePublisher{}, 0, log).Listen(ctx, &fakeSubscriber{dgs: dgs})
 		assert.Error(t, err)
+		mr.AssertExpectations(t)
 	})
 }
@@ -0,0 +1,313 @@
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/frisk038/tezos-delegation-service/domain/adapter"
+	"github.com/frisk038/tezos-delegation-service/domain/entity"
+	"github.com/frisk038/tezos-delegation-service/domain/repository"
+	"golang.org/x/exp/slog"
+)
+
+// Config represents the configuration of the delivery of the webhook events.
+type Config struct {
+	Timeout    time.Duration `yaml:"timeout" env-default:"10s"`
+	Retries    int           `yaml:"retries" env-default:"5"`
+	BackoffMin time.Duration `yaml:"backoff-min" env-default:"1s"`
+	BackoffMax time.Duration `yaml:"backoff-max" env-default:"5m"`
+	Batch      int           `yaml:"batch" env-default:"100"`
+	Interval   time.Duration `yaml:"interval" env-default:"1m"`
+	Retention  time.Duration `yaml:"retention" env-default:"720h"`
+}
+
+// secretSize is the count of random bytes of the secret of a subscription.
+const secretSize = 32
+
+// UseCase represents the use case for managing the webhook subscriptions and notifying them of the stored delegations.
+// The events are written along with their delegation, each subscription having its own worker delivering them.
+type UseCase struct {
+	repo       repository.Webhook
+	notifier   adapter.Notifier
+	retries    int           // How many times an event is posted again after a failed attempt.
+	backoffMin time.Duration // Wait before the first retry, doubled on each attempt.
+	backoffMax time.Duration // Upper bound of the wait between two attempts.
+	batch      int           // Count of pending events read at once.
+	interval   time.Duration // Period of the check for the subscriptions and their pending events.
+	retention  time.Duration // How long the events delivered or given up and the attempts are kept, forever if 0.
+	log        *slog.Logger
+	sleep      func(ctx context.Context, d time.Duration) error
+
+	wake chan struct{}
+}
+
+// worker delivers the events of a subscription.
+type worker struct {
+	wake   chan struct{}
+	cancel context.CancelFunc
+}
+
+// New creates a new instance of the UseCase.
+func New(repo repository.Webhook, notifier adapter.Notifier, cfg Config, log *slog.Logger) *UseCase {
+	return &UseCase{
+		repo:       repo,
+		notifier:   notifier,
+		retries:    cfg.Retries,
+		backoffMin: cfg.BackoffMin,
+		backoffMax: cfg.BackoffMax,
+		batch:      cfg.Batch,
+		interval:   cfg.Interval,
+		retention:  cfg.Retention,
+		log:        log,
+		wake:       make(chan struct{}, 1),
+	}
+}
+
+// CreateSubscription stores a webhook subscription with a new random secret, and returns it.
+func (uc *UseCase) CreateSubscription(ctx context.Context, sub entity.Subscription) (entity.Subscription, error) {
+	secret := make([]byte, secretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return entity.Subscription{}, err
+	}
+	sub.Secret = hex.EncodeToString(secret)
+
+	return uc.repo.InsertSubscription(ctx, sub)
+}
+
+// GetSubscriptions retrieves every webhook subscription.
+func (uc *UseCase) GetSubscriptions(ctx context.Context) ([]entity.Subscription, error) {
+	return uc.repo.SelectSubscriptions(ctx)
+}
+
+// DeleteSubscription removes a webhook subscription, entity.ErrNotFound if there is none.
+func (uc *UseCase) DeleteSubscription(ctx context.Context, id int64) error {
+	return uc.repo.DeleteSubscription(ctx, id)
+}
+
+// GetDeliveries retrieves the delivery attempts of a subscription, the most recent first.
+func (uc *UseCase) GetDeliveries(ctx context.Context, subscriptionId int64, limit, offset int) ([]entity.Delivery, error) {
+	return uc.repo.SelectDeliveries(ctx, subscriptionId, limit, offset)
+}
+
+// Retract does nothing: the events of the delegations removed by a chain reorganization which are not delivered yet
+// are deleted along with them.
+func (uc *UseCase) Retract([]entity.Delegation) {}
+
+// Publish wakes Run up to deliver the events of the stored delegations, without blocking.
+// The events are already written, a delegation stored again being only notified once.
+func (uc *UseCase) Publish([]entity.Delegation) {
+	select {
+	case uc.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run delivers the events of the subscriptions until ctx is done, starting with the ones left pending, e.g. by
+// a restart. Each subscription has its own worker so that a slow receiver only delays its own events.
+// Workers are started and stopped along with the subscriptions, on each Publish and every interval.
+// The expired events and attempts are deleted on start and every interval.
+func (uc *UseCase) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	workers := map[int64]*worker{}
+	defer func() {
+		for _, w := range workers {
+			w.cancel()
+		}
+		wg.Wait()
+	}()
+
+	ticker := time.NewTicker(uc.interval)
+	defer ticker.Stop()
+	uc.prune(ctx)
+	for {
+		subs, err := uc.repo.SelectSubscriptions(ctx)
+		if err != nil {
+			uc.log.Error("webhook subscriptions not read", "error", err.Error())
+		} else {
+			uc.dispatch(ctx, &wg, workers, subs)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-uc.wake:
+		case <-ticker.C:
+			uc.prune(ctx)
+		}
+	}
+}
+
+// prune deletes the events delivered or given up and the delivery attempts older than the retention.
+func (uc *UseCase) prune(ctx context.Context) {
+	if uc.retention <= 0 {
+		return
+	}
+
+	events, deliveries, err := uc.repo.DeleteExpiredEvents(ctx, uc.retention)
+	if err != nil {
+		if ctx.Err() == nil {
+			uc.log.Error("webhook events not pruned", "error", err.Error())
+		}
+		return
+	}
+	if events != 0 || deliveries != 0 {
+		uc.log.Info("webhook events pruned", "events", events, "deliveries", deliveries)
+	}
+}
+
+// dispatch wakes the worker of each subscription up, starting the missing ones and stopping the ones of the
+// subscriptions deleted.
+func (uc *UseCase) dispatch(ctx context.Context, wg *sync.WaitGroup, workers map[int64]*worker, subs []entity.Subscription) {
+	current := make(map[int64]struct{}, len(subs))
+	for _, sub := range subs {
+		current[sub.Id] = struct{}{}
+		w, ok := workers[sub.Id]
+		if !ok {
+			wctx, cancel := context.WithCancel(ctx)
+			w = &worker{wake: make(chan struct{}, 1), cancel: cancel}
+			workers[sub.Id] = w
+			wg.Add(1)
+			go func(sub entity.Subscription) {
+				defer wg.Done()
+				uc.work(wctx, sub, w.wake)
+			}(sub)
+		}
+		select {
+		case w.wake <- struct{}{}:
+		default:
+		}
+	}
+
+	for id, w := range workers {
+		if _, ok := current[id]; !ok {
+			w.cancel()
+			delete(workers, id)
+		}
+	}
+}
+
+// work delivers the pending events of the subscription each time it is woken up, until ctx is done.
+func (uc *UseCase) work(ctx context.Context, sub entity.Subscription, wake <-chan struct{}) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-wake:
+			uc.drain(ctx, sub)
+		}
+	}
+}
+
+// drain delivers the pending events of the subscription in order, until none is left.
+// On a failure to read or record them, the remaining ones are delivered on the next wake-up.
+func (uc *UseCase) drain(ctx context.Context, sub entity.Subscription) {
+	for {
+		evs, err := uc.repo.SelectPendingEvents(ctx, sub.Id, uc.batch)
+		if err != nil {
+			if ctx.Err() == nil {
+				uc.log.Error("webhook events not read", "error", err.Error(), "subscription", sub.Id)
+			}
+			return
+		}
+
+		for _, ev := range evs {
+			if err = uc.deliver(ctx, sub, ev); err != nil {
+				if ctx.Err() == nil {
+					uc.log.Error("webhook delivery not recorded",
+						"error", err.Error(),
+						"subscription", sub.Id,
+						"operation", ev.Delegation.Id)
+				}
+				return
+			}
+		}
+		if len(evs) < uc.batch {
+			return
+		}
+	}
+}
+
+// deliver posts the event to the subscription until it is received or the retries run out, recording each attempt
+// along with the state of the event. The attempts already made, e.g. before a restart, are counted.
+// It returns an error when ctx is done or the final state of the event is not recorded, the event staying pending.
+func (uc *UseCase) deliver(ctx context.Context, sub entity.Subscription, ev entity.WebhookEvent) error {
+	for attempt := ev.Attempts + 1; ; attempt++ {
+		if attempt > 1 {
+			if err := uc.wait(ctx, uc.backoff(attempt-2)); err != nil {
+				return err
+			}
+		}
+
+		code, err := uc.notifier.Notify(ctx, sub, ev)
+		dlv := entity.Delivery{
+			SubscriptionId: sub.Id,
+			OperationId:    ev.Delegation.Id,
+			Event:          ev.Kind,
+			Attempt:        attempt,
+			StatusCode:     code,
+		}
+		state := entity.StateDelivered
+		if err != nil {
+			dlv.Error = err.Error()
+			state = entity.StatePending
+			if attempt > uc.retries {
+				state = entity.StateFailed
+			}
+		}
+
+		if logErr := uc.repo.InsertDelivery(ctx, dlv, state); logErr != nil {
+			if state != entity.StatePending || ctx.Err() != nil {
+				return logErr
+			}
+			// The retries go on, an attempt not recorded only allowing one more after a restart.
+			uc.log.Error("webhook delivery not logged",
+				"error", logErr.Error(),
+				"subscription", sub.Id,
+				"operation", ev.Delegation.Id)
+		}
+
+		if state == entity.StateFailed {
+			uc.log.Warn("webhook delivery given up",
+				"error", err.Error(),
+				"subscription", sub.Id,
+				"operation", ev.Delegation.Id,
+				"event", ev.Kind,
+				"attempts", attempt)
+		}
+		if state != entity.StatePending {
+			return nil
+		}
+	}
+}
+
+// backoff returns the wait before the given retry: backoffMin doubled on each attempt, capped to backoffMax.
+func (uc *UseCase) backoff(retry int) time.Duration {
+	d := uc.backoffMin
+	for i := 0; i < retry && d < uc.backoffMax; i++ {
+		d *= 2
+	}
+	if d > uc.backoffMax {
+		d = uc.backoffMax
+	}
+
+	return d
+}
+
+// wait pauses for d or until the context is done.
+func (uc *UseCase) wait(ctx context.Context, d time.Duration) error {
+	if uc.sleep != nil {
+		return uc.sleep(ctx, d)
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,348 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/frisk038/tezos-delegation-service/domain/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/exp/slog"
+)
+
+type mockRepo struct {
+	mock.Mock
+}
+
+func (mr *mockRepo) InsertSubscription(ctx context.Context, sub entity.Subscription) (entity.Subscription, error) {
+	called := mr.Called(ctx, sub)
+	return called.Get(0).(entity.Subscription), called.Error(1)
+}
+
+func (mr *mockRepo) SelectSubscriptions(ctx context.Context) ([]entity.Subscription, error) {
+	called := mr.Called(ctx)
+	return called.Get(0).([]entity.Subscription), called.Error(1)
+}
+
+func (mr *mockRepo) DeleteSubscription(ctx context.Context, id int64) error {
+	return mr.Called(ctx, id).Error(0)
+}
+
+func (mr *mockRepo) SelectPendingEvents(ctx context.Context, subscriptionId int64, limit int) ([]entity.WebhookEvent, error) {
+	called := mr.Called(ctx, subscriptionId, limit)
+	return called.Get(0).([]entity.WebhookEvent), called.Error(1)
+}
+
+func (mr *mockRepo) InsertDelivery(ctx context.Context, dlv entity.Delivery, state string) error {
+	return mr.Called(ctx, dlv, state).Error(0)
+}
+
+func (mr *mockRepo) SelectDeliveries(ctx context.Context, subscriptionId int64, limit, offset int) ([]entity.Delivery, error) {
+	called := mr.Called(ctx, subscriptionId, limit, offset)
+	return called.Get(0).([]entity.Delivery), called.Error(1)
+}
+
+func (mr *mockRepo) DeleteExpiredEvents(ctx context.Context, retention time.Duration) (int64, int64, error) {
+	called := mr.Called(ctx, retention)
+	return called.Get(0).(int64), called.Get(1).(int64), called.Error(2)
+}
+
+type mockNotifier struct {
+	mock.Mock
+}
+
+func (mn *mockNotifier) Notify(ctx context.Context, sub entity.Subscription, ev entity.WebhookEvent) (int, error) {
+	called := mn.Called(ctx, sub.Id, ev)
+	return called.Int(0), called.Error(1)
+}
+
+func newTestUseCase(mr *mockRepo, mn *mockNotifier, waits *[]time.Duration) *UseCase {
+	uc := New(mr, mn, Config{Retries: 2, BackoffMin: time.Second, BackoffMax: 90 * time.Second, Batch: 2, Interval: time.Hour},
+		slog.New(slog.NewTextHandler(io.Discard, nil)))
+	uc.sleep = func(_ context.Context, d time.Duration) error {
+		*waits = append(*waits, d)
+		return nil
+	}
+	return uc
+}
+
+func TestUseCase_CreateSubscription(t *testing.T) {
+	ctx := context.Background()
+	sub := entity.Subscription{Url: "https://example.com/hook", Addresses: []string{"baker1"}, Events: []string{entity.EventJoined}}
+
+	mr := &mockRepo{}
+	mr.On("InsertSubscription", ctx, mock.MatchedBy(func(got entity.Subscription) bool {
+		return len(got.Secret) == 2*secretSize && got.Url == sub.Url
+	})).Return(entity.Subscription{Id: 1}, nil).Twice()
+	uc := New(mr, &mockNotifier{}, Config{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	got, err := uc.CreateSubscription(ctx, sub)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), got.Id)
+	_, err = uc.CreateSubscription(ctx, sub)
+	assert.NoError(t, err)
+
+	// Each subscription gets its own secret.
+	secrets := map[string]struct{}{}
+	for _, call := range mr.Calls {
+		secrets[call.Arguments.Get(1).(entity.Subscription).Secret] = struct{}{}
+	}
+	assert.Len(t, secrets, 2)
+	mr.AssertExpectations(t)
+}
+
+func TestUseCase_Publish(t *testing.T) {
+	uc := New(&mockRepo{}, &mockNotifier{}, Config{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	// Never blocks, Run being woken up once for both.
+	uc.Publish([]entity.Delegation{{Id: 3033}})
+	uc.Publish([]entity.Delegation{{Id: 3034}})
+
+	assert.Len(t, uc.wake, 1)
+}
+
+func TestUseCase_Deliver(t *testing.T) {
+	ctx := context.Background()
+	sub := entity.Subscription{Id: 2, Addresses: []string{"baker2"}, Events: []string{entity.EventJoined}}
+	ev := entity.WebhookEvent{Kind: entity.EventJoined, Baker: "baker2", Delegation: entity.Delegation{Id: 3034, Baker: "baker2"}}
+	delivery := func(attempt, code int, err string) entity.Delivery {
+		return entity.Delivery{SubscriptionId: 2, OperationId: 3034, Event: entity.EventJoined, Attempt: attempt, StatusCode: code, Error: err}
+	}
+
+	t.Run("delivered", func(t *testing.T) {
+		mr := &mockRepo{}
+		mr.On("InsertDelivery", ctx, delivery(1, 200, ""), entity.StateDelivered).Return(nil)
+		mn := &mockNotifier{}
+		mn.On("Notify", ctx, int64(2), ev).Return(200, nil)
+		var waits []time.Duration
+
+		err := newTestUseCase(mr, mn, &waits).deliver(ctx, sub, ev)
+
+		assert.NoError(t, err)
+		assert.Empty(t, waits)
+		mr.AssertExpectations(t)
+		mn.AssertExpectations(t)
+	})
+
+	t.Run("retried", func(t *testing.T) {
+		mr := &mockRepo{}
+		mr.On("InsertDelivery", ctx, delivery(1, 503, "unavailable"), entity.StatePending).Return(nil)
+		mr.On("InsertDelivery", ctx, delivery(2, 0, "refused"), entity.StatePending).Return(errors.New("err"))
+		mr.On("InsertDelivery", ctx, delivery(3, 200, ""), entity.StateDelivered).Return(nil)
+		mn := &mockNotifier{}
+		mn.On("Notify", ctx, int64(2), ev).Return(503, errors.New("unavailable")).Once()
+		mn.On("Notify", ctx, int64(2), ev).Return(0, errors.New("refused")).Once()
+		mn.On("Notify", ctx, int64(2), ev).Return(200, nil).Once()
+		var waits []time.Duration
+
+		err := newTestUseCase(mr, mn, &waits).deliver(ctx, sub, ev)
+
+		// A retry not logged doesn't stop the retries.
+		assert.NoError(t, err)
+		assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, waits)
+		mr.AssertExpectations(t)
+		mn.AssertExpectations(t)
+	})
+
+	t.Run("given_up", func(t *testing.T) {
+		mr := &mockRepo{}
+		mr.On("InsertDelivery", ctx, mock.Anything, entity.StatePending).Return(nil).Twice()
+		mr.On("InsertDelivery", ctx, delivery(3, 500, "err"), entity.StateFailed).Return(nil).Once()
+		mn := &mockNotifier{}
+		mn.On("Notify", ctx, int64(2), ev).Return(500, errors.New("err"))
+		var waits []time.Duration
+
+		err := newTestUseCase(mr, mn, &waits).deliver(ctx, sub, ev)
+
+		assert.NoError(t, err)
+		mn.AssertNumberOfCalls(t, "Notify", 3)
+		mr.AssertExpectations(t)
+		assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, waits)
+	})
+
+	t.Run("resumed", func(t *testing.T) {
+		resumed := ev
+		resumed.Attempts = 2
+		mr := &mockRepo{}
+		mr.On("InsertDelivery", ctx, delivery(3, 500, "err"), entity.StateFailed).Return(nil)
+		mn := &mockNotifier{}
+		mn.On("Notify", ctx, int64(2), resumed).Return(500, errors.New("err"))
+		var waits []time.Duration
+
+		err := newTestUseCase(mr, mn, &waits).deliver(ctx, sub, resumed)
+
+		// The attempts made before a restart are counted, the wait before the next one too.
+		assert.NoError(t, err)
+		assert.Equal(t, []time.Duration{2 * time.Second}, waits)
+		mr.AssertExpectations(t)
+		mn.AssertExpectations(t)
+	})
+
+	t.Run("state_not_recorded", func(t *testing.T) {
+		mr := &mockRepo{}
+		mr.On("InsertDelivery", ctx, delivery(1, 200, ""), entity.StateDelivered).Return(errors.New("err"))
+		mn := &mockNotifier{}
+		mn.On("Notify", ctx, int64(2), ev).Return(200, nil)
+		var waits []time.Duration
+
+		err := newTestUseCase(mr, mn, &waits).deliver(ctx, sub, ev)
+
+		assert.Error(t, err)
+		mn.AssertNumberOfCalls(t, "Notify", 1)
+	})
+}
+
+func TestUseCase_Drain(t *testing.T) {
+	ctx := context.Background()
+	sub := entity.Subscription{Id: 1, Addresses: []string{"baker1"}, Events: []string{entity.EventJoined, entity.EventLeft}}
+	evs := []entity.WebhookEvent{
+		{Kind: entity.EventJoined, Baker: "baker1", Delegation: entity.Delegation{Id: 3033, Baker: "baker1"}},
+		{Kind: entity.EventLeft, Baker: "baker1", Delegation: entity.Delegation{Id: 3034, Baker: "baker2", PrevBaker: "baker1"}},
+		{Kind: entity.EventJoined, Baker: "baker1", Delegation: entity.Delegation{Id: 3035, Baker: "baker1"}},
+	}
+
+	t.Run("in_order", func(t *testing.T) {
+		mr := &mockRepo{}
+		mr.On("SelectPendingEvents", ctx, int64(1), 2).Return(evs[:2], nil).Once()
+		mr.On("SelectPendingEvents", ctx, int64(1), 2).Return(evs[2:], nil).Once()
+		mr.On("InsertDelivery", ctx, mock.Anything, entity.StateDelivered).Return(nil)
+		mn := &mockNotifier{}
+		var notified []int64
+		mn.On("Notify", ctx, int64(1), mock.Anything).Return(200, nil).Run(func(args mock.Arguments) {
+			notified = append(notified, args.Get(2).(entity.WebhookEvent).Delegation.Id)
+		})
+		var waits []time.Duration
+
+		newTestUseCase(mr, mn, &waits).drain(ctx, sub)
+
+		assert.Equal(t, []int64{3033, 3034, 3035}, notified)
+		mr.AssertExpectations(t)
+	})
+
+	t.Run("state_not_recorded", func(t *testing.T) {
+		mr := &mockRepo{}
+		mr.On("SelectPendingEvents", ctx, int64(1), 2).Return(evs[:2], nil).Once()
+		mr.On("InsertDelivery", ctx, mock.Anything, entity.StateDelivered).Return(errors.New("err"))
+		mn := &mockNotifier{}
+		mn.On("Notify", ctx, int64(1), evs[0]).Return(200, nil)
+		var waits []time.Duration
+
+		newTestUseCase(mr, mn, &waits).drain(ctx, sub)
+
+		// The event is still pending, the next ones wait for it to be delivered again.
+		mn.AssertNumberOfCalls(t, "Notify", 1)
+		mr.AssertExpectations(t)
+	})
+
+	t.Run("events_err", func(t *testing.T) {
+		mr := &mockRepo{}
+		mr.On("SelectPendingEvents", ctx, int64(1), 2).Return([]entity.WebhookEvent(nil), errors.New("err"))
+		mn := &mockNotifier{}
+		var waits []time.Duration
+
+		newTestUseCase(mr, mn, &waits).drain(ctx, sub)
+
+		mr.AssertExpectations(t)
+		mn.AssertNotCalled(t, "Notify")
+	})
+}
+
+func TestUseCase_Dispatch(t *testing.T) {
+	ctx := context.Background()
+	sub := entity.Subscription{Id: 1, Addresses: []string{"baker1"}, Events: []string{entity.EventJoined}}
+
+	mr := &mockRepo{}
+	mr.On("SelectPendingEvents", mock.Anything, int64(1), 2).Return([]entity.WebhookEvent(nil), nil)
+	var waits []time.Duration
+	uc := newTestUseCase(mr, &mockNotifier{}, &waits)
+	var wg sync.WaitGroup
+	workers := map[int64]*worker{}
+
+	uc.dispatch(ctx, &wg, workers, []entity.Subscription{sub})
+	uc.dispatch(ctx, &wg, workers, []entity.Subscription{sub})
+	assert.Len(t, workers, 1)
+
+	// The worker of a deleted subscription is stopped.
+	uc.dispatch(ctx, &wg, workers, nil)
+	assert.Empty(t, workers)
+	wg.Wait()
+}
+
+func TestUseCase_Run(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sub1 := entity.Subscription{Id: 1, Addresses: []string{"baker1"}, Events: []string{entity.EventJoined}}
+	sub2 := entity.Subscription{Id: 2, Addresses: []string{"baker2"}, Events: []string{entity.EventJoined}}
+	ev1 := entity.WebhookEvent{Kind: entity.EventJoined, Baker: "baker1", Delegation: entity.Delegation{Id: 3033, Baker: "baker1"}}
+	ev2 := entity.WebhookEvent{Kind: entity.EventJoined, Baker: "baker2", Delegation: entity.Delegation{Id: 3034, Baker: "baker2"}}
+
+	mr := &mockRepo{}
+	mr.On("SelectSubscriptions", ctx).Return([]entity.Subscription{sub1, sub2}, nil)
+	// Left pending, e.g. by a restart.
+	mr.On("SelectPendingEvents", mock.Anything, int64(1), 2).Return([]entity.WebhookEvent{ev1}, nil)
+	mr.On("SelectPendingEvents", mock.Anything, int64(2), 2).Return([]entity.WebhookEvent{ev2}, nil)
+	mr.On("InsertDelivery", mock.Anything, mock.Anything, entity.StateDelivered).Return(nil)
+	mr.On("DeleteExpiredEvents", ctx, 24*time.Hour).Return(int64(1), int64(2), nil)
+	mn := &mockNotifier{}
+	// The first receiver only answers once the second one is notified: it doesn't hold it back.
+	released := make(chan struct{})
+	var delivered sync.WaitGroup
+	delivered.Add(2)
+	mn.On("Notify", mock.Anything, int64(1), ev1).Return(200, nil).Run(func(mock.Arguments) {
+		<-released
+		delivered.Done()
+	})
+	mn.On("Notify", mock.Anything, int64(2), ev2).Return(200, nil).Run(func(mock.Arguments) {
+		close(released)
+		delivered.Done()
+	})
+	go func() {
+		delivered.Wait()
+		cancel()
+	}()
+	var waits []time.Duration
+	uc := newTestUseCase(mr, mn, &waits)
+	uc.retention = 24 * time.Hour
+
+	err := uc.Run(ctx)
+
+	assert.ErrorIs(t, err, context.Canceled)
+	mr.AssertExpectations(t)
+	mn.AssertExpectations(t)
+}
+
+func TestUseCase_Prune(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("pruned", func(t *testing.T) {
+		mr := &mockRepo{}
+		mr.On("DeleteExpiredEvents", ctx, 24*time.Hour).Return(int64(1), int64(2), nil)
+		uc := New(mr, &mockNotifier{}, Config{Retention: 24 * time.Hour}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+		uc.prune(ctx)
+
+		mr.AssertExpectations(t)
+	})
+
+	t.Run("kept_forever", func(t *testing.T) {
+		mr := &mockRepo{}
+		uc := New(mr, &mockNotifier{}, Config{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+		uc.prune(ctx)
+
+		mr.AssertNotCalled(t, "DeleteExpiredEvents", mock.Anything, mock.Anything)
+	})
+
+	t.Run("repo_err", func(t *testing.T) {
+		mr := &mockRepo{}
+		mr.On("DeleteExpiredEvents", ctx, time.Hour).Return(int64(0), int64(0), errors.New("err"))
+		uc := New(mr, &mockNotifier{}, Config{Retention: time.Hour}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+		uc.prune(ctx)
+
+		mr.AssertExpectations(t)
+	})
+}
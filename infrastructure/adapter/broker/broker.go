@@ -0,0 +1,141 @@
+package broker
+
+import (
+	"sync"
+	"time"
+
+	"github.com/frisk038/tezos-delegation-service/domain/entity"
+)
+
+// subscriber is a listener of the broker with the filter of the delegations it receives.
+type subscriber struct {
+	filter entity.StreamFilter
+	ch     chan entity.StreamEvent
+}
+
+// retractedTTL is how long a retracted id is published again if stored back, a chain reorganization settling
+// within a few blocks.
+const retractedTTL = time.Hour
+
+// Broker hands the delegations published by the poller to the subscribers interested in them, within the process.
+// Publishing never blocks: a subscriber whose buffer is full is dropped, its channel being closed, so that it
+// catches up from the database rather than slowing down the poller.
+type Broker struct {
+	buffer int
+	now    func() time.Time
+
+	mu        sync.Mutex
+	lastId    int64
+	retracted map[int64]time.Time // Ids published then removed, published again if stored back, with their removal time.
+	subs      map[*subscriber]struct{}
+	closed    bool
+}
+
+// New creates a new broker, each subscriber buffering up to buffer delegations.
+func New(buffer int) *Broker {
+	return &Broker{
+		buffer:    buffer,
+		now:       time.Now,
+		retracted: map[int64]time.Time{},
+		subs:      map[*subscriber]struct{}{},
+	}
+}
+
+// Publish hands the delegations to the subscribers whose filter they match.
+// Delegations are expected in id order; the ones not above the last published id, e.g. stored again by a catch-up,
+// are skipped so that subscribers receive each operation once, unless they were retracted meanwhile.
+func (b *Broker) Publish(dgs []entity.Delegation) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, dg := range dgs {
+		if dg.Id <= b.lastId {
+			at, ok := b.retracted[dg.Id]
+			if !ok {
+				continue
+			}
+			delete(b.retracted, dg.Id)
+			if b.now().Sub(at) > retractedTTL {
+				continue
+			}
+		} else {
+			b.lastId = dg.Id
+		}
+
+		b.send(entity.StreamEvent{Delegation: dg})
+	}
+}
+
+// Retract hands the delegations removed by a chain reorganization to the subscribers whose filter they match,
+// so that they are published again if stored back within retractedTTL, e.g. in another block.
+func (b *Broker) Retract(dgs []entity.Delegation) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	for id, at := range b.retracted {
+		if now.Sub(at) > retractedTTL {
+			delete(b.retracted, id)
+		}
+	}
+
+	for _, dg := range dgs {
+		if dg.Id <= b.lastId {
+			b.retracted[dg.Id] = now
+		}
+
+		b.send(entity.StreamEvent{Delegation: dg, Removed: true})
+	}
+}
+
+// send hands the event to the subscribers whose filter matches its delegation, dropping the ones whose buffer is full.
+func (b *Broker) send(ev entity.StreamEvent) {
+	for sub := range b.subs {
+		if !sub.filter.Match(ev.Delegation) {
+			continue
+		}
+		select {
+		case sub.ch <- ev:
+		default:
+			delete(b.subs, sub)
+			close(sub.ch)
+		}
+	}
+}
+
+// Subscribe returns the channel receiving the events of the delegations published or retracted from now on matching
+// the filter, along with the function to call once done with it. The channel is closed if the subscriber falls behind
+// or the broker is closed.
+func (b *Broker) Subscribe(f entity.StreamFilter) (<-chan entity.StreamEvent, func()) {
+	sub := &subscriber{filter: f, ch: make(chan entity.StreamEvent, b.buffer)}
+
+	b.mu.Lock()
+	if b.closed {
+		close(sub.ch)
+	} else {
+		b.subs[sub] = struct{}{}
+	}
+	b.mu.Unlock()
+
+	return sub.ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subs[sub]; ok {
+			delete(b.subs, sub)
+			close(sub.ch)
+		}
+	}
+}
+
+// Close closes the channel of every subscriber, e.g. so that the streams end when the service stops.
+// Later subscribers get a closed channel.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for sub := range b.subs {
+		delete(b.subs, sub)
+		close(sub.ch)
+	}
+}
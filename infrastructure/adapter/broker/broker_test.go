@@ -0,0 +1,162 @@
+package broker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/frisk038/tezos-delegation-service/domain/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+// received drains what is buffered in the channel, reporting whether it is still open.
+func received(ch <-chan entity.StreamEvent) ([]entity.StreamEvent, bool) {
+	var got []entity.StreamEvent
+	for {
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				return got, false
+			}
+			got = append(got, ev)
+		default:
+			return got, true
+		}
+	}
+}
+
+// events returns the events of the delegations, removed or stored.
+func events(removed bool, dgs ...entity.Delegation) []entity.StreamEvent {
+	evs := make([]entity.StreamEvent, 0, len(dgs))
+	for _, dg := range dgs {
+		evs = append(evs, entity.StreamEvent{Delegation: dg, Removed: removed})
+	}
+	return evs
+}
+
+func TestBroker(t *testing.T) {
+	dgs := []entity.Delegation{
+		{Id: 3033, Delegator: "dg1", Baker: "baker1", Amount: 1000},
+		{Id: 3034, Delegator: "dg2", Baker: "baker2", Amount: 20},
+		{Id: 3035, Delegator: "dg1", Baker: "baker2", Amount: 500, Status: entity.StatusFailed},
+	}
+
+	t.Run("filtered", func(t *testing.T) {
+		b := New(10)
+		all, cancelAll := b.Subscribe(entity.StreamFilter{})
+		defer cancelAll()
+		dg1, cancelDg1 := b.Subscribe(entity.StreamFilter{Delegator: "dg1"})
+		defer cancelDg1()
+		large, cancelLarge := b.Subscribe(entity.StreamFilter{AmountMin: 100})
+		defer cancelLarge()
+
+		b.Publish(dgs)
+
+		got, open := received(all)
+		assert.True(t, open)
+		assert.Equal(t, events(false, dgs[:2]...), got)
+		got, _ = received(dg1)
+		assert.Equal(t, events(false, dgs[:1]...), got)
+		got, _ = received(large)
+		assert.Equal(t, events(false, dgs[:1]...), got)
+	})
+
+	t.Run("published_once", func(t *testing.T) {
+		b := New(10)
+		ch, cancel := b.Subscribe(entity.StreamFilter{})
+		defer cancel()
+
+		b.Publish(dgs[:1])
+		// Stored again, along with a new one.
+		b.Publish(dgs[:2])
+
+		got, _ := received(ch)
+		assert.Equal(t, events(false, dgs[:2]...), got)
+	})
+
+	t.Run("retracted", func(t *testing.T) {
+		b := New(10)
+		ch, cancel := b.Subscribe(entity.StreamFilter{})
+		defer cancel()
+
+		b.Publish(dgs[:2])
+		b.Retract(dgs[1:2])
+		// Stored back in another block, along with one which was never removed.
+		moved := dgs[1]
+		moved.Block = "block2bis"
+		b.Publish([]entity.Delegation{dgs[0], moved})
+		b.Publish([]entity.Delegation{moved})
+
+		got, open := received(ch)
+		assert.True(t, open)
+		assert.Equal(t, append(append(events(false, dgs[:2]...), events(true, dgs[1])...), events(false, moved)...), got)
+	})
+
+	t.Run("retracted_expired", func(t *testing.T) {
+		now := time.Now()
+		b := New(10)
+		b.now = func() time.Time { return now }
+		ch, cancel := b.Subscribe(entity.StreamFilter{})
+		defer cancel()
+
+		b.Publish(dgs[:2])
+		b.Retract(dgs[:1])
+		now = now.Add(retractedTTL + time.Second)
+		// The first retracted id is pruned by the next retraction, and no longer published again.
+		b.Retract(dgs[1:2])
+		assert.Len(t, b.retracted, 1)
+		b.Publish(dgs[:2])
+
+		got, open := received(ch)
+		assert.True(t, open)
+		assert.Equal(t, append(append(append(events(false, dgs[:2]...), events(true, dgs[0])...),
+			events(true, dgs[1])...), events(false, dgs[1])...), got)
+	})
+
+	t.Run("slow_subscriber_dropped", func(t *testing.T) {
+		b := New(1)
+		slow, cancelSlow := b.Subscribe(entity.StreamFilter{})
+		defer cancelSlow()
+		other, cancelOther := b.Subscribe(entity.StreamFilter{Baker: "baker2"})
+		defer cancelOther()
+
+		b.Publish(dgs)
+
+		got, open := received(slow)
+		assert.False(t, open)
+		assert.Equal(t, events(false, dgs[:1]...), got)
+		got, open = received(other)
+		assert.True(t, open)
+		assert.Equal(t, events(false, dgs[1:2]...), got)
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		b := New(10)
+		ch, cancel := b.Subscribe(entity.StreamFilter{})
+		cancel()
+		// Cancelling again is harmless.
+		cancel()
+
+		b.Publish(dgs)
+
+		got, open := received(ch)
+		assert.False(t, open)
+		assert.Empty(t, got)
+	})
+
+	t.Run("closed", func(t *testing.T) {
+		b := New(10)
+		before, cancel := b.Subscribe(entity.StreamFilter{})
+		defer cancel()
+
+		b.Close()
+		after, cancelAfter := b.Subscribe(entity.StreamFilter{})
+		defer cancelAfter()
+		b.Publish(dgs)
+
+		_, open := received(before)
+		assert.False(t, open)
+		got, open := received(after)
+		assert.False(t, open)
+		assert.Empty(t, got)
+	})
+}
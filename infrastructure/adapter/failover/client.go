@@ -0,0 +1,269 @@
+package failover
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/frisk038/tezos-delegation-service/domain/adapter"
+	"github.com/frisk038/tezos-delegation-service/domain/entity"
+	"golang.org/x/exp/slog"
+)
+
+// Config represents the configuration for the failover between delegation sources.
+type Config struct {
+	Timeout    time.Duration  `yaml:"timeout" env-default:"30s"`
+	CoolDown   time.Duration  `yaml:"cool-down" env-default:"1m"`
+	CrossCheck bool           `yaml:"cross-check" env-default:"false"`
+	Sources    []SourceConfig `yaml:"sources"`
+}
+
+// SourceConfig represents one delegation source, in order of preference.
+type SourceConfig struct {
+	Kind string `yaml:"kind"`
+	Url  string `yaml:"url"`
+}
+
+// Source is a named delegation source.
+type Source struct {
+	Name string
+	API  adapter.API
+}
+
+// health tracks the failures of a source.
+type health struct {
+	failures  int
+	downUntil time.Time
+}
+
+// Client is a delegation source which tries each of its sources in order until one answers.
+type Client struct {
+	sources    []Source
+	timeout    time.Duration
+	coolDown   time.Duration
+	crossCheck bool
+	log        *slog.Logger
+	now        func() time.Time
+
+	mu     sync.Mutex
+	health []health
+}
+
+// New creates a new failover client over the given sources, the first one being preferred.
+func New(cfg Config, sources []Source, log *slog.Logger) (*Client, error) {
+	if len(sources) == 0 {
+		return nil, errors.New("failover needs at least one source")
+	}
+
+	return &Client{
+		sources:    sources,
+		timeout:    cfg.Timeout,
+		coolDown:   cfg.CoolDown,
+		crossCheck: cfg.CrossCheck,
+		log:        log,
+		now:        time.Now,
+		health:     make([]health, len(sources)),
+	}, nil
+}
+
+// GetDelegations returns the delegations of the range, all the pages at once.
+func (c *Client) GetDelegations(ctx context.Context, rg entity.DelegationRange) ([]entity.Delegation, error) {
+	var result []entity.Delegation
+	err := c.StreamDelegations(ctx, rg, func(_ context.Context, dgs []entity.Delegation) error {
+		result = append(result, dgs...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// StreamDelegations walks the delegations of the first source which doesn't stay silent longer than the timeout.
+// When a source fails midway, the next one resumes after the last delegation handed to onPage.
+// Sources which failed recently are only tried once every healthy source has failed.
+// With cross-check enabled, the next healthy source is also walked and a count mismatch is logged; there is no
+// cross-check when every other source is cooling down.
+func (c *Client) StreamDelegations(ctx context.Context, rg entity.DelegationRange, onPage adapter.PageFunc) error {
+	var errs []error
+	count := 0
+	answered := -1
+	next := rg
+	for _, i := range c.order() {
+		if answered < 0 {
+			lastId, n, err := c.stream(ctx, i, next, onPage)
+			count += n
+			next.LastId = lastId
+			if err != nil {
+				var pgErr *pageError
+				if errors.As(err, &pgErr) {
+					return pgErr.err
+				}
+				errs = append(errs, fmt.Errorf("%s: %w", c.sources[i].Name, err))
+				if ctx.Err() != nil {
+					break
+				}
+				continue
+			}
+
+			answered = i
+			if !c.crossCheck {
+				return nil
+			}
+			continue
+		}
+
+		// Sources cooling down come last: once one is reached, no healthy source is left to cross-check with.
+		if c.coolingDown(i) {
+			break
+		}
+
+		// The other source only counts the delegations of the whole range.
+		otherCount := 0
+		_, _, err := c.stream(ctx, i, rg, func(_ context.Context, dgs []entity.Delegation) error {
+			otherCount += len(dgs)
+			return nil
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+
+		if otherCount != count {
+			c.log.Warn("delegation sources disagree",
+				"source", c.sources[answered].Name,
+				"count", count,
+				"other_source", c.sources[i].Name,
+				"other_count", otherCount)
+		}
+		return nil
+	}
+
+	if answered >= 0 {
+		return nil
+	}
+	return errors.Join(errs...)
+}
+
+// order returns the index of the sources to try: the healthy ones first, then those cooling down.
+func (c *Client) order() []int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	var healthy, down []int
+	for i, h := range c.health {
+		if now.Before(h.downUntil) {
+			down = append(down, i)
+		} else {
+			healthy = append(healthy, i)
+		}
+	}
+
+	return append(healthy, down...)
+}
+
+// coolingDown reports whether the source failed recently.
+func (c *Client) coolingDown(i int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now().Before(c.health[i].downUntil)
+}
+
+// pageError wraps the error returned by onPage, which is not a failure of the source.
+type pageError struct {
+	err error
+}
+
+func (e *pageError) Error() string {
+	return e.err.Error()
+}
+
+// stream walks the delegations of one source and records its health.
+// The source is abandoned once it stays silent longer than the timeout, the time spent in onPage not being counted.
+// It returns the id of the last delegation handed to onPage and how many were handed over.
+func (c *Client) stream(ctx context.Context, i int, rg entity.DelegationRange, onPage adapter.PageFunc) (int64, int, error) {
+	srcCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	var timer *time.Timer
+	if c.timeout > 0 {
+		timer = time.AfterFunc(c.timeout, func() { cancel(context.DeadlineExceeded) })
+		defer timer.Stop()
+	}
+
+	// The source runs on its own goroutine so that a stuck one can be abandoned,
+	// pages are no longer handed over once it is.
+	var mu sync.Mutex
+	abandoned := false
+	lastId, count := rg.LastId, 0
+	res := make(chan error, 1)
+	go func() {
+		res <- c.sources[i].API.StreamDelegations(srcCtx, rg, func(_ context.Context, dgs []entity.Delegation) error {
+			mu.Lock()
+			defer mu.Unlock()
+			if abandoned || srcCtx.Err() != nil {
+				return context.Cause(srcCtx)
+			}
+
+			if timer != nil {
+				timer.Stop()
+			}
+			if err := onPage(ctx, dgs); err != nil {
+				return &pageError{err: err}
+			}
+			lastId, count = dgs[len(dgs)-1].Id, count+len(dgs)
+			if timer != nil {
+				timer.Reset(c.timeout)
+			}
+			return nil
+		})
+	}()
+
+	var err error
+	select {
+	case err = <-res:
+	case <-srcCtx.Done():
+		// The source may have returned right before being abandoned.
+		select {
+		case err = <-res:
+		default:
+			err = context.Cause(srcCtx)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	abandoned = true
+
+	var pgErr *pageError
+	if errors.As(err, &pgErr) {
+		return lastId, count, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	h := &c.health[i]
+	if err != nil {
+		h.failures++
+		h.downUntil = c.now().Add(c.coolDown)
+		c.log.Warn("delegation source failed",
+			"source", c.sources[i].Name,
+			"failures", h.failures,
+			"delivered", count,
+			"err", err)
+		return lastId, count, err
+	}
+
+	if h.failures > 0 {
+		c.log.Info("delegation source recovered", "source", c.sources[i].Name, "failures", h.failures)
+	}
+	*h = health{}
+	return lastId, count, nil
+}
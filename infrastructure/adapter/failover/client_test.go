@@ -0,0 +1,228 @@
+package failover
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/frisk038/tezos-delegation-service/domain/adapter"
+	"github.com/frisk038/tezos-delegation-service/domain/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slog"
+)
+
+type mockAPI struct {
+	mock.Mock
+}
+
+func (ma *mockAPI) GetDelegations(ctx context.Context, rg entity.DelegationRange) ([]entity.Delegation, error) {
+	called := ma.Called(rg)
+	return called.Get(0).([]entity.Delegation), called.Error(1)
+}
+
+// StreamDelegations hands the delegations to onPage as one page before returning the error, if any.
+func (ma *mockAPI) StreamDelegations(ctx context.Context, rg entity.DelegationRange, onPage adapter.PageFunc) error {
+	called := ma.Called(rg)
+	if dgs := called.Get(0).([]entity.Delegation); len(dgs) != 0 {
+		if err := onPage(ctx, dgs); err != nil {
+			return err
+		}
+	}
+	return called.Error(1)
+}
+
+func TestClient_GetDelegations(t *testing.T) {
+	ctx := context.Background()
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cfg := Config{Timeout: time.Second, CoolDown: time.Minute}
+	rg := entity.DelegationRange{LastId: 3000}
+	tn := time.Now()
+	dgs := []entity.Delegation{
+		{
+			Amount:    1000034,
+			Block:     "block2",
+			Id:        3034,
+			Delegator: "dg2",
+			TimeStamp: tn,
+		},
+		{
+			Amount:    1234,
+			Block:     "block1",
+			Id:        30004,
+			Delegator: "dg1",
+			TimeStamp: tn,
+		},
+	}
+
+	t.Run("no_source", func(t *testing.T) {
+		_, err := New(cfg, nil, log)
+		assert.Error(t, err)
+	})
+
+	t.Run("first_source", func(t *testing.T) {
+		first, second := &mockAPI{}, &mockAPI{}
+		first.On("StreamDelegations", rg).Return(dgs, nil)
+
+		c, err := New(cfg, []Source{{Name: "first", API: first}, {Name: "second", API: second}}, log)
+		require.NoError(t, err)
+
+		got, err := c.GetDelegations(ctx, rg)
+		assert.NoError(t, err)
+		assert.Equal(t, dgs, got)
+		first.AssertExpectations(t)
+		second.AssertExpectations(t)
+	})
+
+	t.Run("failover_and_cool_down", func(t *testing.T) {
+		first, second := &mockAPI{}, &mockAPI{}
+		first.On("StreamDelegations", rg).Return([]entity.Delegation(nil), errors.New("err")).Once()
+		second.On("StreamDelegations", rg).Return(dgs, nil).Twice()
+
+		c, err := New(cfg, []Source{{Name: "first", API: first}, {Name: "second", API: second}}, log)
+		require.NoError(t, err)
+
+		got, err := c.GetDelegations(ctx, rg)
+		assert.NoError(t, err)
+		assert.Equal(t, dgs, got)
+
+		// The first source is cooling down, the second one is tried first.
+		got, err = c.GetDelegations(ctx, rg)
+		assert.NoError(t, err)
+		assert.Equal(t, dgs, got)
+
+		// Once the cool down is over, the first source is preferred again.
+		first.On("StreamDelegations", rg).Return(dgs[:1], nil).Once()
+		c.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+		got, err = c.GetDelegations(ctx, rg)
+		assert.NoError(t, err)
+		assert.Equal(t, dgs[:1], got)
+
+		first.AssertExpectations(t)
+		second.AssertExpectations(t)
+	})
+
+	t.Run("failover_on_timeout", func(t *testing.T) {
+		first, second := &mockAPI{}, &mockAPI{}
+		first.On("StreamDelegations", rg).After(time.Second).Return(dgs[:1], nil)
+		second.On("StreamDelegations", rg).Return(dgs, nil)
+
+		c, err := New(Config{Timeout: 10 * time.Millisecond, CoolDown: time.Minute},
+			[]Source{{Name: "first", API: first}, {Name: "second", API: second}}, log)
+		require.NoError(t, err)
+
+		got, err := c.GetDelegations(ctx, rg)
+		assert.NoError(t, err)
+		assert.Equal(t, dgs, got)
+	})
+
+	t.Run("resume_after_midway_failure", func(t *testing.T) {
+		first, second := &mockAPI{}, &mockAPI{}
+		first.On("StreamDelegations", rg).Return(dgs[:1], errors.New("err"))
+		second.On("StreamDelegations", entity.DelegationRange{LastId: dgs[0].Id}).Return(dgs[1:], nil)
+
+		c, err := New(cfg, []Source{{Name: "first", API: first}, {Name: "second", API: second}}, log)
+		require.NoError(t, err)
+
+		var pages [][]entity.Delegation
+		err = c.StreamDelegations(ctx, rg, func(_ context.Context, page []entity.Delegation) error {
+			pages = append(pages, page)
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, [][]entity.Delegation{dgs[:1], dgs[1:]}, pages)
+		first.AssertExpectations(t)
+		second.AssertExpectations(t)
+	})
+
+	t.Run("page_err_not_failed_over", func(t *testing.T) {
+		first, second := &mockAPI{}, &mockAPI{}
+		first.On("StreamDelegations", rg).Return(dgs, nil)
+
+		c, err := New(cfg, []Source{{Name: "first", API: first}, {Name: "second", API: second}}, log)
+		require.NoError(t, err)
+
+		pageErr := errors.New("page err")
+		err = c.StreamDelegations(ctx, rg, func(context.Context, []entity.Delegation) error {
+			return pageErr
+		})
+		assert.Equal(t, pageErr, err)
+		assert.Equal(t, []int{0, 1}, c.order())
+		second.AssertNotCalled(t, "StreamDelegations", mock.Anything)
+	})
+
+	t.Run("all_sources_fail", func(t *testing.T) {
+		first, second := &mockAPI{}, &mockAPI{}
+		first.On("StreamDelegations", rg).Return([]entity.Delegation(nil), errors.New("first err"))
+		second.On("StreamDelegations", rg).Return([]entity.Delegation(nil), errors.New("second err"))
+
+		c, err := New(cfg, []Source{{Name: "first", API: first}, {Name: "second", API: second}}, log)
+		require.NoError(t, err)
+
+		got, err := c.GetDelegations(ctx, rg)
+		assert.ErrorContains(t, err, "first: first err")
+		assert.ErrorContains(t, err, "second: second err")
+		assert.Nil(t, got)
+
+		// Sources cooling down are still tried when nothing else is left.
+		_, err = c.GetDelegations(ctx, rg)
+		assert.Error(t, err)
+		first.AssertNumberOfCalls(t, "StreamDelegations", 2)
+		second.AssertNumberOfCalls(t, "StreamDelegations", 2)
+	})
+
+	t.Run("cross_check", func(t *testing.T) {
+		first, second, third := &mockAPI{}, &mockAPI{}, &mockAPI{}
+		first.On("StreamDelegations", rg).Return(dgs, nil)
+		second.On("StreamDelegations", rg).Return(dgs[:1], nil)
+
+		c, err := New(Config{Timeout: time.Second, CoolDown: time.Minute, CrossCheck: true},
+			[]Source{{Name: "first", API: first}, {Name: "second", API: second}, {Name: "third", API: third}}, log)
+		require.NoError(t, err)
+
+		got, err := c.GetDelegations(ctx, rg)
+		assert.NoError(t, err)
+		assert.Equal(t, dgs, got)
+		first.AssertExpectations(t)
+		second.AssertExpectations(t)
+		third.AssertExpectations(t)
+	})
+
+	t.Run("cross_check_single_healthy", func(t *testing.T) {
+		first, second := &mockAPI{}, &mockAPI{}
+		first.On("StreamDelegations", rg).Return(dgs, nil)
+		second.On("StreamDelegations", rg).Return([]entity.Delegation(nil), errors.New("err"))
+
+		c, err := New(Config{Timeout: time.Second, CoolDown: time.Minute, CrossCheck: true},
+			[]Source{{Name: "first", API: first}, {Name: "second", API: second}}, log)
+		require.NoError(t, err)
+
+		got, err := c.GetDelegations(ctx, rg)
+		assert.NoError(t, err)
+		assert.Equal(t, dgs, got)
+	})
+
+	t.Run("cross_check_skips_cooling_down", func(t *testing.T) {
+		first, second := &mockAPI{}, &mockAPI{}
+		first.On("StreamDelegations", rg).Return([]entity.Delegation(nil), errors.New("err")).Once()
+		second.On("StreamDelegations", rg).Return(dgs, nil)
+
+		c, err := New(Config{Timeout: time.Second, CoolDown: time.Minute, CrossCheck: true},
+			[]Source{{Name: "first", API: first}, {Name: "second", API: second}}, log)
+		require.NoError(t, err)
+
+		// The first source fails then cools down, the second one answers and is not cross-checked with it.
+		got, err := c.GetDelegations(ctx, rg)
+		assert.NoError(t, err)
+		assert.Equal(t, dgs, got)
+
+		got, err = c.GetDelegations(ctx, rg)
+		assert.NoError(t, err)
+		assert.Equal(t, dgs, got)
+		first.AssertNumberOfCalls(t, "StreamDelegations", 1)
+		second.AssertNumberOfCalls(t, "StreamDelegations", 2)
+	})
+}
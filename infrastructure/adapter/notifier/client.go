@@ -0,0 +1,116 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/frisk038/tezos-delegation-service/domain/entity"
+)
+
+// Headers of the requests posting the webhook events.
+const (
+	EventHeader     = "X-Webhook-Event"
+	SignatureHeader = "X-Webhook-Signature"
+)
+
+// httpClient is an interface representing the HTTP client used for making requests.
+type httpClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Client posts the webhook events as JSON, signed with the secret of their subscription.
+type Client struct {
+	Client httpClient
+}
+
+// delegation is the delegation of an event, as posted.
+type delegation struct {
+	Id        int64     `json:"id"`
+	TimeStamp time.Time `json:"timestamp"`
+	Amount    int64     `json:"amount"`
+	Delegator string    `json:"delegator"`
+	Block     string    `json:"block"`
+	Hash      string    `json:"hash,omitempty"`
+	Level     int64     `json:"level,omitempty"`
+	Baker     string    `json:"baker,omitempty"`
+	PrevBaker string    `json:"prevBaker,omitempty"`
+}
+
+// payload is the body of the request posting an event.
+type payload struct {
+	Subscription int64      `json:"subscription"`
+	Event        string     `json:"event"`
+	Baker        string     `json:"baker"`
+	Delegation   delegation `json:"delegation"`
+}
+
+// New creates a new webhook client, each request being given up after timeout.
+func New(timeout time.Duration) *Client {
+	return &Client{
+		Client: &http.Client{
+			Timeout: timeout,
+		},
+	}
+}
+
+// Notify posts the event to the url of the subscription.
+// The body is signed with HMAC-SHA256 keyed by the secret of the subscription, in the hex encoded
+// X-Webhook-Signature header prefixed by sha256=. Any status but a 2xx is returned along with an error.
+func (c *Client) Notify(ctx context.Context, sub entity.Subscription, ev entity.WebhookEvent) (int, error) {
+	dg := ev.Delegation
+	body, err := json.Marshal(payload{
+		Subscription: sub.Id,
+		Event:        ev.Kind,
+		Baker:        ev.Baker,
+		Delegation: delegation{
+			Id:        dg.Id,
+			TimeStamp: dg.TimeStamp,
+			Amount:    dg.Amount,
+			Delegator: dg.Delegator,
+			Block:     dg.Block,
+			Hash:      dg.Hash,
+			Level:     dg.Level,
+			Baker:     dg.Baker,
+			PrevBaker: dg.PrevBaker,
+		},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, ev.Kind)
+	req.Header.Set(SignatureHeader, Sign(sub.Secret, body))
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver returned non-2xx status: %v", resp.Status)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// Sign returns the signature of the body as sent in the X-Webhook-Signature header.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,106 @@
+package notifier
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/frisk038/tezos-delegation-service/domain/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Notify(t *testing.T) {
+	ctx := context.Background()
+	ev := entity.WebhookEvent{
+		Kind:  entity.EventLeft,
+		Baker: "tz1Baker1",
+		Delegation: entity.Delegation{
+			Id:        3034,
+			TimeStamp: time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC),
+			Amount:    1000,
+			Delegator: "tz1Delegator1",
+			Block:     "block1",
+			Level:     4000001,
+			Baker:     "tz1Baker2",
+			PrevBaker: "tz1Baker1",
+			Status:    entity.StatusApplied,
+		},
+	}
+
+	t.Run("delivered", func(t *testing.T) {
+		var got *http.Request
+		var body []byte
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = r
+			body, _ = io.ReadAll(r.Body)
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer srv.Close()
+		sub := entity.Subscription{Id: 7, Url: srv.URL + "/hook", Secret: "s3cr3t"}
+
+		code, err := New(time.Second).Notify(ctx, sub, ev)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNoContent, code)
+		require.NotNil(t, got)
+		assert.Equal(t, http.MethodPost, got.Method)
+		assert.Equal(t, "/hook", got.URL.Path)
+		assert.Equal(t, "application/json", got.Header.Get("Content-Type"))
+		assert.Equal(t, entity.EventLeft, got.Header.Get(EventHeader))
+		assert.Equal(t, Sign("s3cr3t", body), got.Header.Get(SignatureHeader))
+		assert.JSONEq(t,
+			`{"subscription":7,"event":"left","baker":"tz1Baker1","delegation":{"id":3034,
+			"timestamp":"2023-10-01T12:00:00Z","amount":1000,"delegator":"tz1Delegator1","block":"block1",
+			"level":4000001,"baker":"tz1Baker2","prevBaker":"tz1Baker1"}}`,
+			string(body),
+		)
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer srv.Close()
+
+		code, err := New(time.Second).Notify(ctx, entity.Subscription{Url: srv.URL}, ev)
+
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+	})
+
+	t.Run("unreachable", func(t *testing.T) {
+		srv := httptest.NewServer(http.NotFoundHandler())
+		srv.Close()
+
+		code, err := New(time.Second).Notify(ctx, entity.Subscription{Url: srv.URL}, ev)
+
+		assert.Error(t, err)
+		assert.Zero(t, code)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		done := make(chan struct{})
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-done
+		}))
+		defer srv.Close()
+		defer close(done)
+
+		code, err := New(50*time.Millisecond).Notify(ctx, entity.Subscription{Url: srv.URL}, ev)
+
+		assert.Error(t, err)
+		assert.Zero(t, code)
+	})
+}
+
+func TestSign(t *testing.T) {
+	// echo -n '{"event":"joined"}' | openssl dgst -sha256 -hmac secret
+	assert.Equal(t,
+		"sha256=811dd01fdfe8dbe3f848ebab5da76e437c5c9755742e27b0dccc8f85ac4e9c1f",
+		Sign("secret", []byte(`{"event":"joined"}`)),
+	)
+}
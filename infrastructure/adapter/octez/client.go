@@ -0,0 +1,295 @@
+package octez
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/frisk038/tezos-delegation-service/domain/adapter"
+	"github.com/frisk038/tezos-delegation-service/domain/entity"
+)
+
+// Config represents the configuration for the Tezos node RPC client.
+type Config struct {
+	Url     string        `yaml:"url" env:"TEZOS-NODE"`
+	Timeout time.Duration `yaml:"timeout" env-default:"10s"`
+}
+
+const (
+	// levelIdFactor is used to synthesize delegation ids as level*levelIdFactor + position in the block,
+	// the node has no notion of operation ids unlike the indexer.
+	levelIdFactor = 1_000_000
+	// maxBlockDelegations bounds the position of a delegation in its block, far above what fits in a block, an id
+	// whose position is beyond it was not synthesized from a node.
+	maxBlockDelegations = 10_000
+	// managerPass is the validation pass of the manager operations, delegations included.
+	managerPass      = 3
+	delegationKind   = "delegation"
+	headBlock        = "head"
+	headerPath       = "chains/main/blocks/%s/header"
+	operationsPath   = "chains/main/blocks/%d/operations/%d"
+	contractBalance  = "chains/main/blocks/%d/context/contracts/%s/balance"
+	contractDelegate = "chains/main/blocks/%d/context/contracts/%s/delegate"
+	firstLevel       = 1
+	// milligasPerGas converts the consumed milligas reported by the node into gas units.
+	milligasPerGas = 1000
+)
+
+// errNotFound is returned when the node has no such resource, e.g. the delegate of an account without one.
+var errNotFound = errors.New("not found")
+
+// httpClient is an interface representing the HTTP client used for making requests.
+type httpClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Client is the Tezos node RPC client.
+type Client struct {
+	Client httpClient
+	Url    *url.URL
+}
+
+// header is a struct used to parse a block header.
+type header struct {
+	Hash      string    `json:"hash"`
+	Level     int64     `json:"level"`
+	TimeStamp time.Time `json:"timestamp"`
+}
+
+// operation is a struct used to parse a manager operation of a block.
+type operation struct {
+	Hash     string `json:"hash"`
+	Contents []struct {
+		Kind     string `json:"kind"`
+		Source   string `json:"source"`
+		Delegate string `json:"delegate"`
+		Fee      int64  `json:"fee,string"`
+		Counter  int64  `json:"counter,string"`
+		Metadata struct {
+			OperationResult struct {
+				Status           string `json:"status"`
+				ConsumedMilligas int64  `json:"consumed_milligas,string"`
+			} `json:"operation_result"`
+		} `json:"metadata"`
+	} `json:"contents"`
+}
+
+// New creates a new instance of the Tezos node RPC client.
+func New(cfg Config) (*Client, error) {
+	urlRpc, err := url.Parse(cfg.Url)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		Client: &http.Client{
+			Timeout: cfg.Timeout,
+		},
+		Url: urlRpc,
+	}, nil
+}
+
+// GetDelegations reads the blocks of the range one level at a time, up to the head of the chain.
+// Delegation ids are synthesized from the level, they can't be mixed with the ids of the Tezos API.
+func (c *Client) GetDelegations(ctx context.Context, rg entity.DelegationRange) ([]entity.Delegation, error) {
+	var result []entity.Delegation
+	err := c.StreamDelegations(ctx, rg, func(_ context.Context, dgs []entity.Delegation) error {
+		result = append(result, dgs...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// StreamDelegations reads the blocks of the range one level at a time, up to the head of the chain,
+// handing the delegations of each block to onPage. Blocks without delegations are not reported.
+func (c *Client) StreamDelegations(ctx context.Context, rg entity.DelegationRange, onPage adapter.PageFunc) error {
+	head, err := c.getHeader(ctx, headBlock)
+	if err != nil {
+		return err
+	}
+
+	start := int64(firstLevel)
+	switch {
+	case rg.LastId != 0:
+		start = rg.LastId / levelIdFactor
+		if rg.LastId%levelIdFactor >= maxBlockDelegations || start > head.Level {
+			return fmt.Errorf("delegation id %d was not synthesized from a node, "+
+				"the delegations were stored from another source", rg.LastId)
+		}
+	case !rg.From.IsZero():
+		start, err = c.findLevel(ctx, rg.From, head)
+		if err != nil {
+			return err
+		}
+	}
+
+	for level := start; level <= head.Level; level++ {
+		hd, err := c.getHeader(ctx, strconv.FormatInt(level, 10))
+		if err != nil {
+			return err
+		}
+		if !rg.To.IsZero() && !hd.TimeStamp.Before(rg.To) {
+			break
+		}
+		if hd.TimeStamp.Before(rg.From) {
+			continue
+		}
+
+		dgs, err := c.getDelegations(ctx, hd)
+		if err != nil {
+			return err
+		}
+
+		var page []entity.Delegation
+		for _, dg := range dgs {
+			if dg.Id > rg.LastId {
+				page = append(page, dg)
+			}
+		}
+		if len(page) == 0 {
+			continue
+		}
+		if err = onPage(ctx, page); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// findLevel returns the first level baked at or after tm, head.Level+1 if there is none yet.
+func (c *Client) findLevel(ctx context.Context, tm time.Time, head header) (int64, error) {
+	if head.TimeStamp.Before(tm) {
+		return head.Level + 1, nil
+	}
+
+	lo, hi := int64(firstLevel), head.Level
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		hd, err := c.getHeader(ctx, strconv.FormatInt(mid, 10))
+		if err != nil {
+			return 0, err
+		}
+
+		if hd.TimeStamp.Before(tm) {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+
+	return lo, nil
+}
+
+// getDelegations extracts the delegations of the manager operations of a block.
+func (c *Client) getDelegations(ctx context.Context, hd header) ([]entity.Delegation, error) {
+	var ops []operation
+	if err := c.get(ctx, fmt.Sprintf(operationsPath, hd.Level, managerPass), &ops); err != nil {
+		return nil, err
+	}
+
+	var dgs []entity.Delegation
+	position := int64(0)
+	for _, op := range ops {
+		for _, content := range op.Contents {
+			if content.Kind != delegationKind {
+				continue
+			}
+
+			amount, err := c.getBalance(ctx, hd.Level, content.Source)
+			if err != nil {
+				return nil, err
+			}
+			prevBaker, err := c.getDelegate(ctx, hd.Level-1, content.Source)
+			if err != nil {
+				return nil, err
+			}
+
+			result := content.Metadata.OperationResult
+			dgs = append(dgs, entity.Delegation{
+				Amount:    amount,
+				Block:     hd.Hash,
+				Id:        hd.Level*levelIdFactor + position,
+				Delegator: content.Source,
+				TimeStamp: hd.TimeStamp,
+				Hash:      op.Hash,
+				Level:     hd.Level,
+				Baker:     content.Delegate,
+				PrevBaker: prevBaker,
+				Status:    result.Status,
+				BakerFee:  content.Fee,
+				GasUsed:   (result.ConsumedMilligas + milligasPerGas - 1) / milligasPerGas,
+				Counter:   content.Counter,
+			})
+			position++
+		}
+	}
+
+	return dgs, nil
+}
+
+// getBalance returns the balance of an address once the block of the given level was applied.
+func (c *Client) getBalance(ctx context.Context, level int64, address string) (int64, error) {
+	var balance string
+	if err := c.get(ctx, fmt.Sprintf(contractBalance, level, address), &balance); err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseInt(balance, 10, 64)
+}
+
+// getDelegate returns the delegate of an address once the block of the given level was applied, empty if it has none.
+func (c *Client) getDelegate(ctx context.Context, level int64, address string) (string, error) {
+	var delegate string
+	err := c.get(ctx, fmt.Sprintf(contractDelegate, level, address), &delegate)
+	if errors.Is(err, errNotFound) {
+		return "", nil
+	}
+
+	return delegate, err
+}
+
+// getHeader returns the header of a block, given by level or alias.
+func (c *Client) getHeader(ctx context.Context, block string) (header, error) {
+	var hd header
+	err := c.get(ctx, fmt.Sprintf(headerPath, block), &hd)
+	return hd, err
+}
+
+// get calls an RPC of the node and parses its JSON response.
+func (c *Client) get(ctx context.Context, path string, res any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.Url.JoinPath(path).String(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("node RPC %s: %w", path, errNotFound)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("node RPC %s returned non-OK status: %v", path, resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(body, res)
+}
@@ -0,0 +1,180 @@
+package octez
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/frisk038/tezos-delegation-service/domain/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	headerRoute     = regexp.MustCompile(`^/chains/main/blocks/(\w+)/header$`)
+	operationsRoute = regexp.MustCompile(`^/chains/main/blocks/(\d+)/operations/3$`)
+	balanceRoute    = regexp.MustCompile(`^/chains/main/blocks/(\d+)/context/contracts/(\w+)/balance$`)
+	delegateRoute   = regexp.MustCompile(`^/chains/main/blocks/(\d+)/context/contracts/(\w+)/delegate$`)
+)
+
+// newFakeNode serves the RPC responses recorded in testdata.
+// Headers of levels older than the recorded ones are generated, the binary search over levels needs them.
+// Like the node, it answers 404 for the delegate of an account without one.
+func newFakeNode(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var file string
+		if m := headerRoute.FindStringSubmatch(r.URL.Path); m != nil {
+			level, err := strconv.ParseInt(m[1], 10, 64)
+			if err == nil && level < 4000001 {
+				_, _ = fmt.Fprintf(w, `{"hash":"BLockOld%d","level":%d,"timestamp":"2018-06-30T17:39:57Z"}`, level, level)
+				return
+			}
+			file = fmt.Sprintf("header_%s.json", m[1])
+		} else if m = operationsRoute.FindStringSubmatch(r.URL.Path); m != nil {
+			file = fmt.Sprintf("operations_%s.json", m[1])
+		} else if m = balanceRoute.FindStringSubmatch(r.URL.Path); m != nil {
+			file = fmt.Sprintf("balance_%s_%s.json", m[1], m[2])
+		} else if m = delegateRoute.FindStringSubmatch(r.URL.Path); m != nil {
+			file = fmt.Sprintf("delegate_%s_%s.json", m[1], m[2])
+		}
+
+		body, err := os.ReadFile(filepath.Join("testdata", file))
+		if file == "" || err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(body)
+	}))
+}
+
+func TestClient_GetDelegations(t *testing.T) {
+	ctx := context.Background()
+	srv := newFakeNode(t)
+	defer srv.Close()
+	rpcUrl, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+	client := &Client{Client: &http.Client{}, Url: rpcUrl}
+
+	testTime1, _ := time.Parse(time.RFC3339, "2023-09-01T00:00:00Z")
+	testTime3, _ := time.Parse(time.RFC3339, "2023-09-01T00:00:30Z")
+	level1 := entity.Delegation{
+		Amount:    10023000,
+		Block:     "BLockHash1EYgBWC5xSNtEPEyuD8BXbsGc6UDJgDShoLwykjQHn",
+		Id:        4000001000000,
+		Delegator: "tz1Sender1",
+		TimeStamp: testTime1,
+		Hash:      "ooDelegation1pSLr5bUNvKYxX9KsxDgbXhJVRRD6kc8bVvT9ZUg",
+		Level:     4000001,
+		Baker:     "tz1Baker1",
+		Status:    "applied",
+		BakerFee:  380,
+		GasUsed:   1000,
+		Counter:   71829,
+	}
+	level3 := []entity.Delegation{
+		{
+			Amount:    123400,
+			Block:     "BLockHash3EYgBWC5xSNtEPEyuD8BXbsGc6UDJgDShoLwykjQHn",
+			Id:        4000003000000,
+			Delegator: "tz1Sender2",
+			TimeStamp: testTime3,
+			Hash:      "ooBatch3aRpSLr5bUNvKYxX9KsxDgbXhJVRRD6kc8bVvT9ZUg",
+			Level:     4000003,
+			Baker:     "tz1Baker2",
+			PrevBaker: "tz1Baker1",
+			Status:    "applied",
+			BakerFee:  330,
+			GasUsed:   1000,
+			Counter:   103,
+		},
+		{
+			Amount:    531000,
+			Block:     "BLockHash3EYgBWC5xSNtEPEyuD8BXbsGc6UDJgDShoLwykjQHn",
+			Id:        4000003000001,
+			Delegator: "tz1Sender3",
+			TimeStamp: testTime3,
+			Hash:      "ooUndelegate3pSLr5bUNvKYxX9KsxDgbXhJVRRD6kc8bVvT9ZUg",
+			Level:     4000003,
+			PrevBaker: "tz1Baker3",
+			Status:    "applied",
+			BakerFee:  300,
+			GasUsed:   1000,
+			Counter:   55,
+		},
+	}
+
+	t.Run("success_from_time", func(t *testing.T) {
+		got, err := client.GetDelegations(ctx, entity.DelegationRange{From: testTime1})
+		assert.NoError(t, err)
+		assert.Equal(t, append([]entity.Delegation{level1}, level3...), got)
+	})
+
+	t.Run("success_with_range", func(t *testing.T) {
+		got, err := client.GetDelegations(ctx, entity.DelegationRange{From: testTime1, To: testTime3})
+		assert.NoError(t, err)
+		assert.Equal(t, []entity.Delegation{level1}, got)
+	})
+
+	t.Run("success_from_last_id", func(t *testing.T) {
+		got, err := client.GetDelegations(ctx, entity.DelegationRange{LastId: 4000003000000})
+		assert.NoError(t, err)
+		assert.Equal(t, level3[1:], got)
+	})
+
+	t.Run("success_after_head", func(t *testing.T) {
+		got, err := client.GetDelegations(ctx, entity.DelegationRange{From: testTime3.Add(time.Second)})
+		assert.NoError(t, err)
+		assert.Empty(t, got)
+	})
+
+	t.Run("stream_block_by_block", func(t *testing.T) {
+		var pages [][]entity.Delegation
+		err := client.StreamDelegations(ctx, entity.DelegationRange{From: testTime1},
+			func(_ context.Context, dgs []entity.Delegation) error {
+				pages = append(pages, dgs)
+				return nil
+			})
+		assert.NoError(t, err)
+		assert.Equal(t, [][]entity.Delegation{{level1}, level3}, pages)
+	})
+
+	t.Run("missing_block", func(t *testing.T) {
+		got, err := client.GetDelegations(ctx, entity.DelegationRange{LastId: 3999999000000})
+		assert.Error(t, err)
+		assert.Nil(t, got)
+	})
+
+	t.Run("last_id_from_another_source", func(t *testing.T) {
+		// An operation id of the Tezos API.
+		got, err := client.GetDelegations(ctx, entity.DelegationRange{LastId: 623457123456})
+		assert.ErrorContains(t, err, "not synthesized from a node")
+		assert.Nil(t, got)
+
+		got, err = client.GetDelegations(ctx, entity.DelegationRange{LastId: 9000000000000})
+		assert.ErrorContains(t, err, "not synthesized from a node")
+		assert.Nil(t, got)
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		cctx, cancel := context.WithCancel(ctx)
+		cancel()
+		got, err := client.GetDelegations(cctx, entity.DelegationRange{From: testTime1})
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Nil(t, got)
+	})
+
+	t.Run("node_unreachable", func(t *testing.T) {
+		u, _ := url.Parse("http://127.0.0.1:1")
+		got, err := (&Client{Client: &http.Client{}, Url: u}).GetDelegations(ctx, entity.DelegationRange{From: testTime1})
+		assert.Error(t, err)
+		assert.Nil(t, got)
+	})
+}
@@ -3,44 +3,76 @@ package tezos
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math/rand"
 	"net/http"
 	"net/url"
 	"strconv"
 	"time"
 
+	"github.com/frisk038/tezos-delegation-service/domain/adapter"
 	"github.com/frisk038/tezos-delegation-service/domain/entity"
 )
 
 // Config represents the configuration for the Tezos API client.
 type Config struct {
-	Url     string        `yaml:"url" env:"TEZOS-API"`
-	Timeout time.Duration `yaml:"timeout" env-default:"1s"`
-	Limit   int           `yaml:"limit" env-default:"1"`
+	Url        string        `yaml:"url" env:"TEZOS-API"`
+	Timeout    time.Duration `yaml:"timeout" env-default:"1s"`
+	Limit      int           `yaml:"limit" env-default:"1"`
+	Retries    int           `yaml:"retries" env-default:"3"`
+	BackoffMin time.Duration `yaml:"backoff-min" env-default:"500ms"`
+	BackoffMax time.Duration `yaml:"backoff-max" env-default:"30s"`
 }
 
 // httpClient is an interface representing the HTTP client used for making requests.
 type httpClient interface {
-	Get(url string) (*http.Response, error)
+	Do(req *http.Request) (*http.Response, error)
 }
 
 // Client is the Tezos API client.
 type Client struct {
-	Client httpClient
-	Url    *url.URL
-	Limit  int
+	Client     httpClient
+	Url        *url.URL
+	Limit      int
+	Retries    int           // How many times a page is requested again after a retryable error.
+	BackoffMin time.Duration // Wait before the first retry, doubled on each attempt.
+	BackoffMax time.Duration // Upper bound of the wait between two attempts.
+	sleep      func(ctx context.Context, d time.Duration) error
+}
+
+// statusError is returned when the Tezos API answers with a non-OK status.
+type statusError struct {
+	status     string
+	code       int
+	retryAfter time.Duration
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("API returned non-OK status: %v", e.status)
+}
+
+// account is a struct used to parse an account referenced by the Tezos API, null when there is none.
+type account struct {
+	Address string `json:"address"`
 }
 
 // delegation is a struct used to parse the response of the Tezos API.
 type delegation struct {
-	Amount int64  `json:"amount"`
-	Block  string `json:"block"`
-	Id     int64  `json:"id"`
-	Sender struct {
-		Address string `json:"address"`
-	} `json:"sender"`
-	TimeStamp string `json:"timestamp"`
+	Amount       int64   `json:"amount"`
+	Block        string  `json:"block"`
+	Id           int64   `json:"id"`
+	Sender       account `json:"sender"`
+	TimeStamp    string  `json:"timestamp"`
+	Hash         string  `json:"hash"`
+	Level        int64   `json:"level"`
+	NewDelegate  account `json:"newDelegate"`
+	PrevDelegate account `json:"prevDelegate"`
+	Status       string  `json:"status"`
+	BakerFee     int64   `json:"bakerFee"`
+	GasUsed      int64   `json:"gasUsed"`
+	Counter      int64   `json:"counter"`
 }
 
 // New creates a new instance of the Tezos API client.
@@ -54,48 +86,182 @@ func New(cfg Config) (*Client, error) {
 		Client: &http.Client{
 			Timeout: cfg.Timeout,
 		},
-		Url:   urlApi,
-		Limit: cfg.Limit,
+		Url:        urlApi,
+		Limit:      cfg.Limit,
+		Retries:    cfg.Retries,
+		BackoffMin: cfg.BackoffMin,
+		BackoffMax: cfg.BackoffMax,
 	}, nil
 }
 
-// GetDelegations gets delegations and handles pagination.
-func (c *Client) GetDelegations(ctx context.Context, startTime time.Time) ([]entity.Delegation, error) {
-	offset := 0
+// GetDelegations gets delegations and handles pagination, returning all the pages at once.
+func (c *Client) GetDelegations(ctx context.Context, rg entity.DelegationRange) ([]entity.Delegation, error) {
 	var result []entity.Delegation
+	err := c.StreamDelegations(ctx, rg, func(_ context.Context, dgs []entity.Delegation) error {
+		result = append(result, dgs...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// StreamDelegations gets delegations one page at a time and hands each page to onPage before requesting the next one.
+// Pages are walked with an id cursor so that operations sharing a timestamp are never skipped,
+// a failing page is retried on its own without requesting the previous ones again.
+func (c *Client) StreamDelegations(ctx context.Context, rg entity.DelegationRange, onPage adapter.PageFunc) error {
 	for {
-		chunk, err := c.getDelegations(ctx, startTime, offset)
+		chunk, err := c.getDelegationsWithRetry(ctx, rg)
 		if err != nil {
+			return err
+		}
+
+		if len(chunk) != 0 {
+			if err = onPage(ctx, chunk); err != nil {
+				return err
+			}
+		}
+
+		if len(chunk) == 0 || len(chunk) < c.Limit {
+			return nil
+		}
+		rg.LastId = chunk[len(chunk)-1].Id
+	}
+}
+
+// getDelegationsWithRetry retrieves one page of delegations, retrying on timeouts, 5xx and 429 statuses.
+// The wait between attempts grows exponentially with jitter, unless the API tells how long to wait with Retry-After.
+// A Retry-After longer than BackoffMax is not waited for, the error is returned instead.
+func (c *Client) getDelegationsWithRetry(ctx context.Context, rg entity.DelegationRange) ([]entity.Delegation, error) {
+	for attempt := 0; ; attempt++ {
+		if err := ctx.Err(); err != nil {
 			return nil, err
 		}
 
-		result = append(result, chunk...)
-		offset += c.Limit
+		dgs, err := c.getDelegations(ctx, rg)
+		if err == nil {
+			return dgs, nil
+		}
+		if attempt >= c.Retries || !retryable(ctx, err) {
+			return nil, err
+		}
 
-		if len(chunk) < c.Limit {
-			break
+		wait := c.backoff(attempt)
+		var stErr *statusError
+		if errors.As(err, &stErr) && stErr.retryAfter > 0 {
+			// Waiting longer than BackoffMax would hold the caller back: it tries again on its next run instead.
+			if stErr.retryAfter > c.BackoffMax {
+				return nil, err
+			}
+			wait = stErr.retryAfter
+		}
+		if err = c.wait(ctx, wait); err != nil {
+			return nil, err
 		}
 	}
+}
 
-	return result, nil
+// retryable tells whether a request may succeed if sent again.
+// Transport errors are, unless the context is done, while API errors depend on their status.
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	var stErr *statusError
+	if errors.As(err, &stErr) {
+		return stErr.code == http.StatusTooManyRequests ||
+			stErr.code == http.StatusRequestTimeout ||
+			stErr.code >= http.StatusInternalServerError
+	}
+
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
 }
 
-// getDelegations retrieves delegations from the Tezos API starting from a specified timestamp with an offset to skip what has already been read.
-func (c *Client) getDelegations(_ context.Context, startTime time.Time, offset int) ([]entity.Delegation, error) {
-	q := c.Url.Query()
-	q.Set("timestamp.gt", startTime.Format(time.RFC3339))
-	q.Set("offset", strconv.Itoa(offset))
+// backoff returns the wait before the given retry: BackoffMin doubled on each attempt, capped to BackoffMax,
+// of which a random half is dropped so that clients don't retry all at once.
+func (c *Client) backoff(attempt int) time.Duration {
+	d := c.BackoffMin
+	for i := 0; i < attempt && d < c.BackoffMax; i++ {
+		d *= 2
+	}
+	if d > c.BackoffMax {
+		d = c.BackoffMax
+	}
+	if d <= 0 {
+		return 0
+	}
+
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// wait pauses for d or until the context is done.
+func (c *Client) wait(ctx context.Context, d time.Duration) error {
+	if c.sleep != nil {
+		return c.sleep(ctx, d)
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// parseRetryAfter reads a Retry-After header given either in seconds or as an HTTP date.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if tm, err := http.ParseTime(value); err == nil {
+		return time.Until(tm)
+	}
+
+	return 0
+}
+
+// getDelegations retrieves one page of delegations from the Tezos API with an id greater than rg.LastId, sorted by id.
+// The timestamp bounds of the range are only applied when they are not zero.
+func (c *Client) getDelegations(ctx context.Context, rg entity.DelegationRange) ([]entity.Delegation, error) {
+	u := *c.Url
+	q := u.Query()
+	q.Set("id.gt", strconv.FormatInt(rg.LastId, 10))
+	q.Set("sort.asc", "id")
 	q.Set("limit", strconv.Itoa(c.Limit))
-	c.Url.RawQuery = q.Encode()
+	if !rg.From.IsZero() {
+		q.Set("timestamp.ge", rg.From.Format(time.RFC3339))
+	}
+	if !rg.To.IsZero() {
+		q.Set("timestamp.lt", rg.To.Format(time.RFC3339))
+	}
+	u.RawQuery = q.Encode()
 
-	resp, err := c.Client.Get(c.Url.String())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.Client.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer func() { _ = resp.Body.Close() }()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API returned non-OK status: %v", resp.Status)
+		return nil, &statusError{
+			status:     resp.Status,
+			code:       resp.StatusCode,
+			retryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
 	}
 
 	body, err := io.ReadAll(resp.Body)
@@ -108,6 +274,11 @@ func (c *Client) getDelegations(_ context.Context, startTime time.Time, offset i
 		return nil, err
 	}
 
+	return toEntities(jsDgs)
+}
+
+// toEntities maps delegations parsed from the Tezos API onto entities.
+func toEntities(jsDgs []delegation) ([]entity.Delegation, error) {
 	dgs := make([]entity.Delegation, len(jsDgs))
 	for i, dg := range jsDgs {
 		tm, err := time.Parse(time.RFC3339, dg.TimeStamp)
@@ -121,6 +292,14 @@ func (c *Client) getDelegations(_ context.Context, startTime time.Time, offset i
 			Id:        dg.Id,
 			Delegator: dg.Sender.Address,
 			TimeStamp: tm,
+			Hash:      dg.Hash,
+			Level:     dg.Level,
+			Baker:     dg.NewDelegate.Address,
+			PrevBaker: dg.PrevDelegate.Address,
+			Status:    dg.Status,
+			BakerFee:  dg.BakerFee,
+			GasUsed:   dg.GasUsed,
+			Counter:   dg.Counter,
 		}
 	}
 
@@ -19,8 +19,8 @@ type mockHttp struct {
 	mock.Mock
 }
 
-func (c *mockHttp) Get(url string) (*http.Response, error) {
-	called := c.Called(url)
+func (c *mockHttp) Do(req *http.Request) (*http.Response, error) {
+	called := c.Called(req.URL.String())
 	return called.Get(0).(*http.Response), called.Error(1)
 }
 
@@ -28,7 +28,7 @@ func TestClient_getDelegations(t *testing.T) {
 	testTime0, _ := time.Parse(time.RFC3339, "2023-08-01T00:00:00Z")
 	testTime1, _ := time.Parse(time.RFC3339, "2023-09-01T00:00:00Z")
 	testTime2, _ := time.Parse(time.RFC3339, "2023-09-01T01:00:00Z")
-	mockUrl := `=~^https://api\.tzkt\.io/v1/operations/delegations\?limit=.&offset=.&timestamp.gt=2023-08-01T00%3A00%3A00Z`
+	mockUrl := `=~^https://api\.tzkt\.io/v1/operations/delegations\?id.gt=0&limit=.&sort.asc=id&timestamp.ge=2023-08-01T00%3A00%3A00Z`
 	apiUrl, _ := url.Parse("https://api.tzkt.io/v1/operations/delegations")
 
 	t.Run("success", func(t *testing.T) {
@@ -42,7 +42,18 @@ func TestClient_getDelegations(t *testing.T) {
 				"sender": {
 					"address": "tz1Sender1"
 				},
-				"timestamp": "2023-09-01T00:00:00Z"
+				"timestamp": "2023-09-01T00:00:00Z",
+				"hash": "ooHash1",
+				"level": 4000001,
+				"newDelegate": {
+					"alias": "Baker 1",
+					"address": "tz1Baker1"
+				},
+				"prevDelegate": null,
+				"status": "applied",
+				"bakerFee": 380,
+				"gasUsed": 1000,
+				"counter": 71829
 			},
 			{
 				"amount": 123400,
@@ -51,7 +62,16 @@ func TestClient_getDelegations(t *testing.T) {
 				"sender": {
 					"address": "tz1Sender2"
 				},
-				"timestamp": "2023-09-01T01:00:00Z"
+				"timestamp": "2023-09-01T01:00:00Z",
+				"hash": "ooHash2",
+				"level": 4000002,
+				"prevDelegate": {
+					"address": "tz1Baker1"
+				},
+				"status": "failed",
+				"bakerFee": 300,
+				"gasUsed": 100,
+				"counter": 55
 			}
 		]`))
 
@@ -61,7 +81,7 @@ func TestClient_getDelegations(t *testing.T) {
 			Limit:  2,
 		}
 
-		delegations, err := client.getDelegations(context.Background(), testTime0, 0)
+		delegations, err := client.getDelegations(context.Background(), entity.DelegationRange{From: testTime0})
 
 		assert.NoError(t, err)
 		assert.Len(t, delegations, 2)
@@ -72,6 +92,13 @@ func TestClient_getDelegations(t *testing.T) {
 				Id:        1,
 				Delegator: "tz1Sender1",
 				TimeStamp: testTime1,
+				Hash:      "ooHash1",
+				Level:     4000001,
+				Baker:     "tz1Baker1",
+				Status:    "applied",
+				BakerFee:  380,
+				GasUsed:   1000,
+				Counter:   71829,
 			},
 			{
 				Amount:    123400,
@@ -79,6 +106,13 @@ func TestClient_getDelegations(t *testing.T) {
 				Id:        2,
 				Delegator: "tz1Sender2",
 				TimeStamp: testTime2,
+				Hash:      "ooHash2",
+				Level:     4000002,
+				PrevBaker: "tz1Baker1",
+				Status:    "failed",
+				BakerFee:  300,
+				GasUsed:   100,
+				Counter:   55,
 			}},
 			delegations)
 
@@ -89,7 +123,7 @@ func TestClient_getDelegations(t *testing.T) {
 		httpmock.Activate()
 		defer httpmock.DeactivateAndReset()
 		httpmock.RegisterResponder("GET",
-			"https://api.tzkt.io/v1/operations/delegations?limit=1&offset=0&timestamp.gt=2023-08-01T00%3A00%3A00Z", httpmock.NewStringResponder(200, `[
+			"https://api.tzkt.io/v1/operations/delegations?id.gt=0&limit=1&sort.asc=id&timestamp.ge=2023-08-01T00%3A00%3A00Z", httpmock.NewStringResponder(200, `[
 			{
 				"amount": 10023000,
 				"block": "mockBlock1",
@@ -101,7 +135,7 @@ func TestClient_getDelegations(t *testing.T) {
 			}
 		]`))
 		httpmock.RegisterResponder("GET",
-			"https://api.tzkt.io/v1/operations/delegations?limit=1&offset=1&timestamp.gt=2023-08-01T00%3A00%3A00Z", httpmock.NewStringResponder(200, `[
+			"https://api.tzkt.io/v1/operations/delegations?id.gt=1&limit=1&sort.asc=id", httpmock.NewStringResponder(200, `[
 			{
 				"amount": 531000,
 				"block": "mockBlock2",
@@ -119,7 +153,7 @@ func TestClient_getDelegations(t *testing.T) {
 			Limit:  1,
 		}
 
-		delegations, err := client.getDelegations(context.Background(), testTime0, 0)
+		delegations, err := client.getDelegations(context.Background(), entity.DelegationRange{From: testTime0})
 		assert.NoError(t, err)
 		assert.Len(t, delegations, 1)
 		assert.Equal(t, []entity.Delegation{
@@ -133,7 +167,7 @@ func TestClient_getDelegations(t *testing.T) {
 		},
 			delegations)
 
-		delegations, err = client.getDelegations(context.Background(), testTime0, 1)
+		delegations, err = client.getDelegations(context.Background(), entity.DelegationRange{LastId: 1})
 		assert.NoError(t, err)
 		assert.Len(t, delegations, 1)
 		assert.Equal(t, []entity.Delegation{
@@ -161,7 +195,7 @@ func TestClient_getDelegations(t *testing.T) {
 			Limit:  2,
 		}
 
-		delegations, err := client.getDelegations(context.Background(), testTime0, 0)
+		delegations, err := client.getDelegations(context.Background(), entity.DelegationRange{From: testTime0})
 
 		assert.NoError(t, err)
 		assert.Len(t, delegations, 0)
@@ -171,10 +205,29 @@ func TestClient_getDelegations(t *testing.T) {
 		assert.Equal(t, 1, httpmock.GetTotalCallCount())
 	})
 
+	t.Run("success_with_range", func(t *testing.T) {
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+		httpmock.RegisterResponder("GET",
+			"https://api.tzkt.io/v1/operations/delegations?id.gt=0&limit=2&sort.asc=id&timestamp.ge=2023-08-01T00%3A00%3A00Z&timestamp.lt=2023-09-01T00%3A00%3A00Z",
+			httpmock.NewStringResponder(200, `[]`))
+
+		client := &Client{
+			Url:    apiUrl,
+			Client: &http.Client{},
+			Limit:  2,
+		}
+
+		delegations, err := client.getDelegations(context.Background(), entity.DelegationRange{From: testTime0, To: testTime1})
+		assert.NoError(t, err)
+		assert.Empty(t, delegations)
+		assert.Equal(t, 1, httpmock.GetTotalCallCount())
+	})
+
 	t.Run("get_err", func(t *testing.T) {
 		mh := &mockHttp{}
-		mh.On("Get",
-			"https://api.tzkt.io/v1/operations/delegations?limit=2&offset=0&timestamp.gt=2023-08-01T00%3A00%3A00Z").
+		mh.On("Do",
+			"https://api.tzkt.io/v1/operations/delegations?id.gt=0&limit=2&sort.asc=id&timestamp.ge=2023-08-01T00%3A00%3A00Z").
 			Return((*http.Response)(nil), errors.New("err"))
 
 		client := &Client{
@@ -183,7 +236,7 @@ func TestClient_getDelegations(t *testing.T) {
 			Limit:  2,
 		}
 
-		delegations, err := client.getDelegations(context.Background(), testTime0, 0)
+		delegations, err := client.getDelegations(context.Background(), entity.DelegationRange{From: testTime0})
 		assert.Error(t, err)
 		assert.Nil(t, delegations)
 		mh.AssertExpectations(t)
@@ -200,7 +253,7 @@ func TestClient_getDelegations(t *testing.T) {
 			Limit:  2,
 		}
 
-		delegations, err := client.getDelegations(context.Background(), testTime0, 0)
+		delegations, err := client.getDelegations(context.Background(), entity.DelegationRange{From: testTime0})
 		assert.Error(t, err)
 		assert.Nil(t, delegations)
 		assert.Equal(t, 1, httpmock.GetTotalCallCount())
@@ -227,7 +280,7 @@ func TestClient_getDelegations(t *testing.T) {
 			Limit:  2,
 		}
 
-		delegations, err := client.getDelegations(context.Background(), testTime0, 0)
+		delegations, err := client.getDelegations(context.Background(), entity.DelegationRange{From: testTime0})
 
 		assert.Error(t, err)
 		assert.Nil(t, delegations)
@@ -255,7 +308,7 @@ func TestClient_getDelegations(t *testing.T) {
 			Limit:  2,
 		}
 
-		delegations, err := client.getDelegations(context.Background(), testTime0, 0)
+		delegations, err := client.getDelegations(context.Background(), entity.DelegationRange{From: testTime0})
 
 		assert.Error(t, err)
 		assert.Nil(t, delegations)
@@ -273,7 +326,7 @@ func TestClient_GetDelegations(t *testing.T) {
 		httpmock.Activate()
 		defer httpmock.DeactivateAndReset()
 		httpmock.RegisterResponder("GET",
-			"https://api.tzkt.io/v1/operations/delegations?limit=2&offset=0&timestamp.gt=2023-08-01T00%3A00%3A00Z", httpmock.NewStringResponder(200, `[
+			"https://api.tzkt.io/v1/operations/delegations?id.gt=0&limit=2&sort.asc=id&timestamp.ge=2023-08-01T00%3A00%3A00Z", httpmock.NewStringResponder(200, `[
 			{
 				"amount": 10023000,
 				"block": "mockBlock1",
@@ -294,7 +347,7 @@ func TestClient_GetDelegations(t *testing.T) {
 			}
 		]`))
 		httpmock.RegisterResponder("GET",
-			"https://api.tzkt.io/v1/operations/delegations?limit=2&offset=2&timestamp.gt=2023-08-01T00%3A00%3A00Z", httpmock.NewStringResponder(200, `[
+			"https://api.tzkt.io/v1/operations/delegations?id.gt=2&limit=2&sort.asc=id&timestamp.ge=2023-08-01T00%3A00%3A00Z", httpmock.NewStringResponder(200, `[
 			{
 				"amount": 531000,
 				"block": "mockBlock3",
@@ -312,7 +365,7 @@ func TestClient_GetDelegations(t *testing.T) {
 			Limit:  2,
 		}
 
-		delegations, err := client.GetDelegations(ctx, testTime0)
+		delegations, err := client.GetDelegations(ctx, entity.DelegationRange{From: testTime0})
 		assert.NoError(t, err)
 		assert.Len(t, delegations, 3)
 		assert.Equal(t, []entity.Delegation{
@@ -346,7 +399,7 @@ func TestClient_GetDelegations(t *testing.T) {
 		httpmock.Activate()
 		defer httpmock.DeactivateAndReset()
 		httpmock.RegisterResponder("GET",
-			"https://api.tzkt.io/v1/operations/delegations?limit=2&offset=0&timestamp.gt=2023-08-01T00%3A00%3A00Z", httpmock.NewStringResponder(200, `[
+			"https://api.tzkt.io/v1/operations/delegations?id.gt=0&limit=2&sort.asc=id&timestamp.ge=2023-08-01T00%3A00%3A00Z", httpmock.NewStringResponder(200, `[
 			{,
 				"amount": 10023000,
 				"block": "mockBlock1",
@@ -367,7 +420,7 @@ func TestClient_GetDelegations(t *testing.T) {
 			}
 		]`))
 		httpmock.RegisterResponder("GET",
-			"https://api.tzkt.io/v1/operations/delegations?limit=2&offset=2&timestamp.gt=2023-08-01T00%3A00%3A00Z", httpmock.NewStringResponder(200, `[
+			"https://api.tzkt.io/v1/operations/delegations?id.gt=2&limit=2&sort.asc=id&timestamp.ge=2023-08-01T00%3A00%3A00Z", httpmock.NewStringResponder(200, `[
 			{
 				"amount": 531000,
 				"block": "mockBlock3",
@@ -385,7 +438,7 @@ func TestClient_GetDelegations(t *testing.T) {
 			Limit:  2,
 		}
 
-		delegations, err := client.GetDelegations(ctx, testTime0)
+		delegations, err := client.GetDelegations(ctx, entity.DelegationRange{From: testTime0})
 		assert.Error(t, err)
 		assert.Nil(t, delegations)
 		assert.Equal(t, 1, httpmock.GetTotalCallCount())
@@ -395,7 +448,7 @@ func TestClient_GetDelegations(t *testing.T) {
 		httpmock.Activate()
 		defer httpmock.DeactivateAndReset()
 		httpmock.RegisterResponder("GET",
-			"https://api.tzkt.io/v1/operations/delegations?limit=2&offset=0&timestamp.gt=2023-08-01T00%3A00%3A00Z", httpmock.NewStringResponder(200, `[
+			"https://api.tzkt.io/v1/operations/delegations?id.gt=0&limit=2&sort.asc=id&timestamp.ge=2023-08-01T00%3A00%3A00Z", httpmock.NewStringResponder(200, `[
 			{
 				"amount": 10023000,
 				"block": "mockBlock1",
@@ -416,7 +469,7 @@ func TestClient_GetDelegations(t *testing.T) {
 			}
 		]`))
 		httpmock.RegisterResponder("GET",
-			"https://api.tzkt.io/v1/operations/delegations?limit=2&offset=2&timestamp.gt=2023-08-01T00%3A00%3A00Z", httpmock.NewStringResponder(200, `[
+			"https://api.tzkt.io/v1/operations/delegations?id.gt=2&limit=2&sort.asc=id&timestamp.ge=2023-08-01T00%3A00%3A00Z", httpmock.NewStringResponder(200, `[
 			{,
 				"amount": 531000,
 				"block": "mockBlock3",
@@ -434,9 +487,270 @@ func TestClient_GetDelegations(t *testing.T) {
 			Limit:  2,
 		}
 
-		delegations, err := client.GetDelegations(ctx, testTime0)
+		delegations, err := client.GetDelegations(ctx, entity.DelegationRange{From: testTime0})
+		assert.Error(t, err)
+		assert.Nil(t, delegations)
+		assert.Equal(t, 2, httpmock.GetTotalCallCount())
+	})
+
+	t.Run("resume_from_last_id", func(t *testing.T) {
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+		httpmock.RegisterResponder("GET",
+			"https://api.tzkt.io/v1/operations/delegations?id.gt=2&limit=2&sort.asc=id", httpmock.NewStringResponder(200, `[
+			{
+				"amount": 531000,
+				"block": "mockBlock3",
+				"id": 3,
+				"sender": {
+					"address": "tz1Sender3"
+				},
+				"timestamp": "2023-09-01T00:00:00Z"
+			}
+		]`))
+
+		client := &Client{
+			Url:    apiUrl,
+			Client: &http.Client{},
+			Limit:  2,
+		}
+
+		delegations, err := client.GetDelegations(ctx, entity.DelegationRange{LastId: 2})
+		assert.NoError(t, err)
+		assert.Equal(t, []entity.Delegation{
+			{
+				Amount:    531000,
+				Block:     "mockBlock3",
+				Id:        3,
+				Delegator: "tz1Sender3",
+				TimeStamp: testTime1,
+			},
+		}, delegations)
+		assert.Equal(t, 1, httpmock.GetTotalCallCount())
+	})
+}
+
+func TestClient_StreamDelegations(t *testing.T) {
+	apiUrl, _ := url.Parse("https://api.tzkt.io/v1/operations/delegations")
+	ctx := context.Background()
+	testTime1, _ := time.Parse(time.RFC3339, "2023-09-01T00:00:00Z")
+	page1 := `[
+		{"amount": 10023000, "block": "mockBlock1", "id": 1, "sender": {"address": "tz1Sender1"}, "timestamp": "2023-09-01T00:00:00Z"},
+		{"amount": 1093000, "block": "mockBlock2", "id": 2, "sender": {"address": "tz1Sender2"}, "timestamp": "2023-09-01T00:00:00Z"}
+	]`
+	page2 := `[
+		{"amount": 531000, "block": "mockBlock3", "id": 3, "sender": {"address": "tz1Sender3"}, "timestamp": "2023-09-01T00:00:00Z"}
+	]`
+
+	t.Run("page_by_page", func(t *testing.T) {
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+		httpmock.RegisterResponder("GET",
+			"https://api.tzkt.io/v1/operations/delegations?id.gt=0&limit=2&sort.asc=id", httpmock.NewStringResponder(200, page1))
+		httpmock.RegisterResponder("GET",
+			"https://api.tzkt.io/v1/operations/delegations?id.gt=2&limit=2&sort.asc=id", httpmock.NewStringResponder(200, page2))
+
+		client := &Client{
+			Url:    apiUrl,
+			Client: &http.Client{},
+			Limit:  2,
+		}
+
+		var pages [][]int64
+		err := client.StreamDelegations(ctx, entity.DelegationRange{}, func(_ context.Context, dgs []entity.Delegation) error {
+			// The next page is only requested once the current one is handled.
+			assert.Equal(t, len(pages)+1, httpmock.GetTotalCallCount())
+			var ids []int64
+			for _, dg := range dgs {
+				ids = append(ids, dg.Id)
+				assert.Equal(t, testTime1, dg.TimeStamp)
+			}
+			pages = append(pages, ids)
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, [][]int64{{1, 2}, {3}}, pages)
+	})
+
+	t.Run("empty_page_not_handed", func(t *testing.T) {
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+		httpmock.RegisterResponder("GET",
+			"https://api.tzkt.io/v1/operations/delegations?id.gt=3&limit=2&sort.asc=id", httpmock.NewStringResponder(200, `[]`))
+
+		client := &Client{
+			Url:    apiUrl,
+			Client: &http.Client{},
+			Limit:  2,
+		}
+
+		err := client.StreamDelegations(ctx, entity.DelegationRange{LastId: 3}, func(context.Context, []entity.Delegation) error {
+			t.Fatal("no page expected")
+			return nil
+		})
+		assert.NoError(t, err)
+	})
+
+	t.Run("page_err_stops", func(t *testing.T) {
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+		httpmock.RegisterResponder("GET",
+			"https://api.tzkt.io/v1/operations/delegations?id.gt=0&limit=2&sort.asc=id", httpmock.NewStringResponder(200, page1))
+
+		client := &Client{
+			Url:    apiUrl,
+			Client: &http.Client{},
+			Limit:  2,
+		}
+
+		pageErr := errors.New("page err")
+		err := client.StreamDelegations(ctx, entity.DelegationRange{}, func(context.Context, []entity.Delegation) error {
+			return pageErr
+		})
+		assert.ErrorIs(t, err, pageErr)
+		assert.Equal(t, 1, httpmock.GetTotalCallCount())
+	})
+}
+
+func TestClient_GetDelegationsRetry(t *testing.T) {
+	apiUrl, _ := url.Parse("https://api.tzkt.io/v1/operations/delegations")
+	ctx := context.Background()
+	page1 := "https://api.tzkt.io/v1/operations/delegations?id.gt=0&limit=1&sort.asc=id"
+	page2 := "https://api.tzkt.io/v1/operations/delegations?id.gt=1&limit=1&sort.asc=id"
+	body1 := `[{"amount": 10023000, "block": "mockBlock1", "id": 1, "sender": {"address": "tz1Sender1"}, "timestamp": "2023-09-01T00:00:00Z"}]`
+
+	newClient := func(waits *[]time.Duration) *Client {
+		return &Client{
+			Url:        apiUrl,
+			Client:     &http.Client{},
+			Limit:      1,
+			Retries:    2,
+			BackoffMin: 100 * time.Millisecond,
+			BackoffMax: time.Second,
+			sleep: func(_ context.Context, d time.Duration) error {
+				*waits = append(*waits, d)
+				return nil
+			},
+		}
+	}
+
+	t.Run("resume_from_failed_page", func(t *testing.T) {
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+		httpmock.RegisterResponder("GET", page1, httpmock.NewStringResponder(200, body1))
+		httpmock.RegisterResponder("GET", page2, httpmock.NewStringResponder(503, "").
+			Then(httpmock.NewStringResponder(200, `[]`)))
+
+		var waits []time.Duration
+		delegations, err := newClient(&waits).GetDelegations(ctx, entity.DelegationRange{})
+		assert.NoError(t, err)
+		assert.Len(t, delegations, 1)
+		assert.Equal(t, map[string]int{"GET " + page1: 1, "GET " + page2: 2}, httpmock.GetCallCountInfo())
+		assert.Len(t, waits, 1)
+		assert.GreaterOrEqual(t, waits[0], 50*time.Millisecond)
+		assert.LessOrEqual(t, waits[0], 100*time.Millisecond)
+	})
+
+	t.Run("retry_after", func(t *testing.T) {
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+		httpmock.RegisterResponder("GET", page1, httpmock.NewStringResponder(429, "").
+			HeaderSet(http.Header{"Retry-After": {"1"}}).
+			Then(httpmock.NewStringResponder(200, `[]`)))
+
+		var waits []time.Duration
+		delegations, err := newClient(&waits).GetDelegations(ctx, entity.DelegationRange{})
+		assert.NoError(t, err)
+		assert.Empty(t, delegations)
+		assert.Equal(t, []time.Duration{time.Second}, waits)
+		assert.Equal(t, 2, httpmock.GetTotalCallCount())
+	})
+
+	t.Run("retry_after_above_backoff_max", func(t *testing.T) {
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+		httpmock.RegisterResponder("GET", page1, httpmock.NewStringResponder(429, "").
+			HeaderSet(http.Header{"Retry-After": {"7"}}).
+			Then(httpmock.NewStringResponder(200, `[]`)))
+
+		var waits []time.Duration
+		_, err := newClient(&waits).GetDelegations(ctx, entity.DelegationRange{})
+		assert.Error(t, err)
+		assert.Empty(t, waits)
+		assert.Equal(t, 1, httpmock.GetTotalCallCount())
+	})
+
+	t.Run("exponential_backoff", func(t *testing.T) {
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+		httpmock.RegisterResponder("GET", page1, httpmock.NewStringResponder(500, ""))
+
+		var waits []time.Duration
+		delegations, err := newClient(&waits).GetDelegations(ctx, entity.DelegationRange{})
 		assert.Error(t, err)
 		assert.Nil(t, delegations)
+		assert.Equal(t, 3, httpmock.GetTotalCallCount())
+		assert.Len(t, waits, 2)
+		assert.GreaterOrEqual(t, waits[1], 100*time.Millisecond)
+		assert.LessOrEqual(t, waits[1], 200*time.Millisecond)
+	})
+
+	t.Run("transport_err", func(t *testing.T) {
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+		httpmock.RegisterResponder("GET", page1, httpmock.NewErrorResponder(errors.New("connection reset")).
+			Then(httpmock.NewStringResponder(200, `[]`)))
+
+		var waits []time.Duration
+		_, err := newClient(&waits).GetDelegations(ctx, entity.DelegationRange{})
+		assert.NoError(t, err)
 		assert.Equal(t, 2, httpmock.GetTotalCallCount())
 	})
+
+	t.Run("permanent_err", func(t *testing.T) {
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+		httpmock.RegisterResponder("GET", page1, httpmock.NewStringResponder(400, ""))
+
+		var waits []time.Duration
+		_, err := newClient(&waits).GetDelegations(ctx, entity.DelegationRange{})
+		assert.Error(t, err)
+		assert.Empty(t, waits)
+		assert.Equal(t, 1, httpmock.GetTotalCallCount())
+	})
+
+	t.Run("cancelled_not_retried", func(t *testing.T) {
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+		httpmock.RegisterResponder("GET", page1, httpmock.NewStringResponder(200, `[]`))
+
+		cctx, cancel := context.WithCancel(ctx)
+		cancel()
+		var waits []time.Duration
+		_, err := newClient(&waits).GetDelegations(cctx, entity.DelegationRange{})
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Empty(t, waits)
+		assert.Equal(t, 0, httpmock.GetTotalCallCount())
+	})
+
+	t.Run("invalid_json_not_retried", func(t *testing.T) {
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+		httpmock.RegisterResponder("GET", page1, httpmock.NewStringResponder(200, `[{,}]`))
+
+		var waits []time.Duration
+		_, err := newClient(&waits).GetDelegations(ctx, entity.DelegationRange{})
+		assert.Error(t, err)
+		assert.Equal(t, 1, httpmock.GetTotalCallCount())
+	})
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	assert.Equal(t, time.Duration(0), parseRetryAfter(""))
+	assert.Equal(t, 3*time.Second, parseRetryAfter("3"))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("soon"))
+
+	d := parseRetryAfter(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+	assert.Greater(t, d, 50*time.Second)
+	assert.LessOrEqual(t, d, time.Minute)
 }
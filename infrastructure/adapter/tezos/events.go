@@ -0,0 +1,251 @@
+package tezos
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/frisk038/tezos-delegation-service/domain/entity"
+	"golang.org/x/exp/slog"
+	"golang.org/x/net/websocket"
+)
+
+// EventsConfig represents the configuration for the Tezos API events subscription.
+type EventsConfig struct {
+	Url            string        `yaml:"url" env:"TEZOS-EVENTS"`
+	ReconnectDelay time.Duration `yaml:"reconnect-delay" env-default:"5s"`
+	PingInterval   time.Duration `yaml:"ping-interval" env-default:"15s"`
+}
+
+// SignalR message types and separator used by the Tezos API events hub.
+const (
+	recordSeparator    = "\x1e"
+	messageInvocation  = 1
+	messageCompletion  = 3
+	messageClose       = 7
+	operationsTarget   = "operations"
+	subscribeTarget    = "SubscribeToOperations"
+	delegationType     = "delegation"
+	eventState         = 0
+	eventData          = 1
+	eventReorg         = 2
+	handshakeMessage   = `{"protocol":"json","version":1}`
+	subscribeMessage   = `{"type":1,"invocationId":"0","target":"SubscribeToOperations","arguments":[{"types":"delegation"}]}`
+	pingMessage        = `{"type":6}`
+	subscriptionOrigin = "http://localhost/"
+)
+
+// Subscriber receives delegations from the Tezos API events hub.
+type Subscriber struct {
+	Url            *url.URL
+	ReconnectDelay time.Duration
+	PingInterval   time.Duration
+	log            *slog.Logger
+}
+
+// hubMessage is a struct used to parse the messages of the events hub.
+type hubMessage struct {
+	Type      int               `json:"type"`
+	Target    string            `json:"target"`
+	Arguments []json.RawMessage `json:"arguments"`
+	Error     string            `json:"error"`
+}
+
+// operationsEvent is a struct used to parse the operations pushed by the events hub.
+type operationsEvent struct {
+	Type  int     `json:"type"`
+	State int64   `json:"state"`
+	Data  []event `json:"data"`
+}
+
+// event is a delegation pushed by the events hub, the operation type is needed to skip any other operation.
+type event struct {
+	delegation
+	Type string `json:"type"`
+}
+
+// NewSubscriber creates a new instance of the Tezos API events subscriber.
+func NewSubscriber(cfg EventsConfig, log *slog.Logger) (*Subscriber, error) {
+	urlHub, err := url.Parse(cfg.Url)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Subscriber{
+		Url:            urlHub,
+		ReconnectDelay: cfg.ReconnectDelay,
+		PingInterval:   cfg.PingInterval,
+		log:            log,
+	}, nil
+}
+
+// Subscribe listens to the delegations pushed by the events hub until ctx is done.
+// The connection is opened again after ReconnectDelay whenever it fails or a callback returns an error.
+func (s *Subscriber) Subscribe(ctx context.Context,
+	onSync func(ctx context.Context) error,
+	onDelegations func(ctx context.Context, dgs []entity.Delegation) error) error {
+	for {
+		err := s.listen(ctx, onSync, onDelegations)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		s.log.Error("events subscription lost", "err", err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(s.ReconnectDelay):
+		}
+	}
+}
+
+// listen opens one connection to the events hub and handles its messages until it fails.
+func (s *Subscriber) listen(ctx context.Context,
+	onSync func(ctx context.Context) error,
+	onDelegations func(ctx context.Context, dgs []entity.Delegation) error) error {
+	ws, err := websocket.Dial(s.Url.String(), "", subscriptionOrigin)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = ws.Close() }()
+
+	// Closing the connection is the only way to interrupt a pending read.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = ws.Close()
+		case <-done:
+		}
+	}()
+
+	if err = websocket.Message.Send(ws, handshakeMessage+recordSeparator); err != nil {
+		return err
+	}
+	msgs, err := receive(ws)
+	if err != nil {
+		return err
+	}
+	if len(msgs) == 0 || msgs[0].Error != "" {
+		return fmt.Errorf("events hub handshake failed: %v", msgs)
+	}
+
+	if err = websocket.Message.Send(ws, subscribeMessage+recordSeparator); err != nil {
+		return err
+	}
+	go s.ping(ws, done)
+
+	for {
+		msgs, err = receive(ws)
+		if err != nil {
+			return err
+		}
+
+		for _, msg := range msgs {
+			if err = handle(ctx, msg, onSync, onDelegations); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// ping keeps the connection alive until done is closed.
+func (s *Subscriber) ping(ws *websocket.Conn, done <-chan struct{}) {
+	if s.PingInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(s.PingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := websocket.Message.Send(ws, pingMessage+recordSeparator); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// receive reads one frame of the events hub, which may hold several messages.
+func receive(ws *websocket.Conn) ([]hubMessage, error) {
+	var frame string
+	if err := websocket.Message.Receive(ws, &frame); err != nil {
+		return nil, err
+	}
+
+	return parseFrame(frame)
+}
+
+// parseFrame splits a frame of the events hub into its messages.
+func parseFrame(frame string) ([]hubMessage, error) {
+	var msgs []hubMessage
+	for _, raw := range strings.Split(frame, recordSeparator) {
+		if raw == "" {
+			continue
+		}
+
+		var msg hubMessage
+		if err := json.Unmarshal([]byte(raw), &msg); err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, msg)
+	}
+
+	return msgs, nil
+}
+
+// handle dispatches one message of the events hub to the callbacks.
+func handle(ctx context.Context, msg hubMessage,
+	onSync func(ctx context.Context) error,
+	onDelegations func(ctx context.Context, dgs []entity.Delegation) error) error {
+	switch msg.Type {
+	case messageCompletion:
+		if msg.Error != "" {
+			return fmt.Errorf("%s failed: %s", subscribeTarget, msg.Error)
+		}
+		return nil
+	case messageClose:
+		return fmt.Errorf("events hub closed the connection: %s", msg.Error)
+	case messageInvocation:
+		if msg.Target != operationsTarget || len(msg.Arguments) == 0 {
+			return nil
+		}
+	default:
+		return nil
+	}
+
+	var ev operationsEvent
+	if err := json.Unmarshal(msg.Arguments[0], &ev); err != nil {
+		return err
+	}
+
+	switch ev.Type {
+	case eventState, eventReorg:
+		return onSync(ctx)
+	case eventData:
+		jsDgs := make([]delegation, 0, len(ev.Data))
+		for _, op := range ev.Data {
+			if op.Type == delegationType {
+				jsDgs = append(jsDgs, op.delegation)
+			}
+		}
+		if len(jsDgs) == 0 {
+			return nil
+		}
+
+		dgs, err := toEntities(jsDgs)
+		if err != nil {
+			return err
+		}
+		return onDelegations(ctx, dgs)
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,254 @@
+package tezos
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/frisk038/tezos-delegation-service/domain/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slog"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	subscribedFrame = `{"type":3,"invocationId":"0","result":100}` + "\x1e" +
+		`{"type":1,"target":"operations","arguments":[{"type":0,"state":100}]}` + "\x1e"
+	dataFrame = `{"type":1,"target":"operations","arguments":[{"type":1,"state":101,"data":[
+			{
+				"type": "delegation",
+				"amount": 10023000,
+				"block": "mockBlock1",
+				"id": 1,
+				"sender": {
+					"address": "tz1Sender1"
+				},
+				"timestamp": "2023-09-01T00:00:00Z"
+			},
+			{
+				"type": "transaction",
+				"amount": 5,
+				"block": "mockBlock1",
+				"id": 2,
+				"sender": {
+					"address": "tz1Sender2"
+				},
+				"timestamp": "2023-09-01T00:00:00Z"
+			}
+		]}]}` + "\x1e"
+)
+
+// newFakeHub starts a local events hub which accepts the SignalR handshake and the subscription, then sends frames.
+// The connection is kept open until the client closes it unless hangUp is set.
+func newFakeHub(t *testing.T, hangUp bool, frames ...string) (*httptest.Server, *url.URL) {
+	srv := httptest.NewServer(websocket.Handler(func(ws *websocket.Conn) {
+		var msg string
+		if err := websocket.Message.Receive(ws, &msg); err != nil {
+			return
+		}
+		assert.Equal(t, handshakeMessage+recordSeparator, msg)
+		_ = websocket.Message.Send(ws, "{}"+recordSeparator)
+
+		if err := websocket.Message.Receive(ws, &msg); err != nil {
+			return
+		}
+		assert.Equal(t, subscribeMessage+recordSeparator, msg)
+
+		for _, frame := range frames {
+			if err := websocket.Message.Send(ws, frame); err != nil {
+				return
+			}
+		}
+		if hangUp {
+			return
+		}
+
+		for websocket.Message.Receive(ws, &msg) == nil {
+		}
+	}))
+
+	u, err := url.Parse(strings.Replace(srv.URL, "http", "ws", 1))
+	require.NoError(t, err)
+	return srv, u
+}
+
+func TestSubscriber_Subscribe(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	testTime1, _ := time.Parse(time.RFC3339, "2023-09-01T00:00:00Z")
+	expected := []entity.Delegation{
+		{
+			Amount:    10023000,
+			Block:     "mockBlock1",
+			Id:        1,
+			Delegator: "tz1Sender1",
+			TimeStamp: testTime1,
+		},
+	}
+
+	t.Run("success", func(t *testing.T) {
+		srv, u := newFakeHub(t, false, subscribedFrame, dataFrame)
+		defer srv.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		syncs := 0
+		var got []entity.Delegation
+		s := &Subscriber{Url: u, ReconnectDelay: time.Millisecond, PingInterval: time.Millisecond, log: log}
+		err := s.Subscribe(ctx,
+			func(ctx context.Context) error {
+				syncs++
+				return nil
+			},
+			func(ctx context.Context, dgs []entity.Delegation) error {
+				got = dgs
+				cancel()
+				return nil
+			})
+
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, 1, syncs)
+		assert.Equal(t, expected, got)
+	})
+
+	t.Run("sync_on_reconnect", func(t *testing.T) {
+		srv, u := newFakeHub(t, true, subscribedFrame)
+		defer srv.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		syncs := 0
+		s := &Subscriber{Url: u, ReconnectDelay: time.Millisecond, log: log}
+		err := s.Subscribe(ctx,
+			func(ctx context.Context) error {
+				syncs++
+				if syncs == 2 {
+					cancel()
+				}
+				return nil
+			},
+			func(ctx context.Context, dgs []entity.Delegation) error {
+				return nil
+			})
+
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, 2, syncs)
+	})
+
+	t.Run("reconnect_on_callback_err", func(t *testing.T) {
+		srv, u := newFakeHub(t, false, subscribedFrame, dataFrame)
+		defer srv.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		syncs, calls := 0, 0
+		s := &Subscriber{Url: u, ReconnectDelay: time.Millisecond, log: log}
+		err := s.Subscribe(ctx,
+			func(ctx context.Context) error {
+				syncs++
+				return nil
+			},
+			func(ctx context.Context, dgs []entity.Delegation) error {
+				calls++
+				if calls == 1 {
+					return errors.New("err")
+				}
+				cancel()
+				return nil
+			})
+
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, 2, syncs)
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("reconnect_on_close", func(t *testing.T) {
+		srv, u := newFakeHub(t, false, subscribedFrame, `{"type":7,"error":"bye"}`+recordSeparator)
+		defer srv.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		syncs := 0
+		s := &Subscriber{Url: u, ReconnectDelay: time.Millisecond, log: log}
+		err := s.Subscribe(ctx,
+			func(ctx context.Context) error {
+				syncs++
+				if syncs == 2 {
+					cancel()
+				}
+				return nil
+			},
+			func(ctx context.Context, dgs []entity.Delegation) error {
+				return nil
+			})
+
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, 2, syncs)
+	})
+
+	t.Run("hub_unreachable", func(t *testing.T) {
+		u, _ := url.Parse("ws://127.0.0.1:1")
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		s := &Subscriber{Url: u, ReconnectDelay: 10 * time.Millisecond, log: log}
+		err := s.Subscribe(ctx,
+			func(ctx context.Context) error {
+				return nil
+			},
+			func(ctx context.Context, dgs []entity.Delegation) error {
+				return nil
+			})
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
+func TestSubscriber_handle(t *testing.T) {
+	ctx := context.Background()
+	noSync := func(ctx context.Context) error {
+		return errors.New("unexpected sync")
+	}
+	noDelegations := func(ctx context.Context, dgs []entity.Delegation) error {
+		return errors.New("unexpected delegations")
+	}
+
+	t.Run("reorg", func(t *testing.T) {
+		msgs, err := parseFrame(`{"type":1,"target":"operations","arguments":[{"type":2,"state":99}]}`)
+		require.NoError(t, err)
+
+		synced := false
+		err = handle(ctx, msgs[0], func(ctx context.Context) error {
+			synced = true
+			return nil
+		}, noDelegations)
+		assert.NoError(t, err)
+		assert.True(t, synced)
+	})
+
+	t.Run("other_operations", func(t *testing.T) {
+		msgs, err := parseFrame(`{"type":1,"target":"operations","arguments":[{"type":1,"state":101,"data":[{"type":"transaction","id":2}]}]}`)
+		require.NoError(t, err)
+
+		assert.NoError(t, handle(ctx, msgs[0], noSync, noDelegations))
+	})
+
+	t.Run("subscription_err", func(t *testing.T) {
+		msgs, err := parseFrame(`{"type":3,"invocationId":"0","error":"unknown method"}`)
+		require.NoError(t, err)
+
+		assert.Error(t, handle(ctx, msgs[0], noSync, noDelegations))
+	})
+
+	t.Run("non_RFC333_date", func(t *testing.T) {
+		msgs, err := parseFrame(`{"type":1,"target":"operations","arguments":[{"type":1,"state":101,"data":[
+			{"type":"delegation","id":1,"timestamp":"2023-09-z"}]}]}`)
+		require.NoError(t, err)
+
+		assert.Error(t, handle(ctx, msgs[0], noSync, noDelegations))
+	})
+}
@@ -24,22 +24,185 @@ type Client struct {
 }
 
 const (
-	insertDelegation = `INSERT INTO delegations
-							(id, ts, amount, delegator, block)
-						VALUES ($1, $2, $3, $4, $5);`
-	selectLastDelegation = `SELECT ts
+	// delegationColumns are the columns read by scanDelegation, the details of the older delegations being null.
+	delegationColumns = `ts, amount, delegator, block, id, COALESCE(hash, ''), COALESCE(level, 0),
+							COALESCE(baker, ''), COALESCE(prev_baker, ''), COALESCE(status, ''),
+							COALESCE(baker_fee, 0), COALESCE(gas_used, 0), COALESCE(counter, 0)`
+	upsertDelegation = `INSERT INTO delegations
+							(id, ts, amount, delegator, block, hash, level, baker, prev_baker, status,
+							baker_fee, gas_used, counter, kind)
+						VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), NULLIF($9, ''), $10, $11, $12, $13,
+							NULLIF($14, ''))
+						ON CONFLICT (id) DO UPDATE
+							SET ts = EXCLUDED.ts, amount = EXCLUDED.amount,
+								delegator = EXCLUDED.delegator, block = EXCLUDED.block,
+								hash = EXCLUDED.hash, level = EXCLUDED.level,
+								baker = EXCLUDED.baker, prev_baker = EXCLUDED.prev_baker,
+								status = EXCLUDED.status, baker_fee = EXCLUDED.baker_fee,
+								gas_used = EXCLUDED.gas_used, counter = EXCLUDED.counter, kind = EXCLUDED.kind
+							WHERE (delegations.ts, delegations.amount, delegations.delegator, delegations.block,
+									delegations.hash, delegations.level, delegations.baker, delegations.prev_baker,
+									delegations.status, delegations.baker_fee, delegations.gas_used, delegations.counter,
+									delegations.kind)
+								IS DISTINCT FROM (EXCLUDED.ts, EXCLUDED.amount, EXCLUDED.delegator, EXCLUDED.block,
+									EXCLUDED.hash, EXCLUDED.level, EXCLUDED.baker, EXCLUDED.prev_baker,
+									EXCLUDED.status, EXCLUDED.baker_fee, EXCLUDED.gas_used, EXCLUDED.counter,
+									EXCLUDED.kind)
+						RETURNING (xmax = 0) AS inserted;`
+	selectLastDelegationId = `SELECT id
 							FROM delegations
-							ORDER BY ts DESC
+							ORDER BY id DESC
 							LIMIT 1;`
-	selectDelegation = `SELECT ts, amount, delegator, block, id
+	selectRecentDelegations = `SELECT ` + delegationColumns + `
+							FROM delegations
+							WHERE level > (SELECT MAX(level) FROM delegations) - $1
+							ORDER BY id;`
+	deleteDelegations = `DELETE FROM delegations
+							WHERE id = ANY($1)
+							RETURNING delegator, ts;`
+	// upsertDelegatorState moves the state of the delegators forward to their last applied operation among the given ids,
+	// an operation older than the one the state is built on, e.g. stored by a backfill, leaves it unchanged.
+	upsertDelegatorState = `INSERT INTO delegator_state
+							(delegator, baker, since_ts, since_level, last_id, amount)
+						SELECT DISTINCT ON (delegator) delegator, baker, ts, level, id, amount
+							FROM delegations
+							WHERE id = ANY($1) AND COALESCE(status, 'applied') = 'applied'
+							ORDER BY delegator, id DESC
+						ON CONFLICT (delegator) DO UPDATE
+							SET baker = EXCLUDED.baker, since_ts = EXCLUDED.since_ts,
+								since_level = EXCLUDED.since_level, last_id = EXCLUDED.last_id,
+								amount = EXCLUDED.amount, updated_at = now()
+							WHERE delegator_state.last_id < EXCLUDED.last_id
+								OR delegator_state.last_id = EXCLUDED.last_id
+								AND (delegator_state.baker, delegator_state.since_ts,
+									delegator_state.since_level, delegator_state.amount)
+								IS DISTINCT FROM (EXCLUDED.baker, EXCLUDED.since_ts, EXCLUDED.since_level, EXCLUDED.amount);`
+	// deleteDelegatorState and rebuildDelegatorState compute again the state of the given delegators from what is stored.
+	deleteDelegatorState = `DELETE FROM delegator_state
+							WHERE delegator = ANY($1);`
+	rebuildDelegatorState = `INSERT INTO delegator_state
+							(delegator, baker, since_ts, since_level, last_id, amount)
+						SELECT DISTINCT ON (delegator) delegator, baker, ts, level, id, amount
+							FROM delegations
+							WHERE delegator = ANY($1) AND COALESCE(status, 'applied') = 'applied'
+							ORDER BY delegator, id DESC;`
+	selectDelegatorHistory = `SELECT ` + delegationColumns + `
+							FROM delegations
+							WHERE delegator = $1
+							ORDER BY id
+							LIMIT $2
+							OFFSET $3;`
+	selectBakerDelegators = `SELECT delegator, COALESCE(baker, ''), since_ts, COALESCE(since_level, 0), last_id, amount
+							FROM delegator_state
+							WHERE baker = $1
+							ORDER BY %s, delegator
+							LIMIT $2
+							OFFSET $3;`
+	// selectBakerSnapshot rebuilds the state of every delegator from its last applied operation matching the filter,
+	// then keeps the ones delegating to the baker.
+	selectBakerSnapshot = `SELECT delegator, COALESCE(baker, ''), ts, COALESCE(level, 0), id, amount
+							FROM (SELECT DISTINCT ON (delegator) delegator, baker, ts, level, id, amount
+								FROM delegations
+								%s
+								ORDER BY delegator, id DESC) AS snapshot
+							WHERE baker = $1
+							ORDER BY amount DESC, delegator;`
+	selectBakerTotals = `SELECT COUNT(*), COALESCE(SUM(amount), 0)::bigint
+							FROM delegator_state
+							WHERE baker = $1;`
+	selectDelegatorState = `SELECT delegator, COALESCE(baker, ''), since_ts, COALESCE(since_level, 0), last_id, amount
+							FROM delegator_state
+							WHERE delegator = $1;`
+	selectDelegation = `SELECT ` + delegationColumns + `
 							FROM delegations
 							%s
-							ORDER BY ts DESC
+							ORDER BY %s
 							LIMIT $1
 							OFFSET $2;`
-	whereClause = `WHERE ts >= $3 AND ts < $4`
+	exportDelegations = `SELECT ` + delegationColumns + `
+							FROM delegations
+							%s
+							ORDER BY %s;`
+	countDelegations = `SELECT COUNT(*)
+							FROM delegations
+							%s;`
+	// selectDailyStats reads the days of the rollup matching the filter.
+	selectDailyStats = `SELECT day::timestamp, count, total, delegators, median
+							FROM delegations_daily
+							%s
+							ORDER BY day;`
+	// selectDelegationStats sums the days of the rollup matching the filter by period, $1 being the unit of date_trunc.
+	// The distinct delegators are counted from the delegators of its days, the median is computed from its delegations.
+	selectDelegationStats = `WITH buckets AS (
+							SELECT date_trunc($1, day::timestamp) AS bucket, MIN(day) AS first, MAX(day) AS last,
+								SUM(count)::bigint AS count, SUM(total)::bigint AS total
+							FROM delegations_daily
+							%s
+							GROUP BY 1)
+						SELECT b.bucket, b.count, b.total,
+							(SELECT COUNT(DISTINCT delegator)
+								FROM delegations_daily_delegators
+								WHERE day >= b.first AND day <= b.last),
+							(SELECT COALESCE(round(percentile_cont(0.5) WITHIN GROUP (ORDER BY amount)), 0)::bigint
+								FROM delegations
+								WHERE ts >= b.first AND ts < b.last + 1 AND COALESCE(status, 'applied') = 'applied')
+						FROM buckets AS b
+						ORDER BY b.bucket;`
+	// lockDailyStats takes a lock on each day of $1, in order, until the end of the transaction so that the days are
+	// computed by a single transaction at a time, each reading what the previous one committed.
+	lockDailyStats = `SELECT pg_advisory_xact_lock(hashtext('delegations_daily'), d.day - DATE '2000-01-01')
+							FROM (SELECT DISTINCT day FROM unnest($1::date[]) AS u(day) ORDER BY day) AS d;`
+	// upsertDailyStats computes the aggregates of the applied delegations of each day of $1, as dates.
+	upsertDailyStats = `INSERT INTO delegations_daily
+							(day, count, total, delegators, median)
+						SELECT d.day, COUNT(*), SUM(dg.amount)::bigint, COUNT(DISTINCT dg.delegator),
+								round(percentile_cont(0.5) WITHIN GROUP (ORDER BY dg.amount))::bigint
+							FROM (SELECT DISTINCT unnest($1::date[])) AS d(day)
+							JOIN delegations AS dg ON dg.ts >= d.day AND dg.ts < d.day + 1
+							WHERE COALESCE(dg.status, 'applied') = 'applied'
+							GROUP BY d.day
+						ON CONFLICT (day) DO UPDATE
+							SET count = EXCLUDED.count, total = EXCLUDED.total, delegators = EXCLUDED.delegators,
+								median = EXCLUDED.median, updated_at = now();`
+	// deleteDailyStats removes the days of $1 left without applied delegation, along with their delegators.
+	deleteDailyStats = `DELETE FROM delegations_daily AS dd
+							WHERE dd.day = ANY($1::date[])
+								AND NOT EXISTS (SELECT 1
+									FROM delegations AS dg
+									WHERE dg.ts >= dd.day AND dg.ts < dd.day + 1
+										AND COALESCE(dg.status, 'applied') = 'applied');`
+	deleteDailyDelegators = `DELETE FROM delegations_daily_delegators
+							WHERE day = ANY($1::date[]);`
+	insertDailyDelegators = `INSERT INTO delegations_daily_delegators
+							(day, delegator)
+						SELECT DISTINCT d.day, dg.delegator
+							FROM (SELECT DISTINCT unnest($1::date[])) AS d(day)
+							JOIN delegations AS dg ON dg.ts >= d.day AND dg.ts < d.day + 1
+							WHERE COALESCE(dg.status, 'applied') = 'applied';`
+	// selectDelegationDays lists the days holding applied delegations along with the days of the rollup.
+	selectDelegationDays = `SELECT DISTINCT ts::date
+							FROM delegations
+							WHERE COALESCE(status, 'applied') = 'applied'
+						UNION
+						SELECT day
+							FROM delegations_daily
+						ORDER BY 1;`
+	selectCheckpoint = `SELECT cursor_ts
+							FROM backfill_checkpoints
+							WHERE range_from = $1 AND range_to = $2;`
+	upsertCheckpoint = `INSERT INTO backfill_checkpoints
+							(range_from, range_to, cursor_ts)
+						VALUES ($1, $2, $3)
+						ON CONFLICT (range_from, range_to) DO UPDATE
+							SET cursor_ts = EXCLUDED.cursor_ts, updated_at = now();`
 )
 
+// bakerDelegatorsOrder maps the sorts of the delegators of a baker onto their order by clause.
+var bakerDelegatorsOrder = map[string]string{
+	entity.SortAmount: "amount DESC",
+	entity.SortDate:   "since_ts DESC",
+}
+
 // New creates a new PostgreSQL client for handling delegations.
 func New(cfg Config, logger *slog.Logger) (*Client, error) {
 	dbPool, err := pgxpool.New(context.Background(), cfg.ConnUrl)
@@ -53,44 +216,293 @@ func New(cfg Config, logger *slog.Logger) (*Client, error) {
 	}, nil
 }
 
-// InsertDelegations inserts a batch of delegations into the database.
-func (c *Client) InsertDelegations(ctx context.Context, dgs []entity.Delegation) error {
+// InsertDelegations stores a batch of delegations in a single transaction.
+// Inserts are idempotent on id: an identical row is skipped and a changed one is updated.
+// The state of the delegators of the batch and the daily rollup of its days are updated within the same transaction.
+func (c *Client) InsertDelegations(ctx context.Context, dgs []entity.Delegation) (entity.InsertSummary, error) {
+	_, summary, err := c.ReplaceDelegations(ctx, nil, dgs)
+	return summary, err
+}
+
+// ReplaceDelegations removes the delegations with the given ids and stores the given ones, in a single transaction,
+// e.g. so that the delegations of orphaned blocks are never missing from the database before the chain's are stored.
+// It returns how many delegations were deleted along with a summary of the stored ones, stored as by InsertDelegations.
+// The state of the delegators and the daily rollup of the days of both are built again within the same transaction.
+func (c *Client) ReplaceDelegations(ctx context.Context, ids []int64, dgs []entity.Delegation) (int64, entity.InsertSummary, error) {
+	tx, err := c.conn.Begin(ctx)
+	if err != nil {
+		return 0, entity.InsertSummary{}, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	deleted, err := removeDelegations(ctx, tx, ids)
+	if err != nil {
+		return 0, entity.InsertSummary{}, err
+	}
+	summary, err := storeDelegations(ctx, tx, dgs)
+	if err != nil {
+		return 0, entity.InsertSummary{}, err
+	}
+	if err = refreshDailyStats(ctx, tx, entity.DelegationDays(append(deleted, dgs...))); err != nil {
+		return 0, entity.InsertSummary{}, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return 0, entity.InsertSummary{}, err
+	}
+
+	return int64(len(deleted)), summary, nil
+}
+
+// storeDelegations upserts the delegations, moves the state of their delegators forward and writes the events of the
+// webhook subscriptions, within the transaction.
+func storeDelegations(ctx context.Context, tx pgx.Tx, dgs []entity.Delegation) (entity.InsertSummary, error) {
+	if len(dgs) == 0 {
+		return entity.InsertSummary{}, nil
+	}
+
 	batch := &pgx.Batch{}
-	for _, dg := range dgs {
+	ids := make([]int64, len(dgs))
+	for i, dg := range dgs {
 		// Queue each delegation for insertion using the prepared SQL statement.
-		batch.Queue(insertDelegation, dg.Id, dg.TimeStamp, dg.Amount, dg.Delegator, dg.Block)
+		batch.Queue(upsertDelegation, dg.Id, dg.TimeStamp, dg.Amount, dg.Delegator, dg.Block, dg.Hash, dg.Level,
+			dg.Baker, dg.PrevBaker, dg.Status, dg.BakerFee, dg.GasUsed, dg.Counter, dg.Kind())
+		ids[i] = dg.Id
+	}
+
+	summary, changed, err := readUpsertResults(tx.SendBatch(ctx, batch), dgs)
+	if err != nil {
+		return entity.InsertSummary{}, err
+	}
+
+	if _, err = tx.Exec(ctx, upsertDelegatorState, ids); err != nil {
+		return entity.InsertSummary{}, err
+	}
+	// A delegation stored again unchanged was notified when first stored, its events may have been deleted since.
+	if err = queueWebhookEvents(ctx, tx, changed); err != nil {
+		return entity.InsertSummary{}, err
+	}
+
+	return summary, nil
+}
+
+// removeDelegations deletes the delegations with the given ids along with their webhook events not delivered yet, and
+// builds again the state of their delegators from the delegations left, within the transaction. It returns the delegator and timestamp of each deleted delegation.
+func removeDelegations(ctx context.Context, tx pgx.Tx, ids []int64) ([]entity.Delegation, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	rows, err := tx.Query(ctx, deleteDelegations, ids)
+	if err != nil {
+		return nil, err
+	}
+	deleted, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (entity.Delegation, error) {
+		var dg entity.Delegation
+		err := row.Scan(&dg.Delegator, &dg.TimeStamp)
+		return dg, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	delegators := make([]string, len(deleted))
+	for i, dg := range deleted {
+		delegators[i] = dg.Delegator
+	}
+	if _, err = tx.Exec(ctx, deleteDelegatorState, delegators); err != nil {
+		return nil, err
+	}
+	if _, err = tx.Exec(ctx, rebuildDelegatorState, delegators); err != nil {
+		return nil, err
+	}
+	if err = dropPendingEvents(ctx, tx, ids); err != nil {
+		return nil, err
 	}
 
-	br := c.conn.SendBatch(ctx, batch)
+	return deleted, nil
+}
+
+// readUpsertResults reads the result of the upsert queued for each delegation and closes the batch, returning the
+// delegations inserted or updated. A statement returning no row is a conflict on an identical delegation.
+func readUpsertResults(br pgx.BatchResults, dgs []entity.Delegation) (entity.InsertSummary, []entity.Delegation, error) {
 	defer func() { _ = br.Close() }()
 
-	_, err := br.Exec()
+	var summary entity.InsertSummary
+	var changed []entity.Delegation
+	for _, dg := range dgs {
+		var inserted bool
+		err := br.QueryRow().Scan(&inserted)
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			summary.Skipped++
+			continue
+		case err != nil:
+			return entity.InsertSummary{}, nil, err
+		case inserted:
+			summary.Inserted++
+		default:
+			summary.Updated++
+		}
+		changed = append(changed, dg)
+	}
+
+	return summary, changed, br.Close()
+}
+
+// SelectDelegations returns a slice of delegation from the database in the requested order, the most recent first by default,
+// it also handles pagination either by offset or after a cursor.
+// Delegations stored before their status was recorded are considered applied.
+func (c *Client) SelectDelegations(ctx context.Context, dgr entity.DelegationRequest) ([]entity.Delegation, error) {
+	f := filter{params: []any{dgr.Limit, dgr.Offset}}
+	f.delegations(dgr)
+	order := f.sorted(dgr)
+
+	rows, err := c.conn.Query(ctx, fmt.Sprintf(selectDelegation, f.where(), order), f.params...)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanDelegations(rows)
+}
+
+// ExportDelegations hands every delegation matching the filters of the request to onRow, in the requested order and
+// regardless of its pagination. Rows are read from the connection as Postgres sends them, so that memory stays flat
+// however many they are. An error returned by onRow stops the export and is returned as is.
+func (c *Client) ExportDelegations(ctx context.Context, dgr entity.DelegationRequest, onRow func(dg entity.Delegation) error) error {
+	var f filter
+	f.delegations(dgr)
+	dgr.After = entity.DelegationCursor{}
+	order := f.sorted(dgr)
+
+	rows, err := c.conn.Query(ctx, fmt.Sprintf(exportDelegations, f.where(), order), f.params...)
 	if err != nil {
 		return err
 	}
+	defer rows.Close()
+
+	for rows.Next() {
+		dg, err := scanDelegation(rows)
+		if err != nil {
+			return err
+		}
+		if err = onRow(dg); err != nil {
+			return err
+		}
+	}
 
-	return nil
+	return rows.Err()
 }
 
-// SelectDelegations returns a slice of delegation from the database, it also handles pagination.
-func (c *Client) SelectDelegations(ctx context.Context, dgr entity.DelegationRequest) ([]entity.Delegation, error) {
-	param := []interface{}{dgr.Limit, dgr.Offset}
-	where := ""
-	if !dgr.Date.IsZero() {
-		where = whereClause
-		param = append(param, dgr.Date, dgr.Date.AddDate(1, 0, 0))
+// CountDelegations returns how many delegations match the filters of the request, regardless of its pagination.
+func (c *Client) CountDelegations(ctx context.Context, dgr entity.DelegationRequest) (int64, error) {
+	var f filter
+	f.delegations(dgr)
+
+	var count int64
+	err := c.conn.QueryRow(ctx, fmt.Sprintf(countDelegations, f.where()), f.params...).Scan(&count)
+	if err != nil {
+		return 0, err
 	}
 
-	rows, err := c.conn.Query(ctx, fmt.Sprintf(selectDelegation, where), param...)
+	return count, nil
+}
+
+// SelectDelegationStats returns the aggregates of the applied delegations by period, from the oldest period to the newest.
+// They are read from the daily rollup, so the time range is applied by day.
+func (c *Client) SelectDelegationStats(ctx context.Context, rq entity.StatsRequest) ([]entity.DelegationStats, error) {
+	var f filter
+	query := selectDailyStats
+	if rq.Bucket != entity.BucketDay {
+		f.params = []any{rq.Bucket}
+		query = selectDelegationStats
+	}
+	if !rq.From.IsZero() {
+		f.add("day >= ?::date", rq.From)
+	}
+	if !rq.To.IsZero() {
+		f.add("day < ?::date", rq.To)
+	}
+
+	rows, err := c.conn.Query(ctx, fmt.Sprintf(query, f.where()), f.params...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	var res []entity.DelegationStats
+	for rows.Next() {
+		var st entity.DelegationStats
+		if err = rows.Scan(&st.Bucket, &st.Count, &st.Total, &st.Delegators, &st.Median); err != nil {
+			return nil, err
+		}
+		res = append(res, st)
+	}
+
+	return res, rows.Err()
+}
+
+// refreshDailyStats computes again the rollup of the given days from the delegations stored within the transaction.
+// Days left without applied delegation are removed from it. The days are locked first, so that two transactions
+// refreshing the same day, e.g. the poller and a backfill, don't overwrite each other.
+func refreshDailyStats(ctx context.Context, tx pgx.Tx, days []time.Time) error {
+	if len(days) == 0 {
+		return nil
+	}
+
+	batch := &pgx.Batch{}
+	batch.Queue(lockDailyStats, days)
+	batch.Queue(upsertDailyStats, days)
+	batch.Queue(deleteDailyStats, days)
+	batch.Queue(deleteDailyDelegators, days)
+	batch.Queue(insertDailyDelegators, days)
+
+	return tx.SendBatch(ctx, batch).Close()
+}
+
+// RebuildDailyStats computes again the whole rollup from the stored delegations. Each day is computed and committed
+// on its own, so the poller is only held back on the day being computed. It returns the count of days computed.
+func (c *Client) RebuildDailyStats(ctx context.Context) (int64, error) {
+	rows, err := c.conn.Query(ctx, selectDelegationDays)
+	if err != nil {
+		return 0, err
+	}
+	days, err := pgx.CollectRows(rows, pgx.RowTo[time.Time])
+	if err != nil {
+		return 0, err
+	}
+
+	for _, day := range days {
+		if err = c.rebuildDailyStats(ctx, day); err != nil {
+			return 0, err
+		}
+	}
+
+	return int64(len(days)), nil
+}
+
+// rebuildDailyStats computes again the rollup of a day within its own transaction.
+func (c *Client) rebuildDailyStats(ctx context.Context, day time.Time) error {
+	tx, err := c.conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err = refreshDailyStats(ctx, tx, []time.Time{day}); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// scanDelegations reads every delegation returned by a select and closes the rows.
+func scanDelegations(rows pgx.Rows) ([]entity.Delegation, error) {
+	defer rows.Close()
+
 	var res []entity.Delegation
 	for rows.Next() {
-		var dg entity.Delegation
-		err = rows.Scan(&dg.TimeStamp, &dg.Amount, &dg.Delegator, &dg.Block, &dg.Id)
+		dg, err := scanDelegation(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -100,10 +512,132 @@ func (c *Client) SelectDelegations(ctx context.Context, dgr entity.DelegationReq
 	return res, rows.Err()
 }
 
-// SelectLastDelegation returns the timestamp of the last delegation entry in the database.
-func (c *Client) SelectLastDelegation(ctx context.Context) (time.Time, error) {
-	var lastUpdate time.Time
-	err := c.conn.QueryRow(ctx, selectLastDelegation).Scan(&lastUpdate)
+// scanDelegation reads the current row of delegationColumns.
+func scanDelegation(rows pgx.Rows) (entity.Delegation, error) {
+	var dg entity.Delegation
+	err := rows.Scan(&dg.TimeStamp, &dg.Amount, &dg.Delegator, &dg.Block, &dg.Id, &dg.Hash, &dg.Level,
+		&dg.Baker, &dg.PrevBaker, &dg.Status, &dg.BakerFee, &dg.GasUsed, &dg.Counter)
+	return dg, err
+}
+
+// SelectLastDelegationId returns the id of the last delegation entry in the database, 0 if there is none.
+func (c *Client) SelectLastDelegationId(ctx context.Context) (int64, error) {
+	var lastId int64
+	err := c.conn.QueryRow(ctx, selectLastDelegationId).Scan(&lastId)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	return lastId, nil
+}
+
+// SelectRecentDelegations returns the delegations of the last given count of levels up to the highest stored one,
+// sorted by id. Delegations stored before their level was recorded are left out.
+func (c *Client) SelectRecentDelegations(ctx context.Context, levels int) ([]entity.Delegation, error) {
+	rows, err := c.conn.Query(ctx, selectRecentDelegations, levels)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanDelegations(rows)
+}
+
+// SelectDelegatorHistory returns the delegations of a delegator from the oldest to the newest, it also handles pagination.
+func (c *Client) SelectDelegatorHistory(ctx context.Context, rq entity.DelegatorHistoryRequest) ([]entity.Delegation, error) {
+	rows, err := c.conn.Query(ctx, selectDelegatorHistory, rq.Delegator, rq.Limit, rq.Offset)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanDelegations(rows)
+}
+
+// SelectBakerDelegators returns the current delegators of a baker, sorted by amount unless sorted by date.
+// It also handles pagination.
+func (c *Client) SelectBakerDelegators(ctx context.Context, rq entity.BakerDelegatorsRequest) ([]entity.DelegatorState, error) {
+	order, ok := bakerDelegatorsOrder[rq.Sort]
+	if !ok {
+		order = bakerDelegatorsOrder[entity.SortAmount]
+	}
+
+	rows, err := c.conn.Query(ctx, fmt.Sprintf(selectBakerDelegators, order), rq.Baker, rq.Limit, rq.Offset)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanDelegatorStates(rows)
+}
+
+// SelectBakerSnapshot returns the delegators of a baker as of a past timestamp or level, largest amount first.
+// The state of each delegator is rebuilt from its last applied operation up to that point.
+func (c *Client) SelectBakerSnapshot(ctx context.Context, rq entity.BakerSnapshotRequest) ([]entity.DelegatorState, error) {
+	f := filter{params: []any{rq.Baker}}
+	f.add("COALESCE(status, 'applied') = 'applied'")
+	if !rq.At.IsZero() {
+		f.add("ts <= ?", rq.At)
+	}
+	if rq.Level > 0 {
+		f.add("level <= ?", rq.Level)
+	}
+
+	rows, err := c.conn.Query(ctx, fmt.Sprintf(selectBakerSnapshot, f.where()), f.params...)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanDelegatorStates(rows)
+}
+
+// scanDelegatorStates reads every delegator state returned by a select and closes the rows.
+func scanDelegatorStates(rows pgx.Rows) ([]entity.DelegatorState, error) {
+	defer rows.Close()
+
+	var res []entity.DelegatorState
+	for rows.Next() {
+		var st entity.DelegatorState
+		err := rows.Scan(&st.Delegator, &st.Baker, &st.Since, &st.SinceLevel, &st.LastId, &st.Amount)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, st)
+	}
+
+	return res, rows.Err()
+}
+
+// SelectBakerTotals returns the count of the current delegators of a baker and the sum of their amounts.
+func (c *Client) SelectBakerTotals(ctx context.Context, baker string) (int64, int64, error) {
+	var count, total int64
+	err := c.conn.QueryRow(ctx, selectBakerTotals, baker).Scan(&count, &total)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return count, total, nil
+}
+
+// SelectDelegatorState returns the current delegation of a delegator, entity.ErrNotFound if none is stored.
+func (c *Client) SelectDelegatorState(ctx context.Context, delegator string) (entity.DelegatorState, error) {
+	var st entity.DelegatorState
+	err := c.conn.QueryRow(ctx, selectDelegatorState, delegator).
+		Scan(&st.Delegator, &st.Baker, &st.Since, &st.SinceLevel, &st.LastId, &st.Amount)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.DelegatorState{}, entity.ErrNotFound
+		}
+		return entity.DelegatorState{}, err
+	}
+
+	return st, nil
+}
+
+// SelectCheckpoint returns how far the backfill of the given range went, a zero time if it never started.
+func (c *Client) SelectCheckpoint(ctx context.Context, from, to time.Time) (time.Time, error) {
+	var cursor time.Time
+	err := c.conn.QueryRow(ctx, selectCheckpoint, from, to).Scan(&cursor)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return time.Time{}, nil
@@ -111,7 +645,13 @@ func (c *Client) SelectLastDelegation(ctx context.Context) (time.Time, error) {
 		return time.Time{}, err
 	}
 
-	return lastUpdate, nil
+	return cursor, nil
+}
+
+// UpsertCheckpoint records that every delegation of the given range before cursor has been stored.
+func (c *Client) UpsertCheckpoint(ctx context.Context, from, to, cursor time.Time) error {
+	_, err := c.conn.Exec(ctx, upsertCheckpoint, from, to, cursor)
+	return err
 }
 
 func (c *Client) Close() {
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
@@ -23,9 +24,17 @@ func TestClient_Integration(t *testing.T) {
 	migrateDb(t, tc)
 
 	for name, fn := range map[string]func(t *testing.T, c *Client){
-		"testInsertDelegations":    testInsertDelegations,
-		"testSelectDelegations":    testSelectDelegations,
-		"testSelectLastDelegation": testSelectLastDelegation,
+		"testInsertDelegations":      testInsertDelegations,
+		"testSelectDelegations":      testSelectDelegations,
+		"testSelectLastDelegationId": testSelectLastDelegationId,
+		"testCheckpoint":             testCheckpoint,
+		"testRecentDelegations":      testRecentDelegations,
+		"testDelegatorState":         testDelegatorState,
+		"testDelegatorHistory":       testDelegatorHistory,
+		"testBakerDelegators":        testBakerDelegators,
+		"testBakerSnapshot":          testBakerSnapshot,
+		"testDelegationStats":        testDelegationStats,
+		"testWebhooks":               testWebhooks,
 	} {
 		t.Run(name, func(t *testing.T) {
 			fn(t, c)
@@ -55,8 +64,9 @@ func testInsertDelegations(t *testing.T, c *Client) {
 	}
 
 	t.Run("success", func(t *testing.T) {
-		err := c.InsertDelegations(ctx, dgs)
+		summary, err := c.InsertDelegations(ctx, dgs)
 		assert.NoError(t, err)
+		assert.Equal(t, entity.InsertSummary{Inserted: 2}, summary)
 
 		rows, err := c.conn.Query(ctx, "SELECT amount, block, id, ts, delegator FROM delegations ORDER by amount DESC")
 		assert.NoError(t, err)
@@ -71,6 +81,22 @@ func testInsertDelegations(t *testing.T, c *Client) {
 		assert.NoError(t, err)
 		assert.Equal(t, dgs, got)
 	})
+
+	t.Run("idempotent", func(t *testing.T) {
+		summary, err := c.InsertDelegations(ctx, dgs)
+		assert.NoError(t, err)
+		assert.Equal(t, entity.InsertSummary{Skipped: 2}, summary)
+
+		updated := dgs[0]
+		updated.Block = "block2bis"
+		summary, err = c.InsertDelegations(ctx, []entity.Delegation{updated, dgs[1]})
+		assert.NoError(t, err)
+		assert.Equal(t, entity.InsertSummary{Updated: 1, Skipped: 1}, summary)
+
+		var block string
+		require.NoError(t, c.conn.QueryRow(ctx, "SELECT block FROM delegations WHERE id = $1", updated.Id).Scan(&block))
+		assert.Equal(t, "block2bis", block)
+	})
 }
 
 func testSelectDelegations(t *testing.T, c *Client) {
@@ -83,6 +109,14 @@ func testSelectDelegations(t *testing.T, c *Client) {
 			Id:        5600,
 			Delegator: "dg4",
 			TimeStamp: tm.AddDate(2, 0, 0),
+			Hash:      "ooHash4",
+			Level:     4000004,
+			Baker:     "baker2",
+			PrevBaker: "baker1",
+			Status:    "applied",
+			BakerFee:  380,
+			GasUsed:   1000,
+			Counter:   71829,
 		},
 		{
 			Amount:    1000034,
@@ -90,6 +124,13 @@ func testSelectDelegations(t *testing.T, c *Client) {
 			Id:        3034,
 			Delegator: "dg1",
 			TimeStamp: tm.Add(2 * time.Minute),
+			Hash:      "ooHash1",
+			Level:     4000001,
+			PrevBaker: "baker1",
+			Status:    "failed",
+			BakerFee:  300,
+			GasUsed:   100,
+			Counter:   55,
 		},
 		{
 			Amount:    123400,
@@ -106,7 +147,8 @@ func testSelectDelegations(t *testing.T, c *Client) {
 			TimeStamp: tm,
 		},
 	}
-	require.NoError(t, c.InsertDelegations(ctx, dgs))
+	_, err := c.InsertDelegations(ctx, dgs)
+	require.NoError(t, err)
 
 	t.Run("success", func(t *testing.T) {
 		got, err := c.SelectDelegations(ctx, entity.DelegationRequest{Limit: 5, Offset: 0})
@@ -132,6 +174,116 @@ func testSelectDelegations(t *testing.T, c *Client) {
 		assert.Equal(t, dgs[1:2], got)
 	})
 
+	t.Run("success_with_sort", func(t *testing.T) {
+		got, err := c.SelectDelegations(ctx, entity.DelegationRequest{Limit: 5, Sort: entity.SortAmount})
+		assert.NoError(t, err)
+		assert.Equal(t, []entity.Delegation{dgs[1], dgs[2], dgs[3], dgs[0]}, got)
+
+		got, err = c.SelectDelegations(ctx, entity.DelegationRequest{Limit: 5, Sort: entity.SortId, Order: entity.OrderAsc})
+		assert.NoError(t, err)
+		assert.Equal(t, []entity.Delegation{dgs[1], dgs[0], dgs[2], dgs[3]}, got)
+
+		// Oldest first, after a cursor.
+		after := entity.DelegationCursor{TimeStamp: dgs[2].TimeStamp, Amount: dgs[2].Amount, Id: dgs[2].Id}
+		got, err = c.SelectDelegations(ctx, entity.DelegationRequest{Limit: 5, Order: entity.OrderAsc, After: after})
+		assert.NoError(t, err)
+		assert.Equal(t, []entity.Delegation{dgs[1], dgs[0]}, got)
+	})
+
+	t.Run("count", func(t *testing.T) {
+		count, err := c.CountDelegations(ctx, entity.DelegationRequest{Limit: 1, Offset: 1})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(4), count)
+
+		count, err = c.CountDelegations(ctx, entity.DelegationRequest{Status: entity.StatusApplied, LevelMin: 4000002})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), count)
+	})
+
+	t.Run("success_with_cursor", func(t *testing.T) {
+		after := entity.DelegationCursor{TimeStamp: dgs[1].TimeStamp, Id: dgs[1].Id}
+		got, err := c.SelectDelegations(ctx, entity.DelegationRequest{Limit: 5, After: after})
+		assert.NoError(t, err)
+		assert.Equal(t, dgs[2:], got)
+
+		// Delegations sharing the timestamp of the cursor are listed after it when their id is lower.
+		after = entity.DelegationCursor{TimeStamp: dgs[2].TimeStamp, Id: dgs[2].Id + 1}
+		got, err = c.SelectDelegations(ctx, entity.DelegationRequest{Limit: 1, After: after})
+		assert.NoError(t, err)
+		assert.Equal(t, dgs[2:3], got)
+	})
+
+	t.Run("success_with_kind_and_status", func(t *testing.T) {
+		// Delegations without kind match none.
+		got, err := c.SelectDelegations(ctx, entity.DelegationRequest{Limit: 5, Kind: entity.KindRedelegate})
+		assert.NoError(t, err)
+		assert.Equal(t, dgs[:1], got)
+
+		got, err = c.SelectDelegations(ctx, entity.DelegationRequest{Limit: 5, Kind: entity.KindFailed})
+		assert.NoError(t, err)
+		assert.Equal(t, dgs[1:2], got)
+
+		got, err = c.SelectDelegations(ctx, entity.DelegationRequest{Limit: 5, Status: entity.StatusFailed})
+		assert.NoError(t, err)
+		assert.Equal(t, dgs[1:2], got)
+
+		// Delegations without status are considered applied.
+		got, err = c.SelectDelegations(ctx, entity.DelegationRequest{Limit: 5, Status: entity.StatusApplied})
+		assert.NoError(t, err)
+		assert.Equal(t, []entity.Delegation{dgs[0], dgs[2], dgs[3]}, got)
+	})
+
+	t.Run("success_with_filters", func(t *testing.T) {
+		got, err := c.SelectDelegations(ctx, entity.DelegationRequest{Limit: 5, Delegator: "dg2"})
+		assert.NoError(t, err)
+		assert.Equal(t, dgs[2:3], got)
+
+		got, err = c.SelectDelegations(ctx, entity.DelegationRequest{Limit: 5, Baker: "baker2", Block: "block4"})
+		assert.NoError(t, err)
+		assert.Equal(t, dgs[:1], got)
+
+		got, err = c.SelectDelegations(ctx, entity.DelegationRequest{Limit: 5, LevelMin: 4000001, LevelMax: 4000003})
+		assert.NoError(t, err)
+		assert.Equal(t, dgs[1:2], got)
+
+		amountMin, amountMax := int64(100004), int64(123400)
+		got, err = c.SelectDelegations(ctx, entity.DelegationRequest{Limit: 5, AmountMin: &amountMin, AmountMax: &amountMax})
+		assert.NoError(t, err)
+		assert.Equal(t, dgs[2:], got)
+
+		got, err = c.SelectDelegations(ctx, entity.DelegationRequest{Limit: 5, From: tm.Add(time.Minute), To: tm.Add(2 * time.Minute)})
+		assert.NoError(t, err)
+		assert.Equal(t, dgs[2:3], got)
+	})
+
+	t.Run("export", func(t *testing.T) {
+		var got []entity.Delegation
+		onRow := func(dg entity.Delegation) error {
+			got = append(got, dg)
+			return nil
+		}
+
+		// Pagination is ignored, every matching delegation is exported.
+		err := c.ExportDelegations(ctx, entity.DelegationRequest{Limit: 1, Offset: 1, After: entity.DelegationCursor{Id: 1}}, onRow)
+		assert.NoError(t, err)
+		assert.Equal(t, dgs, got)
+
+		got = nil
+		err = c.ExportDelegations(ctx, entity.DelegationRequest{Status: entity.StatusApplied, Sort: entity.SortAmount, Order: entity.OrderAsc}, onRow)
+		assert.NoError(t, err)
+		assert.Equal(t, []entity.Delegation{dgs[0], dgs[3], dgs[2]}, got)
+
+		// An error of onRow stops the export.
+		rowErr := errors.New("err")
+		calls := 0
+		err = c.ExportDelegations(ctx, entity.DelegationRequest{}, func(dg entity.Delegation) error {
+			calls++
+			return rowErr
+		})
+		assert.ErrorIs(t, err, rowErr)
+		assert.Equal(t, 1, calls)
+	})
+
 	t.Run("no_rows", func(t *testing.T) {
 		clearTable(ctx, t, c.conn)
 		got, err := c.SelectDelegations(ctx, entity.DelegationRequest{Limit: 5, Offset: 0})
@@ -140,7 +292,7 @@ func testSelectDelegations(t *testing.T, c *Client) {
 	})
 }
 
-func testSelectLastDelegation(t *testing.T, c *Client) {
+func testSelectLastDelegationId(t *testing.T, c *Client) {
 	ctx := context.Background()
 	tm := time.Now().UTC().Truncate(time.Millisecond)
 	dgs := []entity.Delegation{
@@ -166,17 +318,602 @@ func testSelectLastDelegation(t *testing.T, c *Client) {
 			TimeStamp: tm,
 		},
 	}
-	require.NoError(t, c.InsertDelegations(ctx, dgs))
+	_, err := c.InsertDelegations(ctx, dgs)
+	require.NoError(t, err)
 
 	t.Run("success", func(t *testing.T) {
-		got, err := c.SelectLastDelegation(ctx)
+		got, err := c.SelectLastDelegationId(ctx)
 		assert.NoError(t, err)
-		assert.Equal(t, tm.Add(3*time.Minute), got)
+		assert.Equal(t, int64(300890), got)
 	})
 	t.Run("no_rows", func(t *testing.T) {
 		clearTable(ctx, t, c.conn)
-		got, err := c.SelectLastDelegation(ctx)
+		got, err := c.SelectLastDelegationId(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(0), got)
+	})
+}
+
+func testCheckpoint(t *testing.T, c *Client) {
+	ctx := context.Background()
+	from := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2022, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("no_checkpoint", func(t *testing.T) {
+		got, err := c.SelectCheckpoint(ctx, from, to)
+		assert.NoError(t, err)
+		assert.Equal(t, time.Time{}, got)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		require.NoError(t, c.UpsertCheckpoint(ctx, from, to, from.AddDate(0, 0, 1)))
+		require.NoError(t, c.UpsertCheckpoint(ctx, from, to, from.AddDate(0, 0, 2)))
+
+		got, err := c.SelectCheckpoint(ctx, from, to)
+		assert.NoError(t, err)
+		assert.Equal(t, from.AddDate(0, 0, 2), got)
+
+		got, err = c.SelectCheckpoint(ctx, from, to.AddDate(0, 1, 0))
 		assert.NoError(t, err)
 		assert.Equal(t, time.Time{}, got)
 	})
 }
+
+func testRecentDelegations(t *testing.T, c *Client) {
+	ctx := context.Background()
+	tm := time.Now().UTC().Truncate(time.Millisecond)
+	dgs := []entity.Delegation{
+		{
+			Amount:    100004,
+			Block:     "block1",
+			Id:        3001,
+			Delegator: "dg1",
+			TimeStamp: tm,
+			Level:     4000001,
+		},
+		// Level 4000002 is without delegation.
+		{
+			Amount:    1000034,
+			Block:     "block3",
+			Id:        3002,
+			Delegator: "dg2",
+			TimeStamp: tm.Add(time.Minute),
+			Level:     4000003,
+		},
+		{
+			Amount:    123400,
+			Block:     "block4",
+			Id:        3003,
+			Delegator: "dg3",
+			TimeStamp: tm.Add(2 * time.Minute),
+			Level:     4000004,
+		},
+		{
+			Amount:    425,
+			Block:     "block4",
+			Id:        3004,
+			Delegator: "dg4",
+			TimeStamp: tm.Add(2 * time.Minute),
+			Level:     4000004,
+		},
+	}
+	// Stored before levels were recorded.
+	legacy := entity.Delegation{Amount: 10, Block: "block0", Id: 2999, Delegator: "dg5", TimeStamp: tm}
+	_, err := c.InsertDelegations(ctx, append([]entity.Delegation{legacy}, dgs...))
+	require.NoError(t, err)
+
+	t.Run("select_recent", func(t *testing.T) {
+		got, err := c.SelectRecentDelegations(ctx, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, dgs[1:], got)
+
+		got, err = c.SelectRecentDelegations(ctx, 3)
+		assert.NoError(t, err)
+		assert.Equal(t, dgs[1:], got)
+	})
+
+	t.Run("replace", func(t *testing.T) {
+		reorged := dgs[3]
+		reorged.Block = "block4bis"
+
+		deleted, summary, err := c.ReplaceDelegations(ctx, []int64{3003, 3004, 9999}, []entity.Delegation{reorged})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), deleted)
+		assert.Equal(t, entity.InsertSummary{Inserted: 1}, summary)
+
+		got, err := c.SelectRecentDelegations(ctx, 5)
+		assert.NoError(t, err)
+		assert.Equal(t, []entity.Delegation{dgs[0], dgs[1], reorged}, got)
+
+		_, err = c.SelectDelegatorState(ctx, "dg3")
+		assert.ErrorIs(t, err, entity.ErrNotFound)
+	})
+}
+
+func testDelegatorState(t *testing.T, c *Client) {
+	ctx := context.Background()
+	tm := time.Now().UTC().Truncate(time.Millisecond)
+	delegate := entity.Delegation{
+		Amount:    1000,
+		Block:     "block1",
+		Id:        3001,
+		Delegator: "dg1",
+		TimeStamp: tm,
+		Level:     4000001,
+		Baker:     "baker1",
+		Status:    entity.StatusApplied,
+	}
+	redelegate := entity.Delegation{
+		Amount:    2000,
+		Block:     "block2",
+		Id:        3002,
+		Delegator: "dg1",
+		TimeStamp: tm.Add(time.Minute),
+		Level:     4000002,
+		Baker:     "baker2",
+		PrevBaker: "baker1",
+		Status:    entity.StatusApplied,
+	}
+	failed := entity.Delegation{
+		Amount:    3000,
+		Block:     "block3",
+		Id:        3003,
+		Delegator: "dg1",
+		TimeStamp: tm.Add(2 * time.Minute),
+		Level:     4000003,
+		Baker:     "baker3",
+		PrevBaker: "baker2",
+		Status:    entity.StatusFailed,
+	}
+
+	t.Run("not_found", func(t *testing.T) {
+		_, err := c.SelectDelegatorState(ctx, "dg1")
+		assert.ErrorIs(t, err, entity.ErrNotFound)
+	})
+
+	t.Run("last_applied_operation", func(t *testing.T) {
+		_, err := c.InsertDelegations(ctx, []entity.Delegation{redelegate, failed})
+		require.NoError(t, err)
+		// An older operation stored afterwards, e.g. by a backfill, leaves the state unchanged.
+		_, err = c.InsertDelegations(ctx, []entity.Delegation{delegate})
+		require.NoError(t, err)
+
+		got, err := c.SelectDelegatorState(ctx, "dg1")
+		assert.NoError(t, err)
+		assert.Equal(t, entity.DelegatorState{
+			Delegator:  "dg1",
+			Baker:      "baker2",
+			Since:      redelegate.TimeStamp,
+			SinceLevel: 4000002,
+			LastId:     3002,
+			Amount:     2000,
+		}, got)
+	})
+
+	t.Run("rebuilt_on_delete", func(t *testing.T) {
+		deleted, _, err := c.ReplaceDelegations(ctx, []int64{3002}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), deleted)
+
+		got, err := c.SelectDelegatorState(ctx, "dg1")
+		assert.NoError(t, err)
+		assert.Equal(t, entity.DelegatorState{
+			Delegator:  "dg1",
+			Baker:      "baker1",
+			Since:      delegate.TimeStamp,
+			SinceLevel: 4000001,
+			LastId:     3001,
+			Amount:     1000,
+		}, got)
+	})
+}
+
+func testDelegatorHistory(t *testing.T, c *Client) {
+	ctx := context.Background()
+	tm := time.Now().UTC().Truncate(time.Millisecond)
+	dgs := []entity.Delegation{
+		{
+			Amount:    1000,
+			Block:     "block1",
+			Id:        3001,
+			Delegator: "dg1",
+			TimeStamp: tm,
+		},
+		{
+			Amount:    2000,
+			Block:     "block2",
+			Id:        3002,
+			Delegator: "dg2",
+			TimeStamp: tm.Add(time.Minute),
+		},
+		{
+			Amount:    3000,
+			Block:     "block3",
+			Id:        3003,
+			Delegator: "dg1",
+			TimeStamp: tm.Add(2 * time.Minute),
+		},
+	}
+	_, err := c.InsertDelegations(ctx, dgs)
+	require.NoError(t, err)
+
+	t.Run("oldest_first", func(t *testing.T) {
+		got, err := c.SelectDelegatorHistory(ctx, entity.DelegatorHistoryRequest{Delegator: "dg1", Limit: 5})
+		assert.NoError(t, err)
+		assert.Equal(t, []entity.Delegation{dgs[0], dgs[2]}, got)
+	})
+
+	t.Run("success_with_paging", func(t *testing.T) {
+		got, err := c.SelectDelegatorHistory(ctx, entity.DelegatorHistoryRequest{Delegator: "dg1", Limit: 1, Offset: 1})
+		assert.NoError(t, err)
+		assert.Equal(t, dgs[2:], got)
+	})
+
+	t.Run("unknown_delegator", func(t *testing.T) {
+		got, err := c.SelectDelegatorHistory(ctx, entity.DelegatorHistoryRequest{Delegator: "dg3", Limit: 5})
+		assert.NoError(t, err)
+		assert.Empty(t, got)
+	})
+}
+
+func testBakerDelegators(t *testing.T, c *Client) {
+	ctx := context.Background()
+	tm := time.Now().UTC().Truncate(time.Millisecond)
+	dgs := []entity.Delegation{
+		{
+			Amount:    1000,
+			Block:     "block1",
+			Id:        3001,
+			Delegator: "dg1",
+			TimeStamp: tm,
+			Level:     4000001,
+			Baker:     "baker1",
+			Status:    entity.StatusApplied,
+		},
+		{
+			Amount:    3000,
+			Block:     "block2",
+			Id:        3002,
+			Delegator: "dg2",
+			TimeStamp: tm.Add(time.Minute),
+			Level:     4000002,
+			Baker:     "baker1",
+			Status:    entity.StatusApplied,
+		},
+		{
+			Amount:    2000,
+			Block:     "block3",
+			Id:        3003,
+			Delegator: "dg3",
+			TimeStamp: tm.Add(2 * time.Minute),
+			Level:     4000003,
+			Baker:     "baker2",
+			Status:    entity.StatusApplied,
+		},
+	}
+	_, err := c.InsertDelegations(ctx, dgs)
+	require.NoError(t, err)
+
+	states := []entity.DelegatorState{
+		{Delegator: "dg1", Baker: "baker1", Since: dgs[0].TimeStamp, SinceLevel: 4000001, LastId: 3001, Amount: 1000},
+		{Delegator: "dg2", Baker: "baker1", Since: dgs[1].TimeStamp, SinceLevel: 4000002, LastId: 3002, Amount: 3000},
+	}
+
+	t.Run("sorted_by_amount", func(t *testing.T) {
+		got, err := c.SelectBakerDelegators(ctx, entity.BakerDelegatorsRequest{Baker: "baker1", Limit: 5, Sort: entity.SortAmount})
+		assert.NoError(t, err)
+		assert.Equal(t, []entity.DelegatorState{states[1], states[0]}, got)
+	})
+
+	t.Run("sorted_by_date_with_paging", func(t *testing.T) {
+		got, err := c.SelectBakerDelegators(ctx, entity.BakerDelegatorsRequest{Baker: "baker1", Limit: 1, Offset: 1, Sort: entity.SortDate})
+		assert.NoError(t, err)
+		assert.Equal(t, states[:1], got)
+	})
+
+	t.Run("totals", func(t *testing.T) {
+		count, total, err := c.SelectBakerTotals(ctx, "baker1")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), count)
+		assert.Equal(t, int64(4000), total)
+	})
+
+	t.Run("unknown_baker", func(t *testing.T) {
+		got, err := c.SelectBakerDelegators(ctx, entity.BakerDelegatorsRequest{Baker: "baker3", Limit: 5})
+		assert.NoError(t, err)
+		assert.Empty(t, got)
+
+		count, total, err := c.SelectBakerTotals(ctx, "baker3")
+		assert.NoError(t, err)
+		assert.Zero(t, count)
+		assert.Zero(t, total)
+	})
+}
+
+func testBakerSnapshot(t *testing.T, c *Client) {
+	ctx := context.Background()
+	tm := time.Now().UTC().Truncate(time.Millisecond)
+	dgs := []entity.Delegation{
+		{
+			Amount:    1000,
+			Block:     "block1",
+			Id:        3001,
+			Delegator: "dg1",
+			TimeStamp: tm,
+			Level:     4000001,
+			Baker:     "baker1",
+			Status:    entity.StatusApplied,
+		},
+		{
+			Amount:    3000,
+			Block:     "block1",
+			Id:        3002,
+			Delegator: "dg2",
+			TimeStamp: tm,
+			Level:     4000001,
+			Baker:     "baker1",
+			Status:    entity.StatusApplied,
+		},
+		{
+			Amount:    1500,
+			Block:     "block2",
+			Id:        3003,
+			Delegator: "dg1",
+			TimeStamp: tm.Add(time.Minute),
+			Level:     4000002,
+			Baker:     "baker2",
+			PrevBaker: "baker1",
+			Status:    entity.StatusApplied,
+		},
+		{
+			Amount:    3500,
+			Block:     "block3",
+			Id:        3004,
+			Delegator: "dg2",
+			TimeStamp: tm.Add(2 * time.Minute),
+			Level:     4000003,
+			PrevBaker: "baker1",
+			Status:    entity.StatusFailed,
+		},
+	}
+	_, err := c.InsertDelegations(ctx, dgs)
+	require.NoError(t, err)
+
+	dg1 := entity.DelegatorState{Delegator: "dg1", Baker: "baker1", Since: tm, SinceLevel: 4000001, LastId: 3001, Amount: 1000}
+	dg2 := entity.DelegatorState{Delegator: "dg2", Baker: "baker1", Since: tm, SinceLevel: 4000001, LastId: 3002, Amount: 3000}
+
+	t.Run("at_level", func(t *testing.T) {
+		got, err := c.SelectBakerSnapshot(ctx, entity.BakerSnapshotRequest{Baker: "baker1", Level: 4000001})
+		assert.NoError(t, err)
+		assert.Equal(t, []entity.DelegatorState{dg2, dg1}, got)
+	})
+
+	t.Run("at_timestamp", func(t *testing.T) {
+		// dg1 moved to baker2 while the failed operation of dg2 left its delegation unchanged.
+		got, err := c.SelectBakerSnapshot(ctx, entity.BakerSnapshotRequest{Baker: "baker1", At: tm.Add(2 * time.Minute)})
+		assert.NoError(t, err)
+		assert.Equal(t, []entity.DelegatorState{dg2}, got)
+	})
+
+	t.Run("before_history", func(t *testing.T) {
+		got, err := c.SelectBakerSnapshot(ctx, entity.BakerSnapshotRequest{Baker: "baker1", At: tm.Add(-time.Minute)})
+		assert.NoError(t, err)
+		assert.Empty(t, got)
+	})
+}
+
+func testDelegationStats(t *testing.T, c *Client) {
+	ctx := context.Background()
+	day := time.Date(2023, 9, 16, 0, 0, 0, 0, time.UTC)
+	dgs := []entity.Delegation{
+		{Amount: 1000, Block: "block1", Id: 3001, Delegator: "dg1", TimeStamp: day.Add(10 * time.Hour), Status: entity.StatusApplied},
+		{Amount: 3000, Block: "block2", Id: 3002, Delegator: "dg1", TimeStamp: day.Add(11 * time.Hour), Status: entity.StatusApplied},
+		{Amount: 2000, Block: "block3", Id: 3003, Delegator: "dg2", TimeStamp: day.Add(12 * time.Hour), Status: entity.StatusApplied},
+		{Amount: 500, Block: "block4", Id: 3004, Delegator: "dg3", TimeStamp: day.AddDate(0, 0, 1), Status: entity.StatusFailed},
+		// Delegations without status are considered applied.
+		{Amount: 1500, Block: "block5", Id: 3005, Delegator: "dg3", TimeStamp: day.AddDate(0, 0, 2)},
+	}
+	// The rollup is refreshed along with the delegations.
+	_, err := c.InsertDelegations(ctx, dgs)
+	require.NoError(t, err)
+
+	t.Run("by_day", func(t *testing.T) {
+		got, err := c.SelectDelegationStats(ctx, entity.StatsRequest{Bucket: entity.BucketDay})
+		assert.NoError(t, err)
+		assert.Equal(t, []entity.DelegationStats{
+			{Bucket: day, Count: 3, Delegators: 2, Total: 6000, Median: 2000},
+			{Bucket: day.AddDate(0, 0, 2), Count: 1, Delegators: 1, Total: 1500, Median: 1500},
+		}, got)
+	})
+
+	t.Run("by_month", func(t *testing.T) {
+		got, err := c.SelectDelegationStats(ctx, entity.StatsRequest{Bucket: entity.BucketMonth})
+		assert.NoError(t, err)
+		assert.Equal(t, []entity.DelegationStats{
+			{Bucket: time.Date(2023, 9, 1, 0, 0, 0, 0, time.UTC), Count: 4, Delegators: 3, Total: 7500, Median: 1750},
+		}, got)
+	})
+
+	t.Run("time_range", func(t *testing.T) {
+		got, err := c.SelectDelegationStats(ctx, entity.StatsRequest{
+			Bucket: entity.BucketWeek,
+			From:   day,
+			To:     day.AddDate(0, 0, 2),
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []entity.DelegationStats{
+			{Bucket: time.Date(2023, 9, 11, 0, 0, 0, 0, time.UTC), Count: 3, Delegators: 2, Total: 6000, Median: 2000},
+		}, got)
+	})
+
+	t.Run("refreshed_after_delete", func(t *testing.T) {
+		_, _, err := c.ReplaceDelegations(ctx, []int64{3002, 3005}, nil)
+		require.NoError(t, err)
+
+		got, err := c.SelectDelegationStats(ctx, entity.StatsRequest{Bucket: entity.BucketDay})
+		assert.NoError(t, err)
+		assert.Equal(t, []entity.DelegationStats{
+			{Bucket: day, Count: 2, Delegators: 2, Total: 3000, Median: 1500},
+		}, got)
+	})
+
+	t.Run("rebuild", func(t *testing.T) {
+		_, err := c.conn.Exec(ctx, "DELETE FROM delegations_daily")
+		require.NoError(t, err)
+		// A day without delegation is removed.
+		_, err = c.conn.Exec(ctx, "INSERT INTO delegations_daily (day, count, total, delegators, median) VALUES ('2023-01-01', 1, 10, 1, 10)")
+		require.NoError(t, err)
+
+		days, err := c.RebuildDailyStats(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), days)
+
+		got, err := c.SelectDelegationStats(ctx, entity.StatsRequest{Bucket: entity.BucketYear})
+		assert.NoError(t, err)
+		assert.Equal(t, []entity.DelegationStats{
+			{Bucket: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), Count: 2, Delegators: 2, Total: 3000, Median: 1500},
+		}, got)
+	})
+}
+
+func testWebhooks(t *testing.T, c *Client) {
+	ctx := context.Background()
+	tm := time.Now().UTC().Truncate(time.Millisecond)
+	// Stored before the subscription, it is not notified.
+	_, err := c.InsertDelegations(ctx, []entity.Delegation{
+		{Amount: 10, Block: "block0", Id: 3000, Delegator: "dg0", TimeStamp: tm, Level: 4000000, Baker: "baker1", Status: "applied"},
+	})
+	require.NoError(t, err)
+
+	sub, err := c.InsertSubscription(ctx, entity.Subscription{
+		Url:       "https://example.com/hook",
+		Secret:    "secret",
+		Addresses: []string{"baker1", "baker2"},
+		Events:    []string{entity.EventJoined},
+	})
+	require.NoError(t, err)
+	assert.NotZero(t, sub.Id)
+	assert.False(t, sub.CreatedAt.IsZero())
+	assert.Equal(t, int64(3000), sub.AfterId)
+
+	t.Run("subscriptions", func(t *testing.T) {
+		got, err := c.SelectSubscriptions(ctx)
+		assert.NoError(t, err)
+		if assert.Len(t, got, 1) {
+			assert.Equal(t, sub.Id, got[0].Id)
+			assert.Equal(t, sub.Addresses, got[0].Addresses)
+			assert.Equal(t, sub.Events, got[0].Events)
+			assert.Equal(t, "secret", got[0].Secret)
+		}
+	})
+
+	t.Run("events", func(t *testing.T) {
+		dgs := []entity.Delegation{
+			{Amount: 100, Block: "block1", Id: 3033, Delegator: "dg1", TimeStamp: tm, Level: 4000001, Baker: "baker1", Status: "applied"},
+			{Amount: 200, Block: "block1", Id: 3034, Delegator: "dg2", TimeStamp: tm, Level: 4000001, Baker: "baker2", PrevBaker: "baker1", Status: "applied"},
+			{Amount: 300, Block: "block1", Id: 3035, Delegator: "dg3", TimeStamp: tm, Level: 4000001, Baker: "baker3", Status: "applied"},
+		}
+		_, err := c.InsertDelegations(ctx, dgs)
+		require.NoError(t, err)
+
+		got, err := c.SelectPendingEvents(ctx, sub.Id, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, []entity.WebhookEvent{
+			{Kind: entity.EventJoined, Baker: "baker1", Delegation: dgs[0]},
+			{Kind: entity.EventJoined, Baker: "baker2", Delegation: dgs[1]},
+		}, got)
+
+		require.NoError(t, c.InsertDelivery(ctx, entity.Delivery{
+			SubscriptionId: sub.Id, OperationId: 3033, Event: entity.EventJoined, Attempt: 1, StatusCode: 200,
+		}, entity.StateDelivered))
+		require.NoError(t, c.InsertDelivery(ctx, entity.Delivery{
+			SubscriptionId: sub.Id, OperationId: 3034, Event: entity.EventJoined, Attempt: 1, Error: "connection refused",
+		}, entity.StatePending))
+		got, err = c.SelectPendingEvents(ctx, sub.Id, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, []entity.WebhookEvent{{Kind: entity.EventJoined, Baker: "baker2", Delegation: dgs[1], Attempts: 1}}, got)
+
+		// Stored again in another block: the delivered event is not notified again, the pending one is written again.
+		moved := []entity.Delegation{dgs[0], dgs[1]}
+		moved[0].Block, moved[1].Block = "block1bis", "block1bis"
+		_, _, err = c.ReplaceDelegations(ctx, []int64{3033, 3034}, moved)
+		require.NoError(t, err)
+		got, err = c.SelectPendingEvents(ctx, sub.Id, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, []entity.WebhookEvent{{Kind: entity.EventJoined, Baker: "baker2", Delegation: moved[1]}}, got)
+
+		got, err = c.SelectPendingEvents(ctx, sub.Id+1, 10)
+		assert.NoError(t, err)
+		assert.Empty(t, got)
+	})
+
+	t.Run("deliveries", func(t *testing.T) {
+		require.NoError(t, c.InsertDelivery(ctx, entity.Delivery{
+			SubscriptionId: sub.Id, OperationId: 3034, Event: entity.EventJoined, Attempt: 2, Error: "connection refused",
+		}, entity.StatePending))
+		require.NoError(t, c.InsertDelivery(ctx, entity.Delivery{
+			SubscriptionId: sub.Id, OperationId: 3034, Event: entity.EventJoined, Attempt: 3, StatusCode: 200,
+		}, entity.StateDelivered))
+
+		got, err := c.SelectDeliveries(ctx, sub.Id, 10, 0)
+		assert.NoError(t, err)
+		if assert.Len(t, got, 4) {
+			assert.Equal(t, 3, got[0].Attempt)
+			assert.Equal(t, 200, got[0].StatusCode)
+			assert.Empty(t, got[0].Error)
+			assert.Equal(t, 0, got[1].StatusCode)
+			assert.Equal(t, "connection refused", got[1].Error)
+		}
+
+		got, err = c.SelectDeliveries(ctx, sub.Id, 1, 1)
+		assert.NoError(t, err)
+		if assert.Len(t, got, 1) {
+			assert.Equal(t, 2, got[0].Attempt)
+		}
+
+		events, err := c.SelectPendingEvents(ctx, sub.Id, 10)
+		assert.NoError(t, err)
+		assert.Empty(t, events)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		_, err := c.conn.Exec(ctx, "UPDATE webhook_events SET created_at = now() - interval '2 hours'")
+		require.NoError(t, err)
+		_, err = c.conn.Exec(ctx, "UPDATE webhook_deliveries SET created_at = now() - interval '2 hours' WHERE attempt < 3")
+		require.NoError(t, err)
+
+		events, deliveries, err := c.DeleteExpiredEvents(ctx, time.Hour)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), events)
+		assert.Equal(t, int64(3), deliveries)
+		got, err := c.SelectDeliveries(ctx, sub.Id, 10, 0)
+		assert.NoError(t, err)
+		assert.Len(t, got, 1)
+
+		// Stored again unchanged, the delegation is not notified again.
+		dgs, err := c.SelectDelegations(ctx, entity.DelegationRequest{Limit: 1, Delegator: "dg1"})
+		require.NoError(t, err)
+		_, err = c.InsertDelegations(ctx, dgs)
+		require.NoError(t, err)
+		pending, err := c.SelectPendingEvents(ctx, sub.Id, 10)
+		assert.NoError(t, err)
+		assert.Empty(t, pending)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		assert.NoError(t, c.DeleteSubscription(ctx, sub.Id))
+		assert.ErrorIs(t, c.DeleteSubscription(ctx, sub.Id), entity.ErrNotFound)
+
+		got, err := c.SelectSubscriptions(ctx)
+		assert.NoError(t, err)
+		assert.Empty(t, got)
+
+		// The events and deliveries are deleted along with their subscription.
+		dlvs, err := c.SelectDeliveries(ctx, sub.Id, 10, 0)
+		assert.NoError(t, err)
+		assert.Empty(t, dlvs)
+		var events int
+		require.NoError(t, c.conn.QueryRow(ctx, "SELECT COUNT(*) FROM webhook_events").Scan(&events))
+		assert.Zero(t, events)
+	})
+}
@@ -64,8 +64,8 @@ func migrateDb(t *testing.T, tc *testContainer) {
 	require.NoError(t, err)
 }
 
-// clears all data from table.
+// clears all data from tables.
 func clearTable(ctx context.Context, t *testing.T, conn *pgxpool.Pool) {
-	_, err := conn.Exec(ctx, "TRUNCATE delegations")
+	_, err := conn.Exec(ctx, "TRUNCATE delegations, backfill_checkpoints, delegator_state, delegations_daily, webhook_subscriptions CASCADE")
 	require.NoError(t, err)
 }
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/frisk038/tezos-delegation-service/domain/entity"
+)
+
+// filter accumulates the conditions of a where clause along with their parameters.
+type filter struct {
+	conds  []string
+	params []any
+}
+
+// add appends a condition whose placeholders are written ? and numbered after the parameters already added.
+func (f *filter) add(cond string, args ...any) {
+	for _, arg := range args {
+		f.params = append(f.params, arg)
+		cond = strings.Replace(cond, "?", "$"+strconv.Itoa(len(f.params)), 1)
+	}
+	f.conds = append(f.conds, cond)
+}
+
+// where returns the where clause matching every condition, empty when there is none.
+func (f *filter) where() string {
+	if len(f.conds) == 0 {
+		return ""
+	}
+
+	return "WHERE " + strings.Join(f.conds, " AND ")
+}
+
+// delegationsSort maps the sorts of the delegations onto their column.
+var delegationsSort = map[string]string{
+	entity.SortTimestamp: "ts",
+	entity.SortAmount:    "amount",
+	entity.SortId:        "id",
+}
+
+// sorted returns the order by clause of the delegations, ties being broken by id, and adds the condition keeping
+// the ones listed after the cursor of the request. An unknown sort or order falls back to the timestamp, descending.
+func (f *filter) sorted(dgr entity.DelegationRequest) string {
+	col, ok := delegationsSort[dgr.Sort]
+	if !ok {
+		col = "ts"
+	}
+	dir, op := "DESC", "<"
+	if dgr.Order == entity.OrderAsc {
+		dir, op = "ASC", ">"
+	}
+
+	if dgr.After.Id != 0 {
+		var value any
+		switch col {
+		case "ts":
+			value = dgr.After.TimeStamp
+		case "amount":
+			value = dgr.After.Amount
+		}
+		if value == nil {
+			f.add("id "+op+" ?", dgr.After.Id)
+		} else {
+			// Written so that the index on the sorted column bounds the scan.
+			f.add(fmt.Sprintf("%[1]s %[2]s= ? AND (%[1]s %[2]s ? OR id %[2]s ?)", col, op), value, value, dgr.After.Id)
+		}
+	}
+
+	if col == "id" {
+		return "id " + dir
+	}
+	return col + " " + dir + ", id " + dir
+}
+
+// delegations adds the conditions matching the filters of a delegation request, the ones left to their zero value are ignored.
+func (f *filter) delegations(dgr entity.DelegationRequest) {
+	if !dgr.Date.IsZero() {
+		f.add("ts >= ? AND ts < ?", dgr.Date, dgr.Date.AddDate(1, 0, 0))
+	}
+	if dgr.Kind != "" {
+		// Delegations stored before their details were recorded have no kind, they match none.
+		f.add("kind = ?", dgr.Kind)
+	}
+	if dgr.Status != "" {
+		f.add("COALESCE(status, 'applied') = ?", dgr.Status)
+	}
+	if dgr.Delegator != "" {
+		f.add("delegator = ?", dgr.Delegator)
+	}
+	if dgr.Baker != "" {
+		f.add("baker = ?", dgr.Baker)
+	}
+	if dgr.Block != "" {
+		f.add("block = ?", dgr.Block)
+	}
+	if dgr.LevelMin > 0 {
+		f.add("level >= ?", dgr.LevelMin)
+	}
+	if dgr.LevelMax > 0 {
+		f.add("level <= ?", dgr.LevelMax)
+	}
+	if dgr.AmountMin != nil {
+		f.add("amount >= ?", *dgr.AmountMin)
+	}
+	if dgr.AmountMax != nil {
+		f.add("amount <= ?", *dgr.AmountMax)
+	}
+	if !dgr.From.IsZero() {
+		f.add("ts >= ?", dgr.From)
+	}
+	if !dgr.To.IsZero() {
+		f.add("ts < ?", dgr.To)
+	}
+}
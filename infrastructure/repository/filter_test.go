@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/frisk038/tezos-delegation-service/domain/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilter(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		f := filter{params: []any{10, 0}}
+		assert.Equal(t, "", f.where())
+		assert.Equal(t, []any{10, 0}, f.params)
+	})
+
+	t.Run("numbered_after_params", func(t *testing.T) {
+		f := filter{params: []any{10, 0}}
+		f.add("ts >= ? AND ts < ?", "from", "to")
+		f.add("kind = ?", "delegate")
+		assert.Equal(t, "WHERE ts >= $3 AND ts < $4 AND kind = $5", f.where())
+		assert.Equal(t, []any{10, 0, "from", "to", "delegate"}, f.params)
+	})
+
+	t.Run("delegations", func(t *testing.T) {
+		from := time.Date(2023, 9, 1, 0, 0, 0, 0, time.UTC)
+		to := from.AddDate(0, 1, 0)
+		amountMin, amountMax := int64(0), int64(5000)
+		f := filter{params: []any{10, 0}}
+		f.delegations(entity.DelegationRequest{
+			Status:    entity.StatusApplied,
+			Delegator: "tz1Sender1",
+			Baker:     "tz1Baker1",
+			LevelMin:  4000000,
+			LevelMax:  4000100,
+			AmountMin: &amountMin,
+			AmountMax: &amountMax,
+			From:      from,
+			To:        to,
+		})
+		assert.Equal(t, "WHERE COALESCE(status, 'applied') = $3 AND delegator = $4 AND baker = $5 AND level >= $6 "+
+			"AND level <= $7 AND amount >= $8 AND amount <= $9 AND ts >= $10 AND ts < $11", f.where())
+		assert.Equal(t, []any{10, 0, entity.StatusApplied, "tz1Sender1", "tz1Baker1", int64(4000000), int64(4000100),
+			int64(0), int64(5000), from, to}, f.params)
+	})
+
+	t.Run("delegations_kind", func(t *testing.T) {
+		f := filter{params: []any{10, 0}}
+		f.delegations(entity.DelegationRequest{Kind: entity.KindDelegate, Status: entity.StatusApplied})
+		assert.Equal(t, "WHERE kind = $3 AND COALESCE(status, 'applied') = $4", f.where())
+
+		f = filter{params: []any{10, 0}}
+		f.delegations(entity.DelegationRequest{Kind: entity.KindFailed})
+		assert.Equal(t, "WHERE kind = $3", f.where())
+		assert.Equal(t, []any{10, 0, entity.KindFailed}, f.params)
+	})
+
+	t.Run("delegations_without_filter", func(t *testing.T) {
+		f := filter{params: []any{10, 0}}
+		f.delegations(entity.DelegationRequest{Limit: 10})
+		assert.Equal(t, "", f.where())
+	})
+
+	t.Run("sorted", func(t *testing.T) {
+		ts := time.Date(2023, 9, 16, 11, 53, 1, 0, time.UTC)
+		after := entity.DelegationCursor{TimeStamp: ts, Amount: 5000, Id: 3034}
+		for _, tc := range []struct {
+			name   string
+			dgr    entity.DelegationRequest
+			order  string
+			where  string
+			params []any
+		}{
+			{
+				name:  "default",
+				dgr:   entity.DelegationRequest{},
+				order: "ts DESC, id DESC",
+			},
+			{
+				name:   "timestamp_after_cursor",
+				dgr:    entity.DelegationRequest{After: after},
+				order:  "ts DESC, id DESC",
+				where:  "WHERE ts <= $3 AND (ts < $4 OR id < $5)",
+				params: []any{ts, ts, int64(3034)},
+			},
+			{
+				name:   "amount_ascending_after_cursor",
+				dgr:    entity.DelegationRequest{Sort: entity.SortAmount, Order: entity.OrderAsc, After: after},
+				order:  "amount ASC, id ASC",
+				where:  "WHERE amount >= $3 AND (amount > $4 OR id > $5)",
+				params: []any{int64(5000), int64(5000), int64(3034)},
+			},
+			{
+				name:   "id_after_cursor",
+				dgr:    entity.DelegationRequest{Sort: entity.SortId, After: after},
+				order:  "id DESC",
+				where:  "WHERE id < $3",
+				params: []any{int64(3034)},
+			},
+			{
+				name:  "unknown_sort",
+				dgr:   entity.DelegationRequest{Sort: "delegator", Order: "up"},
+				order: "ts DESC, id DESC",
+			},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				f := filter{params: []any{10, 0}}
+				assert.Equal(t, tc.order, f.sorted(tc.dgr))
+				assert.Equal(t, tc.where, f.where())
+				assert.Equal(t, append([]any{10, 0}, tc.params...), f.params)
+			})
+		}
+	})
+}
@@ -0,0 +1,212 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/frisk038/tezos-delegation-service/domain/entity"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	insertSubscription = `INSERT INTO webhook_subscriptions
+							(url, secret, addresses, events, after_id)
+						SELECT $1, $2, $3, $4, COALESCE(MAX(id), 0)
+							FROM delegations
+						RETURNING id, after_id, created_at;`
+	selectSubscriptions = `SELECT id, url, secret, addresses, events, after_id, created_at
+							FROM webhook_subscriptions
+							ORDER BY id;`
+	deleteSubscription = `DELETE FROM webhook_subscriptions
+							WHERE id = $1;`
+	insertWebhookEvent = `INSERT INTO webhook_events
+							(subscription_id, operation_id, event, baker)
+						VALUES ($1, $2, $3, $4)
+						ON CONFLICT (subscription_id, operation_id, event) DO NOTHING;`
+	deletePendingEvents = `DELETE FROM webhook_events
+							WHERE operation_id = ANY($1) AND state = 'pending';`
+	selectPendingEvents = `SELECT e.event, e.event_baker, e.attempts, ` + delegationColumns + `
+							FROM (SELECT id AS event_id, operation_id, event, baker AS event_baker, attempts
+									FROM webhook_events
+									WHERE subscription_id = $1 AND state = 'pending'
+									ORDER BY id
+									LIMIT $2) e
+								JOIN delegations ON delegations.id = e.operation_id
+							ORDER BY e.event_id;`
+	updateWebhookEvent = `UPDATE webhook_events
+							SET state = $4, attempts = $5
+							WHERE subscription_id = $1 AND operation_id = $2 AND event = $3;`
+	insertDelivery = `INSERT INTO webhook_deliveries
+							(subscription_id, operation_id, event, attempt, status_code, error)
+						VALUES ($1, $2, $3, $4, NULLIF($5, 0), NULLIF($6, ''));`
+	// deleteExpiredEvents and deleteExpiredDeliveries remove the events no longer pending and the delivery attempts
+	// older than the retention $1.
+	deleteExpiredEvents = `DELETE FROM webhook_events
+							WHERE state <> 'pending' AND created_at < now() - $1::interval;`
+	deleteExpiredDeliveries = `DELETE FROM webhook_deliveries
+							WHERE created_at < now() - $1::interval;`
+	selectDeliveries = `SELECT id, subscription_id, operation_id, event, attempt, COALESCE(status_code, 0),
+								COALESCE(error, ''), created_at
+							FROM webhook_deliveries
+							WHERE subscription_id = $1
+							ORDER BY id DESC
+							LIMIT $2
+							OFFSET $3;`
+)
+
+// InsertSubscription stores a webhook subscription and returns it along with its id, the last operation stored
+// and its creation time.
+func (c *Client) InsertSubscription(ctx context.Context, sub entity.Subscription) (entity.Subscription, error) {
+	err := c.conn.QueryRow(ctx, insertSubscription, sub.Url, sub.Secret, sub.Addresses, sub.Events).
+		Scan(&sub.Id, &sub.AfterId, &sub.CreatedAt)
+	if err != nil {
+		return entity.Subscription{}, err
+	}
+
+	return sub, nil
+}
+
+// SelectSubscriptions returns every webhook subscription, the oldest first.
+func (c *Client) SelectSubscriptions(ctx context.Context) ([]entity.Subscription, error) {
+	rows, err := c.conn.Query(ctx, selectSubscriptions)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanSubscriptions(rows)
+}
+
+// scanSubscriptions reads every subscription returned by selectSubscriptions and closes the rows.
+func scanSubscriptions(rows pgx.Rows) ([]entity.Subscription, error) {
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (entity.Subscription, error) {
+		var sub entity.Subscription
+		err := row.Scan(&sub.Id, &sub.Url, &sub.Secret, &sub.Addresses, &sub.Events, &sub.AfterId, &sub.CreatedAt)
+		return sub, err
+	})
+}
+
+// DeleteSubscription removes a webhook subscription along with its events and deliveries, entity.ErrNotFound if
+// there is none.
+func (c *Client) DeleteSubscription(ctx context.Context, id int64) error {
+	tag, err := c.conn.Exec(ctx, deleteSubscription, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return entity.ErrNotFound
+	}
+
+	return nil
+}
+
+// queueWebhookEvents writes the pending events of the stored delegations for each subscription, within the transaction.
+// Events already written, e.g. when a delegation is stored again, are left as they are.
+func queueWebhookEvents(ctx context.Context, tx pgx.Tx, dgs []entity.Delegation) error {
+	if len(dgs) == 0 {
+		return nil
+	}
+
+	rows, err := tx.Query(ctx, selectSubscriptions)
+	if err != nil {
+		return err
+	}
+	subs, err := scanSubscriptions(rows)
+	if err != nil {
+		return err
+	}
+
+	batch := &pgx.Batch{}
+	for _, dg := range dgs {
+		for _, sub := range subs {
+			for _, ev := range sub.Match(dg) {
+				batch.Queue(insertWebhookEvent, sub.Id, dg.Id, ev.Kind, ev.Baker)
+			}
+		}
+	}
+	if batch.Len() == 0 {
+		return nil
+	}
+
+	return tx.SendBatch(ctx, batch).Close()
+}
+
+// dropPendingEvents deletes the events not delivered yet of the delegations with the given ids, within the transaction.
+func dropPendingEvents(ctx context.Context, tx pgx.Tx, ids []int64) error {
+	_, err := tx.Exec(ctx, deletePendingEvents, ids)
+	return err
+}
+
+// SelectPendingEvents returns up to limit events of a subscription not delivered yet, in the order of delivery.
+func (c *Client) SelectPendingEvents(ctx context.Context, subscriptionId int64, limit int) ([]entity.WebhookEvent, error) {
+	rows, err := c.conn.Query(ctx, selectPendingEvents, subscriptionId, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (entity.WebhookEvent, error) {
+		var ev entity.WebhookEvent
+		dg := &ev.Delegation
+		err := row.Scan(&ev.Kind, &ev.Baker, &ev.Attempts, &dg.TimeStamp, &dg.Amount, &dg.Delegator, &dg.Block, &dg.Id,
+			&dg.Hash, &dg.Level, &dg.Baker, &dg.PrevBaker, &dg.Status, &dg.BakerFee, &dg.GasUsed, &dg.Counter)
+		return ev, err
+	})
+}
+
+// InsertDelivery logs an attempt to notify an event to a subscription, and records the state of the event along with
+// its count of attempts in the same transaction.
+func (c *Client) InsertDelivery(ctx context.Context, dlv entity.Delivery, state string) error {
+	tx, err := c.conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	_, err = tx.Exec(ctx, insertDelivery,
+		dlv.SubscriptionId, dlv.OperationId, dlv.Event, dlv.Attempt, dlv.StatusCode, dlv.Error)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, updateWebhookEvent, dlv.SubscriptionId, dlv.OperationId, dlv.Event, state, dlv.Attempt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// DeleteExpiredEvents removes the events delivered or given up along with the delivery attempts older than the
+// retention, and returns the count of each deleted.
+func (c *Client) DeleteExpiredEvents(ctx context.Context, retention time.Duration) (int64, int64, error) {
+	events, err := c.conn.Exec(ctx, deleteExpiredEvents, retention)
+	if err != nil {
+		return 0, 0, err
+	}
+	deliveries, err := c.conn.Exec(ctx, deleteExpiredDeliveries, retention)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return events.RowsAffected(), deliveries.RowsAffected(), nil
+}
+
+// SelectDeliveries returns the logged delivery attempts of a subscription, the most recent first.
+func (c *Client) SelectDeliveries(ctx context.Context, subscriptionId int64, limit, offset int) ([]entity.Delivery, error) {
+	rows, err := c.conn.Query(ctx, selectDeliveries, subscriptionId, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []entity.Delivery
+	for rows.Next() {
+		var dlv entity.Delivery
+		err = rows.Scan(&dlv.Id, &dlv.SubscriptionId, &dlv.OperationId, &dlv.Event, &dlv.Attempt, &dlv.StatusCode,
+			&dlv.Error, &dlv.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, dlv)
+	}
+
+	return res, rows.Err()
+}
@@ -0,0 +1,8 @@
+-- Create a table named 'backfill_checkpoints' to resume historical backfills where they stopped.
+CREATE TABLE backfill_checkpoints (
+    range_from TIMESTAMP NOT NULL,                -- Inclusive start of the backfilled range
+    range_to TIMESTAMP NOT NULL,                  -- Exclusive end of the backfilled range
+    cursor_ts TIMESTAMP NOT NULL,                 -- Every delegation before this timestamp has been stored
+    updated_at TIMESTAMP NOT NULL DEFAULT now(),  -- Last time the checkpoint moved forward
+    PRIMARY KEY (range_from, range_to)
+);
@@ -0,0 +1,2 @@
+-- Index the delegations timestamp, used to find the most recent blocks and to list delegations by date.
+CREATE INDEX delegations_ts_idx ON delegations (ts);
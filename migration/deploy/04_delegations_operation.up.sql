@@ -0,0 +1,10 @@
+-- Add the details of the delegation operation, left empty on the delegations stored before.
+ALTER TABLE delegations
+    ADD COLUMN hash text,         -- Hash of the operation
+    ADD COLUMN level bigint,      -- Level of the block
+    ADD COLUMN baker text,        -- Delegate chosen by the delegator, null on an undelegation
+    ADD COLUMN prev_baker text,   -- Delegate of the delegator before the operation, null if it had none
+    ADD COLUMN status text,       -- Status of the operation: applied, failed, backtracked or skipped
+    ADD COLUMN baker_fee bigint,  -- Fee paid to the baker of the block, in mutez
+    ADD COLUMN gas_used bigint,   -- Gas consumed by the operation
+    ADD COLUMN counter bigint;    -- Counter of the delegator's account used by the operation
@@ -0,0 +1,12 @@
+-- Classify the delegation operations: delegate, undelegate, re-delegate or failed, null when the status is unknown.
+ALTER TABLE delegations ADD COLUMN kind text;
+
+-- Classify the operations stored with their details before.
+UPDATE delegations
+    SET kind = CASE
+        WHEN status <> 'applied' THEN 'failed'
+        WHEN baker IS NULL THEN 'undelegate'
+        WHEN prev_baker IS NOT NULL THEN 're-delegate'
+        ELSE 'delegate'
+    END
+    WHERE status IS NOT NULL;
@@ -0,0 +1,17 @@
+-- Create a table named 'delegator_state' holding the current delegation of each delegator, as of its last applied operation.
+CREATE TABLE delegator_state (
+    delegator text PRIMARY KEY,                   -- Delegator's address
+    baker text,                                   -- Current delegate, null when the delegator withdrew its delegation
+    since_ts TIMESTAMP NOT NULL,                  -- Timestamp of the last applied operation
+    since_level bigint,                           -- Level of the last applied operation
+    last_id bigint NOT NULL,                      -- Id of the last applied operation
+    amount bigint NOT NULL,                       -- Amount known at the last applied operation
+    updated_at TIMESTAMP NOT NULL DEFAULT now()   -- Last time the state changed
+);
+
+-- Build the state of the delegators already stored, delegations without status being considered applied.
+INSERT INTO delegator_state (delegator, baker, since_ts, since_level, last_id, amount)
+SELECT DISTINCT ON (delegator) delegator, baker, ts, level, id, amount
+    FROM delegations
+    WHERE COALESCE(status, 'applied') = 'applied'
+    ORDER BY delegator, id DESC;
@@ -0,0 +1,2 @@
+-- Index the delegations by delegator, used to list the timeline of a delegator in id order.
+CREATE INDEX delegations_delegator_idx ON delegations (delegator, id);
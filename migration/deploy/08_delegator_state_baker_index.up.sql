@@ -0,0 +1,2 @@
+-- Index the delegator states by baker, used to list the current delegators of a baker.
+CREATE INDEX delegator_state_baker_idx ON delegator_state (baker);
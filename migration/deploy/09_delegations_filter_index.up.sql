@@ -0,0 +1,4 @@
+-- Index the delegations by baker, block and level, used to filter the list of delegations.
+CREATE INDEX delegations_baker_idx ON delegations (baker, ts);
+CREATE INDEX delegations_block_idx ON delegations (block);
+CREATE INDEX delegations_level_idx ON delegations (level);
@@ -0,0 +1,5 @@
+-- Index the delegations by timestamp and by amount with id as tiebreak, used to sort the list of delegations
+-- and to walk it with a cursor. The timestamp index is replaced by the one with the tiebreak.
+CREATE INDEX delegations_ts_id_idx ON delegations (ts, id);
+CREATE INDEX delegations_amount_id_idx ON delegations (amount, id);
+DROP INDEX delegations_ts_idx;
@@ -0,0 +1,30 @@
+-- Create a table named 'delegations_daily' holding the aggregate of the applied delegations of each day (UTC).
+CREATE TABLE delegations_daily (
+    day date PRIMARY KEY,                         -- Day of the delegations
+    count bigint NOT NULL,                        -- Count of operations
+    total bigint NOT NULL,                        -- Sum of the amounts
+    delegators bigint NOT NULL,                   -- Count of distinct delegators
+    median bigint NOT NULL,                       -- Median of the amounts, rounded
+    updated_at TIMESTAMP NOT NULL DEFAULT now()   -- Last time the day was computed
+);
+
+-- Create a table named 'delegations_daily_delegators' listing the delegators of each day of 'delegations_daily',
+-- so that the distinct delegators of weeks, months and years are counted from their days.
+CREATE TABLE delegations_daily_delegators (
+    day date NOT NULL REFERENCES delegations_daily (day) ON DELETE CASCADE, -- Day of the delegations
+    delegator text NOT NULL,                                                -- Delegator of at least one of them
+    PRIMARY KEY (day, delegator)
+);
+
+-- Fill both tables with the delegations already stored.
+INSERT INTO delegations_daily (day, count, total, delegators, median)
+SELECT ts::date, COUNT(*), SUM(amount)::bigint, COUNT(DISTINCT delegator),
+    round(percentile_cont(0.5) WITHIN GROUP (ORDER BY amount))::bigint
+FROM delegations
+WHERE COALESCE(status, 'applied') = 'applied'
+GROUP BY ts::date;
+
+INSERT INTO delegations_daily_delegators (day, delegator)
+SELECT DISTINCT ts::date, delegator
+FROM delegations
+WHERE COALESCE(status, 'applied') = 'applied';
@@ -0,0 +1,42 @@
+-- Create a table named 'webhook_subscriptions' holding the webhooks notified of the delegators joining or leaving bakers.
+CREATE TABLE webhook_subscriptions (
+    id bigserial PRIMARY KEY,                     -- Id of the subscription
+    url text NOT NULL,                            -- Url the events are posted to
+    secret text NOT NULL,                         -- Key of the HMAC signature of the payloads
+    addresses text[] NOT NULL,                    -- Addresses of the watched bakers
+    events text[] NOT NULL,                       -- Kinds of the notified events
+    after_id bigint NOT NULL,                     -- Last operation stored on creation, only the later ones are notified
+    created_at TIMESTAMP NOT NULL DEFAULT now()   -- Creation of the subscription
+);
+
+-- Create a table named 'webhook_events' holding the events of each subscription, written along with their delegation
+-- and delivered in id order. An event is notified once, even if its delegation is stored again.
+CREATE TABLE webhook_events (
+    id bigserial PRIMARY KEY,                     -- Id of the event, in the order of delivery
+    subscription_id bigint NOT NULL REFERENCES webhook_subscriptions (id) ON DELETE CASCADE,
+    operation_id bigint NOT NULL,                 -- Id of the delegation
+    event text NOT NULL,                          -- Kind of the event
+    baker text NOT NULL,                          -- Watched baker joined or left
+    state text NOT NULL DEFAULT 'pending',        -- pending until delivered, or failed once the retries ran out
+    attempts int NOT NULL DEFAULT 0,              -- Count of the attempts made
+    created_at TIMESTAMP NOT NULL DEFAULT now(),  -- Storage of the delegation
+    UNIQUE (subscription_id, operation_id, event)
+);
+CREATE INDEX webhook_events_pending_idx ON webhook_events (subscription_id, id) WHERE state = 'pending';
+-- Index the events no longer pending by creation, deleted once older than the retention.
+CREATE INDEX webhook_events_done_idx ON webhook_events (created_at) WHERE state <> 'pending';
+
+-- Create a table named 'webhook_deliveries' logging each attempt to notify an event, deleted along with its subscription.
+CREATE TABLE webhook_deliveries (
+    id bigserial PRIMARY KEY,                     -- Id of the attempt
+    subscription_id bigint NOT NULL REFERENCES webhook_subscriptions (id) ON DELETE CASCADE,
+    operation_id bigint NOT NULL,                 -- Id of the notified delegation
+    event text NOT NULL,                          -- Kind of the event
+    attempt int NOT NULL,                         -- Number of the attempt, from 1
+    status_code int,                              -- Status answered by the receiver, null when it wasn't reached
+    error text,                                   -- Why the attempt failed, null once delivered
+    created_at TIMESTAMP NOT NULL DEFAULT now()   -- Time of the attempt
+);
+CREATE INDEX webhook_deliveries_subscription_idx ON webhook_deliveries (subscription_id, id);
+-- Index the attempts by time, deleted once older than the retention.
+CREATE INDEX webhook_deliveries_created_idx ON webhook_deliveries (created_at);
@@ -0,0 +1 @@
+DROP INDEX delegations_ts_idx;
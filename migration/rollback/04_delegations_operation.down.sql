@@ -0,0 +1,9 @@
+ALTER TABLE delegations
+    DROP COLUMN hash,
+    DROP COLUMN level,
+    DROP COLUMN baker,
+    DROP COLUMN prev_baker,
+    DROP COLUMN status,
+    DROP COLUMN baker_fee,
+    DROP COLUMN gas_used,
+    DROP COLUMN counter;
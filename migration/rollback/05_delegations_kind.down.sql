@@ -0,0 +1 @@
+ALTER TABLE delegations DROP COLUMN kind;
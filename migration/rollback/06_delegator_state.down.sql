@@ -0,0 +1 @@
+DROP TABLE delegator_state;
@@ -0,0 +1 @@
+DROP INDEX delegations_delegator_idx;
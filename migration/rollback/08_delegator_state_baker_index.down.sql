@@ -0,0 +1 @@
+DROP INDEX delegator_state_baker_idx;
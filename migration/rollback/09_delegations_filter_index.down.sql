@@ -0,0 +1,3 @@
+DROP INDEX delegations_level_idx;
+DROP INDEX delegations_block_idx;
+DROP INDEX delegations_baker_idx;
@@ -0,0 +1,3 @@
+CREATE INDEX delegations_ts_idx ON delegations (ts);
+DROP INDEX delegations_amount_id_idx;
+DROP INDEX delegations_ts_id_idx;
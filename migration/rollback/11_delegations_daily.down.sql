@@ -0,0 +1,2 @@
+DROP TABLE delegations_daily_delegators;
+DROP TABLE delegations_daily;